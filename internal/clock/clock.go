@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"fmt"
+	"time"
+
+	// time zones are embedded, so the bot doesn't depend on tzdata of the system
+	_ "time/tzdata"
+)
+
+// Clock gives the current time in the location of the bot. Services and producers get it instead of calling
+// time.Now, so all of them agree about what time it is
+type Clock interface {
+	// Now returns the current time in the location of the bot
+	Now() time.Time
+
+	// Today returns the current date in the location of the bot
+	Today() time.Time
+
+	Location() *time.Location
+}
+
+type clock struct {
+	location *time.Location
+}
+
+func New(location *time.Location) *clock {
+	return &clock{
+		location: location,
+	}
+}
+
+func (c *clock) Now() time.Time {
+	return time.Now().In(c.location)
+}
+
+func (c *clock) Today() time.Time {
+	return Date(c.Now())
+}
+
+func (c *clock) Location() *time.Location {
+	return c.location
+}
+
+// In returns the current time of the clock in the IANA time zone, empty timezone is the location of the clock
+func In(c Clock, timezone string) (time.Time, error) {
+	if timezone == "" {
+		return c.Now(), nil
+	}
+	location, err := LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return c.Now().In(location), nil
+}
+
+// Date returns the date of the time as midnight UTC, the same way postgres returns dates
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// LoadLocation loads the IANA time zone, e.g. Europe/Minsk. Empty name is UTC.
+// The offset in whole hours (3h, -5h) is supported for old configs, it becomes Etc/GMT-3, Etc/GMT+5
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if offset, err := time.ParseDuration(name); err == nil {
+		if offset%time.Hour != 0 {
+			return nil, fmt.Errorf("offset %s isn't in whole hours, use IANA time zone instead", name)
+		}
+		// signs of Etc/GMT zones are inverted: Etc/GMT-3 is UTC+3
+		hours := int(offset / time.Hour)
+		if hours == 0 {
+			return time.UTC, nil
+		}
+		name = fmt.Sprintf("Etc/GMT%+d", -hours)
+	}
+	if name == "Local" {
+		return nil, fmt.Errorf("time zone must be set explicitly")
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("loadLocation: %w", err)
+	}
+	return location, nil
+}
@@ -0,0 +1,74 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "", want: "UTC"},
+		{name: "Europe/Minsk", want: "Europe/Minsk"},
+		{name: "3h", want: "Etc/GMT-3"},
+		{name: "-5h", want: "Etc/GMT+5"},
+		{name: "0h", want: "UTC"},
+		{name: "3h30m", wantErr: true},
+		{name: "Local", wantErr: true},
+		{name: "Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		location, err := LoadLocation(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("LoadLocation(%q) = %s, want error", tt.name, location)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("LoadLocation(%q): %s", tt.name, err)
+			continue
+		}
+		if location.String() != tt.want {
+			t.Errorf("LoadLocation(%q) = %s, want %s", tt.name, location, tt.want)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	location, err := LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// it's still the 1st in Tokyo though it's the 31st in UTC
+	got := Date(time.Date(2026, time.June, 1, 1, 30, 0, 0, location))
+	want := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("Date() = %s, want %s", got, want)
+	}
+}
+
+func TestFake(t *testing.T) {
+	location, err := LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewFake(time.Date(2026, time.June, 1, 23, 50, 0, 0, location))
+	if f.Location() != location {
+		t.Errorf("Location() = %s, want %s", f.Location(), location)
+	}
+	f.Add(20 * time.Minute)
+	if got := f.Now(); got.Day() != 2 || got.Hour() != 0 || got.Minute() != 10 {
+		t.Errorf("Now() = %s after Add", got)
+	}
+	if got, want := f.Today(), time.Date(2026, time.June, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Today() = %s, want %s", got, want)
+	}
+	f.Set(time.Date(2026, time.January, 1, 12, 0, 0, 0, location))
+	if got := f.Today(); got.Month() != time.January {
+		t.Errorf("Today() = %s after Set", got)
+	}
+}
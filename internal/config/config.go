@@ -9,16 +9,23 @@ import (
 
 type Config struct {
 	LogLevel                           int           `env:"LOG_LEVEL"`
-	Timezone                           time.Duration `env:"TIMEZONE"`
-	StartingMinutes                    []int         `env:"STARTING_MINUTES"`
-	TickInterval                       time.Duration `env:"TICK_INTERVAL"`
+	Timezone                           string        `env:"TIMEZONE"`
 	PeriodOfTimeBeforeLunchToShipOrder time.Duration `env:"PERIOD_OF_TIME_BEFORE_LUNCH_TO_SHIP_ORDER"`
 	AdminChatID                        int64         `env:"ADMIN_CHAT_ID"`
 	StartedLunchTime                   time.Duration `env:"STARTED_LUNCH_TIME"`
 	FinishedLunchTime                  time.Duration `env:"FINISHED_LUNCH_TIME"`
+	OrderHorizonDays                   int           `env:"ORDER_HORIZON_DAYS" envDefault:"7"`
+
+	// CatchUpLimit is how far back scheduled jobs run instants which are missed while the bot isn't working
+	CatchUpLimit time.Duration `env:"CATCH_UP_LIMIT" envDefault:"24h"`
+
+	// ConversationStorage is where steps of multi-step flows are kept: postgres or memory
+	ConversationStorage string `env:"CONVERSATION_STORAGE" envDefault:"postgres"`
+	// HTTPAddr is where the server of health checks and the webhook listens
+	HTTPAddr string `env:"HTTP_ADDR" envDefault:":8080"`
 	Postgres
 	TelegramBot
-	Menu
+	Webhook
 	UsersReminder
 	StatisticsSender
 }
@@ -34,15 +41,23 @@ type Postgres struct {
 type TelegramBot struct {
 	Token   string `env:"BOT_TOKEN"`
 	Timeout int    `env:"BOT_TIMEOUT"`
+
+	// Workers is how many updates are handled at the same time, updates of one chat are handled in order
+	Workers          int `env:"UPDATE_WORKERS" envDefault:"8"`
+	UpdatesQueueSize int `env:"UPDATES_QUEUE_SIZE" envDefault:"1000"`
 }
 
-type Menu struct {
-	Categories []string           `env:"CATEGORIES"`
-	Soups      map[string]float32 `env:"SOUPS"`
-	Salads     map[string]float32 `env:"SALADS"`
-	MainCourse map[string]float32 `env:"MAIN_COURSE"`
-	Desserts   map[string]float32 `env:"DESSERTS"`
-	Drinks     map[string]float32 `env:"DRINKS"`
+// Webhook is used when UPDATES_MODE is webhook, otherwise updates are received by long polling
+type Webhook struct {
+	UpdatesMode string `env:"UPDATES_MODE" envDefault:"polling"`
+
+	// URL is the public address telegram sends updates to, its path is served by the http server
+	URL    string `env:"WEBHOOK_URL"`
+	Secret string `env:"WEBHOOK_SECRET"`
+
+	// TLS is served only if both files are set, it isn't needed behind the proxy which terminates TLS
+	CertFile string `env:"WEBHOOK_CERT_FILE"`
+	KeyFile  string `env:"WEBHOOK_KEY_FILE"`
 }
 
 type UsersReminder struct {
@@ -53,6 +68,10 @@ type UsersReminder struct {
 type StatisticsSender struct {
 	ReportHour      int     `env:"REPORT_HOUR"`
 	ReportReceivers []int64 `env:"REPORT_RECEIVERS"`
+
+	// ReportSchedule is the cron expression of reports, e.g. "0 10 * * 1-5". If it's empty, reports are sent
+	// every day at REPORT_HOUR
+	ReportSchedule string `env:"REPORT_SCHEDULE"`
 }
 
 func NewConfig() *Config {
@@ -6,9 +6,16 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/fsm"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
 	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
 	"github.com/chucky-1/food-delivery-bot/internal/service"
 	"github.com/chucky-1/food-delivery-bot/internal/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -23,6 +30,17 @@ var (
 		"Что бы снять блюдо со стопа, жмём\n/all_stopped_dishes, выбираем блюдо\n\n" +
 		"Создать организацию\n/create_organization\n\n" +
 		"Добавить адрес организации\n/add_address\n\n" +
+		"Часовой пояс организации, если он отличается от часового пояса бота (без пояса - сбросить)\n" +
+		"/timezone ID_организации Europe/Moscow\n\n" +
+		"Добавить категорию меню\n/add_category\n\n" +
+		"Добавить, изменить или удалить блюдо\n/add_dish\n/edit_dish\n/delete_dish\n\n" +
+		"Подготовить меню на дату или день недели\n/plan_menu\n\n" +
+		"Скрыть или показать категорию клиентам\n/hide_category Название\n/show_category Название\n\n" +
+		"Праздники и перенесённые рабочие дни (без ID организации - для всех)\n" +
+		"/holiday 01.01.2027 ID_организации\n/workday 01.11.2026 ID_организации\n/reset_day 01.01.2027\n/calendar\n\n" +
+		"Доставки на сегодня и смена их статусов\n/deliveries\n\n" +
+		"Задачи по расписанию и запуск задачи вне расписания\n/jobs\n/run_job название\n\n" +
+		"/cancel - отменить начатое действие\n\n" +
 		"/info - показать это сообщение (можно ввести эту команду руками, когда это сообщение потеряется в куче других сообщений)"
 	createOrganization = "Отправьте сообщение в следующем формате: \n\n" +
 		"Название организации 12:30\n\n" +
@@ -35,226 +53,284 @@ var (
 		"Пример:\n" +
 		"ул. Толбухина 18/2"
 	successfulAddAddress = "Адрес организации успешно добавлен"
-)
-
-var (
-	errInvalidOrganizationID = errors.New("invalid organization id")
+	addCategory          = "Отправьте название категории, при желании вместе с эмодзи в начале\n\n" +
+		"Пример:\n" +
+		"🥐 Выпечка"
+	successfulAddCategory      = "Категория «%s» успешно добавлена"
+	successfulHideCategory     = "Категория «%s» скрыта от клиентов"
+	successfulShowCategory     = "Категория «%s» снова видна клиентам"
+	categoryNotFound           = "Категория «%s» не найдена"
+	invalidCategoryName        = "Вы ввели некорректное название категории. Попробуйте ещё раз"
+	categoryAlreadyExists      = "Категория с таким названием уже есть. Введите другое название"
+	categoryNameRequiredFormat = "Укажите название категории после команды, например:\n/%s Выпечка"
+	actionIsOutdated           = "Это действие уже неактуально"
+	timezoneRequiredFormat     = "Укажите ID организации и часовой пояс после команды, например:\n" +
+		"/timezone ID_организации Europe/Moscow\n\n" +
+		"Без часового пояса организация будет жить по часовому поясу бота"
+	invalidTimezone         = "Не получилось распознать часовой пояс «%s». Используйте названия вида Europe/Moscow или Asia/Almaty"
+	successfulTimezone      = "Часовой пояс организации: %s"
+	successfulResetTimezone = "Организация теперь живёт по часовому поясу бота"
 )
 
 const (
 	info              = "info"
 	allActivateDishes = "all_active_dishes"
 	allStoppedDishes  = "all_stopped_dishes"
+	hideCategory      = "hide_category"
+	showCategory      = "show_category"
+	timezone          = "timezone"
 )
 
 type Admin struct {
-	bot               *tgbotapi.BotAPI
-	updatesChan       chan tgbotapi.Update
+	bot               messenger.Messenger
 	org               service.Organization
 	menu              service.Menu
+	calendar          service.Calendar
+	shipments         service.Shipment
+	clock             clock.Clock
 	msgStore          *storage.Messages
+	jobs              *scheduler.Scheduler
 	adminID           int64
 	startedLunchTime  time.Duration
 	finishedLunchTime time.Duration
 
+	// mu guards the state and the plan, updates of the admin from the private chat and from groups are handled
+	// in parallel
+	mu sync.Mutex
+
 	// if true - state to activate dishes
 	state bool
+
+	// day whose menu is being planned, nil if admin doesn't plan the menu
+	plan *model.MenuDay
+
+	flows *fsm.Machine
 }
 
-func NewAdmin(bot *tgbotapi.BotAPI, updatesChan chan tgbotapi.Update, org service.Organization, menu service.Menu,
-	msgStore *storage.Messages, adminID int64, startedLunchTime time.Duration, finishedLunchTime time.Duration) *Admin {
-	return &Admin{
+func NewAdmin(bot messenger.Messenger, org service.Organization, menu service.Menu,
+	calendar service.Calendar, shipments service.Shipment, clock clock.Clock, msgStore *storage.Messages, jobs *scheduler.Scheduler, adminID int64,
+	startedLunchTime time.Duration, finishedLunchTime time.Duration) *Admin {
+	a := &Admin{
 		bot:               bot,
-		updatesChan:       updatesChan,
 		org:               org,
 		menu:              menu,
+		calendar:          calendar,
+		shipments:         shipments,
+		clock:             clock,
 		msgStore:          msgStore,
+		jobs:              jobs,
 		adminID:           adminID,
 		startedLunchTime:  startedLunchTime,
 		finishedLunchTime: finishedLunchTime,
 	}
+	a.flows = fsm.New(bot, msgStore, a.createOrganizationFlow(), a.addAddressFlow(), a.addCategoryFlow())
+	return a
 }
 
-func (a *Admin) Consume(ctx context.Context) {
-	logrus.Info("admin consumer started")
-	err := a.sendWelcomeMessage()
-	if err != nil {
-		logrus.Errorf("admin: %s", err.Error())
+func (a *Admin) Handle(ctx context.Context, update tgbotapi.Update) {
+	var err error
+	if update.CallbackQuery != nil {
+		err = a.handleCallback(ctx, update.CallbackQuery)
+		if err != nil {
+			logrus.Errorf("admin: handleCallback: %s", err.Error())
+		}
+		return
+	}
+	if update.Message == nil {
 		return
 	}
-	for {
-		select {
-		case <-ctx.Done():
-			logrus.Infof("admin consumer stopped: %s", ctx.Err().Error())
+	if update.Message.IsCommand() {
+		if a.flows.Starts(update.Message.Command()) {
+			err = a.flows.Start(ctx, update.Message.Command(), update.SentFrom(), update.Message.Chat.ID)
+			if err != nil {
+				logrus.Errorf("admin: start flow: %s", err.Error())
+			}
 			return
-		case update := <-a.updatesChan:
-			if update.Message.IsCommand() {
-				switch update.Message.Command() {
-				case info:
-					err = a.sendWelcomeMessage()
-					if err != nil {
-						logrus.Errorf("admin: info: %s", err.Error())
-						continue
-					}
-				case allActivateDishes:
-					a.state = false
-
-					newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-					err = a.sendCategories(newCtx, update.Message.Chat.ID)
-					if err != nil {
-						cancel()
-						logrus.Errorf("admin: allActivateDishes: %s", err.Error())
-						continue
-					}
-					cancel()
-					continue
-
-				case allStoppedDishes:
-					a.state = true
-
-					newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-					err = a.sendCategories(newCtx, update.Message.Chat.ID)
-					if err != nil {
-						cancel()
-						logrus.Errorf("admin: allActivateDishes: %s", err.Error())
-						continue
-					}
-					cancel()
-					continue
-
-				case storage.CreateOrganization:
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, createOrganization)
-					_, err = a.bot.Send(msg)
-					if err != nil {
-						logrus.Errorf("createOrganization: send: %s", err.Error())
-						continue
-					}
-
-					a.msgStore.WaitMessage(update.SentFrom().ID, storage.CreateOrganization, update.Message.MessageID+2, "")
-					continue
-
-				case storage.AddAddress:
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, addAddressStep1)
-					_, err = a.bot.Send(msg)
-					if err != nil {
-						logrus.Errorf("createOrganization: send: %s", err.Error())
-						continue
-					}
-
-					a.msgStore.WaitMessage(update.SentFrom().ID, storage.AddAddress, update.Message.MessageID+2, "")
-					continue
+		}
+		switch update.Message.Command() {
+		case info:
+			err = a.SendWelcomeMessage()
+			if err != nil {
+				logrus.Errorf("admin: info: %s", err.Error())
+				return
+			}
+		case allActivateDishes:
+			a.setState(false)
 
-				}
-			} else {
-				switch update.Message.Text {
-				case model.Soups:
-					err = a.sendDishes(ctx, update.Message.Chat.ID, model.Soups, a.state)
-					if err != nil {
-						logrus.Errorf("sendDishes: %s", err.Error())
-						continue
-					}
-					continue
-				case model.Salads:
-					err = a.sendDishes(ctx, update.Message.Chat.ID, model.Salads, a.state)
-					if err != nil {
-						logrus.Errorf("sendDishes: %s", err.Error())
-						continue
-					}
-					continue
-				case model.MainCourse:
-					err = a.sendDishes(ctx, update.Message.Chat.ID, model.MainCourse, a.state)
-					if err != nil {
-						logrus.Errorf("sendDishes: %s", err.Error())
-						continue
-					}
-					continue
-				case model.Desserts:
-					err = a.sendDishes(ctx, update.Message.Chat.ID, model.Desserts, a.state)
-					if err != nil {
-						logrus.Errorf("sendDishes: %s", err.Error())
-						continue
-					}
-					continue
-				case model.Drinks:
-					err = a.sendDishes(ctx, update.Message.Chat.ID, model.Drinks, a.state)
-					if err != nil {
-						logrus.Errorf("sendDishes: %s", err.Error())
-						continue
-					}
-					continue
-				case goBackToMenu, "Меню":
-					err = a.sendCategories(ctx, update.Message.Chat.ID)
-					if err != nil {
-						logrus.Errorf("sendMenu: %s", err.Error())
-						continue
-					}
-					continue
-				}
+			newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			err = a.showCategories(newCtx, update.Message.Chat.ID, nil)
+			if err != nil {
+				cancel()
+				logrus.Errorf("admin: allActivateDishes: %s", err.Error())
+				return
+			}
+			cancel()
+			return
+
+		case allStoppedDishes:
+			a.setState(true)
+
+			newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			err = a.showCategories(newCtx, update.Message.Chat.ID, nil)
+			if err != nil {
+				cancel()
+				logrus.Errorf("admin: allActivateDishes: %s", err.Error())
+				return
+			}
+			cancel()
+			return
+
+		case hideCategory, showCategory:
+			err = a.setCategoryVisibility(ctx, update.Message.Chat.ID, update.Message.Command(),
+				update.Message.CommandArguments())
+			if err != nil {
+				logrus.Errorf("setCategoryVisibility: %s", err.Error())
+				return
+			}
+			return
+
+		case timezone:
+			err = a.setTimezone(ctx, update.Message.Chat.ID, update.Message.CommandArguments())
+			if err != nil {
+				logrus.Errorf("setTimezone: %s", err.Error())
+				return
+			}
+			return
+
+		case holiday, workday, resetDay:
+			err = a.setCalendarDay(ctx, update.Message.Chat.ID, update.Message.Command(),
+				update.Message.CommandArguments())
+			if err != nil {
+				logrus.Errorf("setCalendarDay: %s", err.Error())
+				return
+			}
+			return
+
+		case listJobs:
+			err = a.showJobs(update.Message.Chat.ID)
+			if err != nil {
+				logrus.Errorf("showJobs: %s", err.Error())
+				return
+			}
+			return
+
+		case runJob:
+			err = a.runJob(ctx, update.Message.Chat.ID, update.Message.CommandArguments())
+			if err != nil {
+				logrus.Errorf("runJob: %s", err.Error())
+				return
+			}
+			return
+
+		case deliveries:
+			err = a.showDeliveries(ctx, update.Message.Chat.ID, nil)
+			if err != nil {
+				logrus.Errorf("showDeliveries: %s", err.Error())
+				return
+			}
+			return
+
+		case calendarDays:
+			err = a.showCalendar(ctx, update.Message.Chat.ID)
+			if err != nil {
+				logrus.Errorf("showCalendar: %s", err.Error())
+				return
+			}
+			return
+
+		case storage.PlanMenu:
+			err = a.startPlanMenu(ctx, update.SentFrom().ID, update.Message.Chat.ID)
+			if err != nil {
+				logrus.Errorf("startPlanMenu: %s", err.Error())
+				return
+			}
+			return
+
+		case finishPlan:
+			err = a.finishPlanMenu(ctx, update.Message.Chat.ID)
+			if err != nil {
+				logrus.Errorf("finishPlanMenu: %s", err.Error())
+				return
+			}
+			return
+
+		case clearPlan:
+			err = a.clearPlanMenu(ctx, update.Message.Chat.ID)
+			if err != nil {
+				logrus.Errorf("clearPlanMenu: %s", err.Error())
+				return
+			}
+			return
+
+		case storage.AddDish, storage.EditDish, storage.DeleteDish:
+			err = a.startDishFlow(ctx, update.SentFrom().ID, update.Message.Chat.ID,
+				update.Message.Command())
+			if err != nil {
+				logrus.Errorf("startDishFlow: %s", err.Error())
+				return
+			}
+			return
+
+		case cancelCommand:
+			a.setPlan(nil)
+			err = cancelConversation(ctx, a.bot, a.msgStore, update.SentFrom().ID, update.Message.Chat.ID)
+			if err != nil {
+				logrus.Errorf("admin: cancelConversation: %s", err.Error())
+			}
+			return
+
+		}
+	} else {
+		switch update.Message.Text {
+		case goBackToMenu, "Меню":
+			err = a.showCategories(ctx, update.Message.Chat.ID, nil)
+			if err != nil {
+				logrus.Errorf("sendMenu: %s", err.Error())
+				return
+			}
+			return
+		}
 
-				newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-				dish, err := a.menu.GetDish(newCtx, update.Message.Text)
+		msgType, errExtract := a.msgStore.Extract(ctx, update.SentFrom().ID)
+		if errExtract != nil {
+			logrus.Errorf("admin: extract: %s", errExtract.Error())
+			return
+		}
+		if msgType != nil {
+			handled, errFlow := a.flows.Handle(ctx, msgType, update.Message)
+			if errFlow != nil {
+				logrus.Errorf("admin: handle flow: %s", errFlow.Error())
+			}
+			if handled {
+				return
+			}
+			switch msgType.Action {
+			case storage.PlanMenu:
+				err = a.choosePlanMenuDay(ctx, update.SentFrom().ID, update.Message.Chat.ID,
+					update.Message.Text)
 				if err != nil {
-					logrus.Errorf("admin: %s", err.Error())
-					cancel()
-					continue
+					logrus.Errorf("choosePlanMenuDay: %s", err.Error())
+					return
 				}
-				if dish != nil {
-					switch a.state {
-					case true:
-						err = a.menu.ActivateDish(ctx, dish.String())
-						if err != nil {
-							logrus.Errorf("admin: %s", err.Error())
-							cancel()
-							continue
-						}
-					case false:
-						err = a.menu.StopDish(ctx, dish.String())
-						if err != nil {
-							logrus.Errorf("admin: %s", err.Error())
-							cancel()
-							continue
-						}
-					}
-
-					err = a.sendDishes(newCtx, update.Message.Chat.ID, dish.Category, a.state)
-					if err != nil {
-						logrus.Error(err.Error())
-						cancel()
-						continue
-					}
-					cancel()
-					continue
-				}
-				cancel()
+				return
 
-				msgType, ok := a.msgStore.Extract(update.SentFrom().ID)
-				if !ok {
-					continue
+			default:
+				var photo string
+				if len(update.Message.Photo) != 0 {
+					photo = update.Message.Photo[len(update.Message.Photo)-1].FileID
 				}
-				switch msgType.Action {
-				case storage.CreateOrganization:
-					err = a.createOrganization(ctx, update.SentFrom().ID, update.Message.Chat.ID, update.Message.Text, update.Message.MessageID)
-					if err != nil {
-						logrus.Errorf("createOrganization: %s", err.Error())
-						continue
-					}
-					continue
-
-				case storage.AddAddress:
-					err = a.addAddress(ctx, update.SentFrom().ID, update.Message.Chat.ID, update.Message.MessageID,
-						update.Message.Text, msgType.DataOnFirstStep)
-					if err != nil {
-						logrus.Errorf("addAddress: %s", err.Error())
-						continue
-					}
-					continue
+				err = a.handleDishFlow(ctx, update.SentFrom().ID, update.Message.Chat.ID,
+					update.Message.Text, photo, msgType)
+				if err != nil {
+					logrus.Errorf("handleDishFlow: %s", err.Error())
 				}
 			}
+			return
 		}
 	}
 }
 
-func (a *Admin) sendWelcomeMessage() error {
+func (a *Admin) SendWelcomeMessage() error {
 	msg := tgbotapi.NewMessage(a.adminID, welcomeAdminMessage)
 	_, err := a.bot.Send(msg)
 	if err != nil {
@@ -263,35 +339,182 @@ func (a *Admin) sendWelcomeMessage() error {
 	return nil
 }
 
-func (a *Admin) sendCategories(ctx context.Context, chatID int64) error {
-	categories, err := a.menu.GetAllCategories(ctx)
+// handleCallback handles the buttons under the messages of the bot
+func (a *Admin) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	if query.Message == nil {
+		return answerCallback(a.bot, query.ID, "", false)
+	}
+	var (
+		userTelegramID = query.From.ID
+		chatID         = query.Message.Chat.ID
+		message        = query.Message
+		action, args   = parseCallbackData(query.Data)
+		notification   string
+		err            error
+	)
+
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	switch action {
+	case cbAdminMenu:
+		err = a.showCategories(newCtx, chatID, message)
+
+	case cbAdminCategory:
+		var category *model.Category
+		category, err = categoryByID(newCtx, a.menu, callbackID(args, 0))
+		if err != nil || category == nil {
+			break
+		}
+		state, plan := a.menuMode()
+		if plan != nil {
+			err = a.showPlanDishes(newCtx, chatID, message, plan, category.Name)
+		} else {
+			err = a.showDishes(newCtx, chatID, message, category.Name, state)
+		}
+
+	case cbAdminDish:
+		var dish *model.Dish
+		dish, err = a.menu.GetDish(newCtx, callbackID(args, 0))
+		if err != nil || dish == nil {
+			break
+		}
+		state, _ := a.menuMode()
+		if state {
+			err = a.menu.ActivateDish(newCtx, dish.ID)
+		} else {
+			err = a.menu.StopDish(newCtx, dish.ID)
+		}
+		if err != nil {
+			break
+		}
+		err = a.showDishes(newCtx, chatID, message, dish.Category, state)
+
+	case cbPlanDish:
+		_, plan := a.menuMode()
+		if plan == nil {
+			notification = planMenuNotStarted
+			break
+		}
+		var dish *model.Dish
+		dish, err = a.menu.GetDish(newCtx, callbackID(args, 0))
+		if err != nil || dish == nil {
+			break
+		}
+		err = a.togglePlanDish(newCtx, chatID, message, plan, dish)
+
+	case cbPlanDay:
+		var msgType *model.Conversation
+		msgType, err = a.msgStore.Extract(newCtx, userTelegramID)
+		if err != nil {
+			break
+		}
+		if msgType == nil || msgType.Action != storage.PlanMenu || len(args) == 0 {
+			notification = actionIsOutdated
+			break
+		}
+		err = removeButtons(a.bot, message)
+		if err != nil {
+			break
+		}
+		err = a.choosePlanMenuDay(newCtx, userTelegramID, chatID, args[0])
+
+	case cbFlowCategory, cbFlowDish, cbFlowKeep, cbFlowYes, cbFlowNo:
+		var msgType *model.Conversation
+		msgType, err = a.msgStore.Extract(newCtx, userTelegramID)
+		if err != nil {
+			break
+		}
+		if msgType == nil || msgType.Dish == nil {
+			notification = actionIsOutdated
+			break
+		}
+		if len(args) == 0 || args[0] != msgType.Action {
+			// the button is left from another step, the current step still waits for the answer
+			notification = actionIsOutdated
+			err = a.msgStore.WaitDishMessage(newCtx, userTelegramID, msgType.Action, msgType.Dish)
+			break
+		}
+		var text string
+		text, err = a.flowInput(newCtx, action, args[1:])
+		if err != nil {
+			break
+		}
+		err = removeButtons(a.bot, message)
+		if err != nil {
+			break
+		}
+		err = a.handleDishFlow(ctx, userTelegramID, chatID, text, "", msgType)
+
+	case cbAdvanceShipment:
+		var status string
+		if len(args) > 1 {
+			status = args[1]
+		}
+		notification, err = a.advanceShipment(newCtx, chatID, message, callbackID(args, 0), status)
+	}
 	if err != nil {
-		return err
+		return failCallback(a.bot, query.ID, action, err)
 	}
+	return answerCallback(a.bot, query.ID, notification, false)
+}
 
-	msg := tgbotapi.NewMessage(chatID, "Меню")
-	var buttons [][]tgbotapi.KeyboardButton
-	for _, category := range categories {
-		but := tgbotapi.NewKeyboardButton(category)
-		row := tgbotapi.NewKeyboardButtonRow(but)
-		buttons = append(buttons, row)
+// flowInput converts the pressed button to the text which admin would type at the step of the dish flow
+func (a *Admin) flowInput(ctx context.Context, action string, args []string) (string, error) {
+	switch action {
+	case cbFlowCategory:
+		category, err := categoryByID(ctx, a.menu, callbackID(args, 0))
+		if err != nil || category == nil {
+			return "", err
+		}
+		return category.Name, nil
+	case cbFlowDish:
+		dish, err := a.menu.GetDish(ctx, callbackID(args, 0))
+		if err != nil || dish == nil {
+			return "", err
+		}
+		return dish.Button(), nil
+	case cbFlowKeep:
+		return keepCurrentValue, nil
+	case cbFlowYes:
+		return confirmYes, nil
 	}
-	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
-	_, err = a.bot.Send(msg)
+	return confirmNo, nil
+}
+
+// setState switches between showing active dishes to stop them and stopped dishes to activate them,
+// planning of the menu is finished
+func (a *Admin) setState(state bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.state = state
+	a.plan = nil
+}
+
+// menuMode returns what the buttons of dishes do: the state and the day whose menu is planned
+func (a *Admin) menuMode() (bool, *model.MenuDay) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state, a.plan
+}
+
+func (a *Admin) showCategories(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	categories, err := a.menu.GetAllCategories(ctx)
 	if err != nil {
-		return fmt.Errorf("send: %w", err)
+		return err
 	}
-	return nil
+	markup := categoriesKeyboard(categories, cbAdminCategory)
+	return showMessage(a.bot, chatID, message, "Меню", &markup)
 }
 
-func (a *Admin) sendDishes(ctx context.Context, chatID int64, category string, active bool) error {
+func (a *Admin) showDishes(ctx context.Context, chatID int64, message *tgbotapi.Message, category string, active bool) error {
 	var (
 		dishes []*model.Dish
 		err    error
 	)
 	switch active {
 	case false:
-		dishes, err = a.menu.GetActiveDishesByCategory(ctx, category)
+		dishes, err = a.menu.GetActiveDishesByCategory(ctx, category, a.clock.Today())
 		if err != nil {
 			return err
 		}
@@ -302,69 +525,14 @@ func (a *Admin) sendDishes(ctx context.Context, chatID int64, category string, a
 		}
 	}
 
-	msg := tgbotapi.NewMessage(chatID, category)
-	var buttons [][]tgbotapi.KeyboardButton
+	var buttons [][]tgbotapi.InlineKeyboardButton
 	for _, dish := range dishes {
-		but := tgbotapi.NewKeyboardButton(dish.String())
-		row := tgbotapi.NewKeyboardButtonRow(but)
-		buttons = append(buttons, row)
-	}
-	but := tgbotapi.NewKeyboardButton(goBackToMenu)
-	row := tgbotapi.NewKeyboardButtonRow(but)
-	buttons = append(buttons, row)
-
-	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(buttons...)
-	_, err = a.bot.Send(msg)
-	if err != nil {
-		return fmt.Errorf("send: %w", err)
-	}
-	return nil
-}
-
-func (a *Admin) createOrganization(ctx context.Context, userTelegramID, chatID int64, message string, messageID int) error {
-	// format message: create Название организации 12:30
-	// 12:30 - lunchTime
-	if len(strings.Split(message, " ")) < 2 {
-		msg := tgbotapi.NewMessage(chatID, "Вы ввели некорректную строку. Попробуйте ещё раз")
-		_, err := a.bot.Send(msg)
-		if err != nil {
-			return fmt.Errorf("send: %w", err)
-		}
-		a.msgStore.WaitMessage(userTelegramID, storage.CreateOrganization, messageID+2, "")
-		return nil
-	}
-	organization, errHandle := a.handleCreateOrganization(message)
-	if errHandle != "" {
-		msg := tgbotapi.NewMessage(chatID, errHandle)
-		_, errSend := a.bot.Send(msg)
-		if errSend != nil {
-			return fmt.Errorf("send: %w", errSend)
-		}
-		a.msgStore.WaitMessage(userTelegramID, storage.CreateOrganization, messageID+2, "")
-		return nil
-	}
-
-	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-	err := a.org.Add(newCtx, organization)
-	if err != nil {
-		cancel()
-		return fmt.Errorf("add: %w", err)
-	}
-	cancel()
-
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(successfulOrganizationRegistered, organization.Name))
-	_, err = a.bot.Send(msg)
-	if err != nil {
-		return fmt.Errorf("send: %w", err)
-	}
-
-	msg = tgbotapi.NewMessage(chatID, organization.ID.String())
-	_, err = a.bot.Send(msg)
-	if err != nil {
-		return fmt.Errorf("send: %w", err)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(dish.String(), cbAdminDish, dish.ID)))
 	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(goBackToMenu, cbAdminMenu)))
 
-	return nil
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return showMessage(a.bot, chatID, message, category, &markup)
 }
 
 func (a *Admin) handleCreateOrganization(message string) (*model.Organization, string) {
@@ -407,46 +575,91 @@ func (a *Admin) handleCreateOrganization(message string) (*model.Organization, s
 	}, ""
 }
 
-func (a *Admin) addAddress(ctx context.Context, userTelegramID, chatID int64, messageID int, message, organizationID string) error {
-	var (
-		orgID uuid.UUID
-		err   error
-	)
-	if organizationID != "" {
-		orgID, err = uuid.Parse(organizationID)
-		if err != nil {
-			return fmt.Errorf("parse: %w", err)
-		}
-	} else {
-		orgID, err = uuid.Parse(message)
+// parseCategory understands "🥐 Выпечка", the emoji is optional
+func parseCategory(message string) *model.Category {
+	category := &model.Category{
+		Name:    strings.TrimSpace(message),
+		Visible: true,
+	}
+	fields := strings.Fields(message)
+	if len(fields) > 1 && !unicode.IsLetter([]rune(fields[0])[0]) && !unicode.IsDigit([]rune(fields[0])[0]) {
+		category.Emoji = fields[0]
+		category.Name = strings.Join(fields[1:], " ")
+	}
+	return category
+}
+
+func (a *Admin) setCategoryVisibility(ctx context.Context, chatID int64, command, category string) error {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(categoryNameRequiredFormat, command))
+		_, err := a.bot.Send(msg)
 		if err != nil {
-			logrus.Errorf("addAddress: parse: %s, message: %s", err.Error(), message)
-			return errInvalidOrganizationID
+			return fmt.Errorf("send: %w", err)
 		}
-		a.msgStore.WaitMessage(userTelegramID, storage.AddAddress, messageID+2, orgID.String())
+		return nil
+	}
 
-		msg := tgbotapi.NewMessage(chatID, addAddressStep2)
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	c, err := a.menu.GetCategory(newCtx, category)
+	if err != nil {
+		return fmt.Errorf("getCategory: %w", err)
+	}
+	if c == nil {
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(categoryNotFound, category))
 		_, err = a.bot.Send(msg)
 		if err != nil {
 			return fmt.Errorf("send: %w", err)
 		}
-
 		return nil
 	}
 
-	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-	err = a.org.UpdateAddress(newCtx, orgID, message)
+	visible := command == showCategory
+	err = a.menu.SetCategoryVisibility(newCtx, c.Name, visible)
 	if err != nil {
-		cancel()
-		return fmt.Errorf("updateAddress: %w", err)
+		return fmt.Errorf("setCategoryVisibility: %w", err)
 	}
-	cancel()
 
-	msg := tgbotapi.NewMessage(chatID, successfulAddAddress)
+	text := successfulHideCategory
+	if visible {
+		text = successfulShowCategory
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(text, c.Name))
 	_, err = a.bot.Send(msg)
 	if err != nil {
 		return fmt.Errorf("send: %w", err)
 	}
-
 	return nil
 }
+
+// setTimezone handles /timezone with arguments "organization ID [time zone]"
+func (a *Admin) setTimezone(ctx context.Context, chatID int64, arguments string) error {
+	fields := strings.Fields(arguments)
+	if len(fields) == 0 || len(fields) > 2 {
+		return a.sendText(chatID, timezoneRequiredFormat, nil)
+	}
+	orgID, err := uuid.Parse(fields[0])
+	if err != nil {
+		return a.sendText(chatID, timezoneRequiredFormat, nil)
+	}
+	var name string
+	if len(fields) == 2 {
+		name = fields[1]
+	}
+
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	err = a.org.UpdateTimezone(newCtx, orgID, name)
+	switch {
+	case errors.Is(err, service.ErrInvalidTimezone):
+		return a.sendText(chatID, fmt.Sprintf(invalidTimezone, name), nil)
+	case errors.Is(err, repository.ErrOrganizationNotFound):
+		return a.sendText(chatID, organizationNotFound, nil)
+	case err != nil:
+		return fmt.Errorf("updateTimezone: %w", err)
+	case name == "":
+		return a.sendText(chatID, successfulResetTimezone, nil)
+	}
+	return a.sendText(chatID, fmt.Sprintf(successfulTimezone, name), nil)
+}
@@ -0,0 +1,133 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	holiday      = "holiday"
+	workday      = "workday"
+	resetDay     = "reset_day"
+	calendarDays = "calendar"
+
+	// calendarPeriod is how many days ahead /calendar shows
+	calendarPeriod = 90
+)
+
+var (
+	calendarDayRequiredFormat = "Укажите дату после команды и, если нужно, ID организации, например:\n" +
+		"/%s 01.01.2027\n/%s 01.01.2027 ID_организации\n\n" +
+		"Без ID день действует для всех организаций"
+	calendarDayInPast       = "Эта дата уже прошла"
+	organizationNotFound    = "Организация с таким ID не найдена"
+	successfulHoliday       = "%s - выходной %s"
+	successfulWorkday       = "%s - рабочий день %s"
+	successfulResetDay      = "%s - обычный день %s: пн - пт рабочие, сб и вс выходные"
+	forAllOrganizations     = "для всех организаций"
+	forOrganization         = "для организации %s"
+	emptyCalendar           = "В ближайшие дни нет праздников и перенесённых рабочих дней"
+	calendarHeader          = "Праздники и перенесённые рабочие дни:\n"
+	calendarOrganizationDay = "%s - %s (организация %s)\n"
+	calendarCommonDay       = "%s - %s\n"
+)
+
+// setCalendarDay handles /holiday, /workday and /reset_day with arguments "date [organization ID]"
+func (a *Admin) setCalendarDay(ctx context.Context, chatID int64, command, arguments string) error {
+	day, ok := parseCalendarDay(arguments, a.clock.Today())
+	if !ok {
+		return a.sendText(chatID, fmt.Sprintf(calendarDayRequiredFormat, command, command), nil)
+	}
+	if day.Date.Before(a.clock.Today()) {
+		return a.sendText(chatID, calendarDayInPast, nil)
+	}
+
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var (
+		err  error
+		text string
+	)
+	switch command {
+	case holiday:
+		err = a.calendar.SetDay(newCtx, day)
+		text = successfulHoliday
+	case workday:
+		day.Working = true
+		err = a.calendar.SetDay(newCtx, day)
+		text = successfulWorkday
+	case resetDay:
+		err = a.calendar.DeleteDay(newCtx, day.Date, day.OrganizationID)
+		text = successfulResetDay
+	}
+	if errors.Is(err, repository.ErrOrganizationNotFound) {
+		return a.sendText(chatID, organizationNotFound, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+
+	whom := forAllOrganizations
+	if day.OrganizationID != uuid.Nil {
+		whom = fmt.Sprintf(forOrganization, day.OrganizationID)
+	}
+	return a.sendText(chatID, fmt.Sprintf(text, day.Date.Format("02.01.2006"), whom), nil)
+}
+
+// showCalendar sends holidays and extra working days of the next days
+func (a *Admin) showCalendar(ctx context.Context, chatID int64) error {
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	today := a.clock.Today()
+	days, err := a.calendar.GetDays(newCtx, today, today.AddDate(0, 0, calendarPeriod))
+	if err != nil {
+		return fmt.Errorf("getDays: %w", err)
+	}
+	if len(days) == 0 {
+		return a.sendText(chatID, emptyCalendar, nil)
+	}
+
+	text := calendarHeader
+	for _, day := range days {
+		kind := "выходной"
+		if day.Working {
+			kind = "рабочий день"
+		}
+		date := fmt.Sprintf("%s, %s", weekdayNames[day.Date.Weekday()], day.Date.Format("02.01.2006"))
+		if day.OrganizationID != uuid.Nil {
+			text += fmt.Sprintf(calendarOrganizationDay, date, kind, day.OrganizationID)
+			continue
+		}
+		text += fmt.Sprintf(calendarCommonDay, date, kind)
+	}
+	return a.sendText(chatID, text, nil)
+}
+
+// parseCalendarDay understands "date [organization ID]", the date is the same as for the menu plan, but not a weekday
+func parseCalendarDay(arguments string, today time.Time) (*model.CalendarDay, bool) {
+	fields := strings.Fields(arguments)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, false
+	}
+	menuDay, ok := parseMenuDay(fields[0], today)
+	if !ok || menuDay.Weekly {
+		return nil, false
+	}
+	day := &model.CalendarDay{Date: menuDay.Date}
+	if len(fields) == 2 {
+		orgID, err := uuid.Parse(fields[1])
+		if err != nil {
+			return nil, false
+		}
+		day.OrganizationID = orgID
+	}
+	return day, true
+}
@@ -0,0 +1,106 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const deliveries = "deliveries"
+
+var (
+	deliveriesHeader      = "Доставки на сегодня:\n\n"
+	deliveryLine          = "%d:%02d %s - %s\n"
+	deliveryLineWithZone  = "%d:%02d (%s) %s - %s\n"
+	emptyDeliveries       = "Сегодня заказы ещё не отправлялись на кухню"
+	advanceDeliveryButton = "%s → %s"
+	successfulAdvance     = "Статус изменён: %s"
+	lunchIsOutForDelivery = "🚗 Ваш обед выехал из кафе и скоро будет у вас"
+	lunchIsDelivered      = "✅ Ваш обед доставлен. Приятного аппетита!"
+	orderStatusNames      = map[string]string{
+		model.OrderDraft:          "черновик",
+		model.OrderConfirmed:      "подтверждён",
+		model.OrderSentToKitchen:  "передан на кухню",
+		model.OrderCooking:        "готовится",
+		model.OrderOutForDelivery: "в пути",
+		model.OrderDelivered:      "доставлен",
+		model.OrderCancelled:      "отменён",
+	}
+	customerNotifications = map[string]string{
+		model.OrderOutForDelivery: lunchIsOutForDelivery,
+		model.OrderDelivered:      lunchIsDelivered,
+	}
+)
+
+// showDeliveries shows today's shipments of organizations with buttons to move their orders to the next status.
+// Today is local for every organization. If the message is nil the new one is sent
+func (a *Admin) showDeliveries(ctx context.Context, chatID int64, message *tgbotapi.Message) error {
+	shipments, err := a.shipments.GetToday(ctx, a.clock.Now())
+	if err != nil {
+		return fmt.Errorf("getToday: %w", err)
+	}
+	if len(shipments) == 0 {
+		return showMessage(a.bot, chatID, message, emptyDeliveries, nil)
+	}
+
+	text := deliveriesHeader
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, sh := range shipments {
+		hour, minute := int(sh.LunchTime.Hours()), int(sh.LunchTime.Minutes())%60
+		status := orderStatusNames[sh.OrderStatus]
+		if sh.Timezone != "" {
+			text += fmt.Sprintf(deliveryLineWithZone, hour, minute, sh.Timezone, sh.OrganizationName, status)
+		} else {
+			text += fmt.Sprintf(deliveryLine, hour, minute, sh.OrganizationName, status)
+		}
+		next := model.NextOrderStatus(sh.OrderStatus)
+		if next == "" {
+			continue
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(
+			fmt.Sprintf(advanceDeliveryButton, sh.OrganizationName, orderStatusNames[next]),
+			cbAdvanceShipment, sh.ID, sh.OrderStatus)))
+	}
+	if len(buttons) == 0 {
+		return showMessage(a.bot, chatID, message, text, nil)
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return showMessage(a.bot, chatID, message, text, &markup)
+}
+
+// advanceShipment moves orders of the shipment from the status to the next one and notifies customers when their
+// lunch leaves the cafe and when it's delivered. It returns the notification for the admin
+func (a *Admin) advanceShipment(ctx context.Context, chatID int64, message *tgbotapi.Message, id int,
+	from string) (string, error) {
+	customers, err := a.shipments.Advance(ctx, id, from, a.clock.Now())
+	if errors.Is(err, service.ErrInvalidOrderStatus) || err == nil && len(customers) == 0 {
+		return actionIsOutdated, a.showDeliveries(ctx, chatID, message)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	to := model.NextOrderStatus(from)
+	notifyCustomers(a.bot, customers, customerNotifications[to])
+	return fmt.Sprintf(successfulAdvance, orderStatusNames[to]), a.showDeliveries(ctx, chatID, message)
+}
+
+// notifyCustomers sends the text to customers, it does nothing if the text is empty. Customers who can't
+// be notified are logged
+func notifyCustomers(bot messenger.Messenger, customers []*model.TelegramUser, text string) {
+	if text == "" {
+		return
+	}
+	for _, customer := range customers {
+		_, err := bot.Send(tgbotapi.NewMessage(customer.ChatID, text))
+		if err != nil {
+			logrus.Errorf("notifyCustomers: %d: %s", customer.ID, err.Error())
+		}
+	}
+}
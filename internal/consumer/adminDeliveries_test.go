@@ -0,0 +1,97 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+)
+
+// fakeShipments keeps orders of one shipment, they are moved only from their current status
+type fakeShipments struct {
+	repository.Shipment
+	shipment  model.Shipment
+	customers []*model.TelegramUser
+}
+
+func (f *fakeShipments) GetToday(_ context.Context, _ time.Time) ([]*model.Shipment, error) {
+	sh := f.shipment
+	return []*model.Shipment{&sh}, nil
+}
+
+func (f *fakeShipments) SetOrderStatus(_ context.Context, id int, from, to string,
+	_ time.Time) ([]*model.TelegramUser, error) {
+	if id != f.shipment.ID || from != f.shipment.OrderStatus {
+		return nil, nil
+	}
+	f.shipment.OrderStatus = to
+	return f.customers, nil
+}
+
+func TestAdvanceShipment(t *testing.T) {
+	const (
+		adminChat    = 1
+		customerChat = 2
+	)
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 10, 12, 12, 0, 0, 0, time.UTC))
+	bot := messenger.NewFake(clk)
+	repo := &fakeShipments{
+		shipment: model.Shipment{
+			ID:           7,
+			OrderingData: &model.OrderingData{OrganizationName: "Рога и копыта", LunchTime: 13 * time.Hour},
+			OrderStatus:  model.OrderCooking,
+		},
+		customers: []*model.TelegramUser{{ID: 20, ChatID: customerChat}},
+	}
+	a := &Admin{bot: bot, shipments: service.NewShipment(repo, nil, nil, nil), clock: clk}
+
+	if err := a.showDeliveries(ctx, adminChat, nil); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, _ := bot.LastMessage(adminChat)
+
+	notification, err := a.advanceShipment(ctx, adminChat, deliveries.Telegram(), 7, model.OrderCooking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Статус изменён: в пути"; notification != want {
+		t.Errorf("got notification %q, want %q", notification, want)
+	}
+	if got := bot.Messages(customerChat); len(got) != 1 || got[0].Text != lunchIsOutForDelivery {
+		t.Errorf("customer got %v, want the message that lunch is out for delivery", got)
+	}
+	deliveries, _ = bot.LastMessage(adminChat)
+	if _, ok := deliveries.Button("доставлен"); !ok {
+		t.Errorf("deliveries %q don't have the button to deliver orders", deliveries.Text)
+	}
+
+	// the button of the old message of deliveries is pressed again
+	notification, err = a.advanceShipment(ctx, adminChat, deliveries.Telegram(), 7, model.OrderCooking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notification != actionIsOutdated {
+		t.Errorf("got notification %q for the stale status, want %q", notification, actionIsOutdated)
+	}
+	if got := bot.Messages(customerChat); len(got) != 1 {
+		t.Errorf("customer got %d messages, want the only one", len(got))
+	}
+	if repo.shipment.OrderStatus != model.OrderOutForDelivery {
+		t.Errorf("orders are %s, want %s", repo.shipment.OrderStatus, model.OrderOutForDelivery)
+	}
+
+	// delivered orders can't be moved
+	notification, err = a.advanceShipment(ctx, adminChat, deliveries.Telegram(), 7, model.OrderDelivered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notification != actionIsOutdated {
+		t.Errorf("got notification %q for delivered orders, want %q", notification, actionIsOutdated)
+	}
+}
@@ -0,0 +1,443 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/chucky-1/food-delivery-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	keepCurrentValue   = "-"
+	removeCurrentValue = "удалить"
+	confirmYes         = "Да"
+	confirmNo          = "Нет"
+)
+
+var (
+	chooseDishCategory         = "Выберите категорию"
+	chooseNewDishCategory      = "Выберите новую категорию или оставьте «%s»"
+	keepCurrentCategory        = "Оставить текущую"
+	chooseDish                 = "Выберите блюдо"
+	noDishesInCategory         = "В категории «%s» пока нет блюд"
+	inputDishName              = "Введите название блюда"
+	inputNewDishName           = "Введите новое название или «-», чтобы оставить «%s»"
+	inputDishPrice             = "Введите цену блюда\n\nПример:\n12.50"
+	inputNewDishPrice          = "Введите новую цену или «-», чтобы оставить %.2f"
+	inputDishDescription       = "Введите описание блюда или «-», если описание не нужно"
+	inputNewDishDescription    = "Введите новое описание, «-», чтобы оставить текущее, или «удалить»"
+	inputDishPhoto             = "Отправьте фото блюда или «-», если фото не нужно"
+	inputNewDishPhoto          = "Отправьте новое фото, «-», чтобы оставить текущее, или «удалить»"
+	inputDishWeight            = "Введите вес порции в граммах или «-», если не нужно"
+	inputNewDishWeight         = "Введите новый вес порции в граммах или «-», чтобы оставить текущий (%d г). 0 - не показывать вес"
+	inputDishCalories          = "Введите калорийность порции в ккал или «-», если не нужно"
+	inputNewDishCalories       = "Введите новую калорийность в ккал или «-», чтобы оставить текущую (%d ккал). 0 - не показывать калорийность"
+	inputDishAllergens         = "Перечислите аллергены через запятую или «-», если их нет\n\nПример:\nглютен, молоко, орехи"
+	inputNewDishAllergens      = "Перечислите аллергены через запятую, «-», чтобы оставить текущие, или «удалить»"
+	invalidDishPhoto           = "Отправьте фото, «-» или «удалить»"
+	invalidDishWeight          = "Вес должен быть целым числом грамм, например 250. Попробуйте ещё раз"
+	invalidDishCalories        = "Калорийность должна быть целым числом, например 320. Попробуйте ещё раз"
+	invalidDishAllergens       = "Не получилось сохранить аллергены. Перечислите их через запятую, не больше 20. Попробуйте ещё раз"
+	confirmDeleteDish          = "Удалить блюдо «%s»? Блюдо пропадёт из меню, но останется в истории заказов"
+	invalidDishCategory        = "Такой категории нет. Выберите категорию с клавиатуры"
+	invalidDishChoice          = "Такого блюда нет. Выберите блюдо с клавиатуры"
+	invalidDishName            = "Вы ввели некорректное название блюда. Попробуйте ещё раз"
+	invalidDishPrice           = "Вы ввели некорректную цену. Цена должна быть числом больше нуля, например 12.50. Попробуйте ещё раз"
+	invalidDishDescription     = "Описание слишком длинное. Попробуйте ещё раз"
+	dishAlreadyExists          = "Блюдо с таким названием уже есть в этой категории. Введите другое название"
+	successfulAddDish          = "Блюдо «%s» добавлено в категорию «%s»"
+	successfulEditDish         = "Блюдо «%s» сохранено"
+	successfulDeleteDish       = "Блюдо «%s» удалено из меню"
+	deleteDishCancelled        = "Удаление отменено"
+	dishHasBeenAlreadyRemoved  = "Блюдо уже удалено"
+	categoryHasBeenJustRemoved = "Категория больше не существует. Начните заново"
+)
+
+func (a *Admin) startDishFlow(ctx context.Context, userTelegramID, chatID int64, action string) error {
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	categories, err := a.menu.GetAllCategories(newCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("getAllCategories: %w", err)
+	}
+
+	err = a.sendText(chatID, chooseDishCategory, categoriesKeyboard(categories, flowAction(cbFlowCategory, action)))
+	if err != nil {
+		return err
+	}
+	return a.msgStore.WaitDishMessage(ctx, userTelegramID, action, &model.Dish{})
+}
+
+// handleDishFlow handles every step of /add_dish, /edit_dish and /delete_dish. The text is either typed by admin
+// or comes from the pressed button, the photo is file_id of the sent photo
+func (a *Admin) handleDishFlow(ctx context.Context, userTelegramID, chatID int64, text, photo string,
+	msgType *model.Conversation) error {
+	if msgType.Dish == nil {
+		return nil
+	}
+	text = strings.TrimSpace(text)
+	dish := msgType.Dish
+
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	switch msgType.Action {
+	case storage.AddDish:
+		category, err := a.menu.GetCategory(newCtx, text)
+		if err != nil {
+			return fmt.Errorf("getCategory: %w", err)
+		}
+		if category == nil {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishCategory)
+		}
+		dish.Category = category.Name
+		return a.askNext(ctx, userTelegramID, chatID, storage.AddDishName, dish, inputDishName, nil)
+
+	case storage.AddDishName:
+		if text == "" {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishName)
+		}
+		dish.Name = text
+		return a.askNext(ctx, userTelegramID, chatID, storage.AddDishPrice, dish, inputDishPrice, nil)
+
+	case storage.AddDishPrice:
+		price, ok := parsePrice(text)
+		if !ok {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishPrice)
+		}
+		dish.Price = price
+		return a.askNext(ctx, userTelegramID, chatID, storage.AddDishDescription, dish, inputDishDescription, nil)
+
+	case storage.AddDishDescription:
+		if text != keepCurrentValue {
+			dish.Description = text
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.AddDishPhoto, dish, inputDishPhoto, nil)
+
+	case storage.AddDishPhoto:
+		photo, ok := photoFromInput(text, photo)
+		if !ok && text != keepCurrentValue {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishPhoto)
+		}
+		dish.Photo = photo
+		return a.askNext(ctx, userTelegramID, chatID, storage.AddDishWeight, dish, inputDishWeight, nil)
+
+	case storage.AddDishWeight:
+		if text != keepCurrentValue {
+			weight, err := strconv.Atoi(text)
+			if err != nil || weight < 0 {
+				return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishWeight)
+			}
+			dish.Weight = weight
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.AddDishCalories, dish, inputDishCalories, nil)
+
+	case storage.AddDishCalories:
+		if text != keepCurrentValue {
+			calories, err := strconv.Atoi(text)
+			if err != nil || calories < 0 {
+				return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishCalories)
+			}
+			dish.Calories = calories
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.AddDishAllergens, dish, inputDishAllergens, nil)
+
+	case storage.AddDishAllergens:
+		if text != keepCurrentValue {
+			dish.Allergens = parseAllergens(text)
+		}
+		err := a.menu.AddDish(newCtx, dish)
+		if err != nil {
+			return a.handleDishError(ctx, userTelegramID, chatID, dish, err, false)
+		}
+		return a.sendText(chatID, fmt.Sprintf(successfulAddDish, dish.String(), dish.Category), nil)
+
+	case storage.EditDish, storage.DeleteDish:
+		category, err := a.menu.GetCategory(newCtx, text)
+		if err != nil {
+			return fmt.Errorf("getCategory: %w", err)
+		}
+		if category == nil {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishCategory)
+		}
+		dishes, err := a.menu.GetDishesByCategory(newCtx, category.Name)
+		if err != nil {
+			return fmt.Errorf("getDishesByCategory: %w", err)
+		}
+		if len(dishes) == 0 {
+			return a.sendText(chatID, fmt.Sprintf(noDishesInCategory, category.Name), nil)
+		}
+		next := storage.EditDishSelect
+		if msgType.Action == storage.DeleteDish {
+			next = storage.DeleteDishSelect
+		}
+		return a.askNext(ctx, userTelegramID, chatID, next, dish, chooseDish, dishesKeyboard(dishes, next))
+
+	case storage.EditDishSelect:
+		selected, err := dishFromButton(newCtx, a.menu, text)
+		if err != nil {
+			return fmt.Errorf("dishFromButton: %w", err)
+		}
+		if selected == nil {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishChoice)
+		}
+		// copy the dish, so the cached menu isn't changed before the dish is saved
+		edited := *selected
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishName, &edited,
+			fmt.Sprintf(inputNewDishName, edited.Name), nil)
+
+	case storage.EditDishName:
+		if text == "" {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishName)
+		}
+		if text != keepCurrentValue {
+			dish.Name = text
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishPrice, dish,
+			fmt.Sprintf(inputNewDishPrice, dish.Price), nil)
+
+	case storage.EditDishPrice:
+		if text != keepCurrentValue {
+			price, ok := parsePrice(text)
+			if !ok {
+				return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishPrice)
+			}
+			dish.Price = price
+		}
+		categories, err := a.menu.GetAllCategories(newCtx)
+		if err != nil {
+			return fmt.Errorf("getAllCategories: %w", err)
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishCategory, dish,
+			fmt.Sprintf(chooseNewDishCategory, dish.Category),
+			categoriesKeyboard(categories, flowAction(cbFlowCategory, storage.EditDishCategory),
+				tgbotapi.NewInlineKeyboardRow(inlineButton(keepCurrentCategory, cbFlowKeep, storage.EditDishCategory))))
+
+	case storage.EditDishCategory:
+		if text != keepCurrentValue {
+			category, err := a.menu.GetCategory(newCtx, text)
+			if err != nil {
+				return fmt.Errorf("getCategory: %w", err)
+			}
+			if category == nil {
+				return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishCategory)
+			}
+			dish.Category = category.Name
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishDescription, dish, inputNewDishDescription, nil)
+
+	case storage.EditDishDescription:
+		switch strings.ToLower(text) {
+		case keepCurrentValue:
+		case removeCurrentValue:
+			dish.Description = ""
+		default:
+			dish.Description = text
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishPhoto, dish, inputNewDishPhoto, nil)
+
+	case storage.EditDishPhoto:
+		photo, ok := photoFromInput(text, photo)
+		switch {
+		case ok:
+			dish.Photo = photo
+		case strings.ToLower(text) == removeCurrentValue:
+			dish.Photo = ""
+		case text != keepCurrentValue:
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishPhoto)
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishWeight, dish,
+			fmt.Sprintf(inputNewDishWeight, dish.Weight), nil)
+
+	case storage.EditDishWeight:
+		if text != keepCurrentValue {
+			weight, err := strconv.Atoi(text)
+			if err != nil || weight < 0 {
+				return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishWeight)
+			}
+			dish.Weight = weight
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishCalories, dish,
+			fmt.Sprintf(inputNewDishCalories, dish.Calories), nil)
+
+	case storage.EditDishCalories:
+		if text != keepCurrentValue {
+			calories, err := strconv.Atoi(text)
+			if err != nil || calories < 0 {
+				return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishCalories)
+			}
+			dish.Calories = calories
+		}
+		return a.askNext(ctx, userTelegramID, chatID, storage.EditDishAllergens, dish, inputNewDishAllergens, nil)
+
+	case storage.EditDishAllergens:
+		switch strings.ToLower(text) {
+		case keepCurrentValue:
+		case removeCurrentValue:
+			dish.Allergens = nil
+		default:
+			dish.Allergens = parseAllergens(text)
+		}
+		err := a.menu.UpdateDish(newCtx, dish)
+		if err != nil {
+			return a.handleDishError(ctx, userTelegramID, chatID, dish, err, true)
+		}
+		return a.sendText(chatID, fmt.Sprintf(successfulEditDish, dish.String()), nil)
+
+	case storage.DeleteDishSelect:
+		selected, err := dishFromButton(newCtx, a.menu, text)
+		if err != nil {
+			return fmt.Errorf("dishFromButton: %w", err)
+		}
+		if selected == nil {
+			return a.askAgain(ctx, userTelegramID, chatID, msgType, invalidDishChoice)
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			inlineButton(confirmYes, cbFlowYes, storage.DeleteDishConfirm),
+			inlineButton(confirmNo, cbFlowNo, storage.DeleteDishConfirm),
+		))
+		return a.askNext(ctx, userTelegramID, chatID, storage.DeleteDishConfirm, selected,
+			fmt.Sprintf(confirmDeleteDish, selected.String()), keyboard)
+
+	case storage.DeleteDishConfirm:
+		if text != confirmYes {
+			return a.sendText(chatID, deleteDishCancelled, nil)
+		}
+		err := a.menu.DeleteDish(newCtx, dish.ID)
+		if err != nil {
+			if errors.Is(err, repository.ErrDishNotFound) {
+				return a.sendText(chatID, dishHasBeenAlreadyRemoved, nil)
+			}
+			return fmt.Errorf("deleteDish: %w", err)
+		}
+		return a.sendText(chatID, fmt.Sprintf(successfulDeleteDish, dish.String()), nil)
+	}
+	return nil
+}
+
+// handleDishError returns admin to the step where the invalid value has been entered
+func (a *Admin) handleDishError(ctx context.Context, userTelegramID, chatID int64, dish *model.Dish, err error, edit bool) error {
+	step := func(add, edited string) string {
+		if edit {
+			return edited
+		}
+		return add
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidDishName):
+		return a.askNext(ctx, userTelegramID, chatID, step(storage.AddDishName, storage.EditDishName), dish,
+			invalidDishName, nil)
+	case errors.Is(err, repository.ErrDishAlreadyExists):
+		return a.askNext(ctx, userTelegramID, chatID, step(storage.AddDishName, storage.EditDishName), dish,
+			dishAlreadyExists, nil)
+	case errors.Is(err, service.ErrInvalidDishPrice):
+		return a.askNext(ctx, userTelegramID, chatID, step(storage.AddDishPrice, storage.EditDishPrice), dish,
+			invalidDishPrice, nil)
+	case errors.Is(err, service.ErrInvalidDescription):
+		return a.askNext(ctx, userTelegramID, chatID, step(storage.AddDishDescription, storage.EditDishDescription),
+			dish, invalidDishDescription, nil)
+	case errors.Is(err, service.ErrInvalidDishWeight):
+		return a.askNext(ctx, userTelegramID, chatID, step(storage.AddDishWeight, storage.EditDishWeight), dish,
+			invalidDishWeight, nil)
+	case errors.Is(err, service.ErrInvalidDishCalories):
+		return a.askNext(ctx, userTelegramID, chatID, step(storage.AddDishCalories, storage.EditDishCalories), dish,
+			invalidDishCalories, nil)
+	case errors.Is(err, service.ErrInvalidAllergens):
+		return a.askNext(ctx, userTelegramID, chatID, step(storage.AddDishAllergens, storage.EditDishAllergens), dish,
+			invalidDishAllergens, nil)
+	case errors.Is(err, repository.ErrCategoryNotFound):
+		return a.sendText(chatID, categoryHasBeenJustRemoved, nil)
+	case errors.Is(err, repository.ErrDishNotFound):
+		return a.sendText(chatID, dishHasBeenAlreadyRemoved, nil)
+	}
+	return err
+}
+
+func (a *Admin) askNext(ctx context.Context, userTelegramID, chatID int64, action string, dish *model.Dish, text string,
+	markup interface{}) error {
+	err := a.sendText(chatID, text, markup)
+	if err != nil {
+		return err
+	}
+	return a.msgStore.WaitDishMessage(ctx, userTelegramID, action, dish)
+}
+
+func (a *Admin) askAgain(ctx context.Context, userTelegramID, chatID int64, msgType *model.Conversation, text string) error {
+	return a.askNext(ctx, userTelegramID, chatID, msgType.Action, msgType.Dish, text, nil)
+}
+
+func (a *Admin) sendText(chatID int64, text string, markup interface{}) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	if markup != nil {
+		msg.ReplyMarkup = markup
+	}
+	_, err := a.bot.Send(msg)
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+func categoriesKeyboard(categories []*model.Category, action string,
+	extra ...[]tgbotapi.InlineKeyboardButton) tgbotapi.InlineKeyboardMarkup {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, category := range categories {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(category.String(), action, category.ID)))
+	}
+	buttons = append(buttons, extra...)
+	return tgbotapi.NewInlineKeyboardMarkup(buttons...)
+}
+
+// dishesKeyboard returns buttons to choose the dish at the step of the dish flow
+func dishesKeyboard(dishes []*model.Dish, step string) tgbotapi.InlineKeyboardMarkup {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, dish := range dishes {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(dish.String(), cbFlowDish, step, dish.ID)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(buttons...)
+}
+
+// flowAction returns the action of buttons which are sent at the step of the dish flow. The step is the first
+// argument of the callback, so the button of the previous step isn't taken as the answer to the current one
+func flowAction(action, step string) string {
+	return callbackData(action, step)
+}
+
+// parsePrice accepts both "12.50" and "12,50"
+func parsePrice(text string) (float32, bool) {
+	price, err := strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 32)
+	if err != nil || price <= 0 {
+		return 0, false
+	}
+	return float32(price), true
+}
+
+// photoFromInput returns file_id of the sent photo or the text if it looks like file_id or URL
+func photoFromInput(text, photo string) (string, bool) {
+	if photo != "" {
+		return photo, true
+	}
+	if text == "" || text == keepCurrentValue || strings.ToLower(text) == removeCurrentValue || strings.ContainsAny(text, " \n") {
+		return "", false
+	}
+	return text, true
+}
+
+func parseAllergens(text string) []string {
+	var allergens []string
+	for _, allergen := range strings.Split(text, ",") {
+		allergen = strings.ToLower(strings.TrimSpace(allergen))
+		if allergen != "" {
+			allergens = append(allergens, allergen)
+		}
+	}
+	return allergens
+}
@@ -0,0 +1,77 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
+)
+
+const (
+	listJobs = "jobs"
+	runJob   = "run_job"
+
+	// jobTimeFormat is how instants of jobs are shown to the admin
+	jobTimeFormat = "02.01 15:04"
+)
+
+var (
+	jobsHeader         = "Задачи по расписанию:\n\n"
+	jobDescription     = "%s\nРасписание: %s\nПоследний запуск: %s\nСледующий запуск: %s\n"
+	jobLastError       = "Ошибка: %s\n"
+	jobNeverRun        = "не было"
+	jobNameRequired    = "Укажите название задачи после команды, например:\n/run_job statisticsSender\n\nСписок задач: /jobs"
+	unknownJob         = "Задачи «%s» нет. Список задач: /jobs"
+	jobIsRunning       = "Задача «%s» уже выполняется, попробуйте позже"
+	jobFailed          = "Задача «%s» завершилась с ошибкой: %s"
+	successfulJobRun   = "Задача «%s» выполнена"
+	schedulerIsMissing = "Задачи по расписанию не запущены"
+)
+
+// showJobs handles /jobs
+func (a *Admin) showJobs(chatID int64) error {
+	if a.jobs == nil {
+		return a.sendText(chatID, schedulerIsMissing, nil)
+	}
+	text := jobsHeader
+	for _, job := range a.jobs.Jobs() {
+		lastRun := jobNeverRun
+		if !job.LastRunAt.IsZero() {
+			lastRun = job.LastRunAt.In(a.clock.Location()).Format(jobTimeFormat)
+		}
+		text += fmt.Sprintf(jobDescription, job.Name, job.Schedule, lastRun,
+			job.NextRunAt.In(a.clock.Location()).Format(jobTimeFormat))
+		if job.LastError != nil {
+			text += fmt.Sprintf(jobLastError, job.LastError.Error())
+		}
+		text += "\n"
+	}
+	return a.sendText(chatID, text, nil)
+}
+
+// runJob handles /run_job with the name of the job, the job runs now out of its schedule
+func (a *Admin) runJob(ctx context.Context, chatID int64, arguments string) error {
+	if a.jobs == nil {
+		return a.sendText(chatID, schedulerIsMissing, nil)
+	}
+	name := strings.TrimSpace(arguments)
+	if name == "" {
+		return a.sendText(chatID, jobNameRequired, nil)
+	}
+
+	newCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	err := a.jobs.Trigger(newCtx, name)
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		return a.sendText(chatID, fmt.Sprintf(unknownJob, name), nil)
+	case errors.Is(err, scheduler.ErrJobRunning):
+		return a.sendText(chatID, fmt.Sprintf(jobIsRunning, name), nil)
+	case err != nil:
+		return a.sendText(chatID, fmt.Sprintf(jobFailed, name, err.Error()), nil)
+	}
+	return a.sendText(chatID, fmt.Sprintf(successfulJobRun, name), nil)
+}
@@ -0,0 +1,211 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	finishPlan = "finish_plan"
+	clearPlan  = "clear_plan"
+
+	plannedDishPrefix    = "✅ "
+	notPlannedDishPrefix = "➖ "
+)
+
+var (
+	planMenu = "Для какого дня подготовить меню?\n\n" +
+		"Введите дату в формате 20.10.2026, «Сегодня», «Завтра» или день недели (Пн, Вт, Ср, Чт, Пт, Сб, Вс), " +
+		"если меню повторяется каждую неделю"
+	planMenuStarted = "Меню на %s\n\n" +
+		"Выберите категорию и отметьте блюда, которые будут в меню. ✅ - блюдо в меню\n\n" +
+		"Меню на конкретную дату важнее меню на день недели. Если на день ничего не выбрано, клиенты увидят всё меню\n\n" +
+		"/finish_plan - завершить\n" +
+		"/clear_plan - убрать все блюда из меню на этот день"
+	invalidMenuDay       = "Не получилось распознать день. Попробуйте ещё раз, например: 20.10.2026, Завтра или Пн"
+	menuDayInPast        = "Эта дата уже прошла. Попробуйте ещё раз"
+	planMenuFinished     = "Меню на %s сохранено. Блюд в меню: %d"
+	planMenuFinishedFull = "На %s не выбрано ни одного блюда, клиенты увидят всё меню"
+	planMenuCleared      = "Меню на %s очищено"
+	planMenuNotStarted   = "Сначала выберите день: /plan_menu"
+)
+
+var weekdaysByName = map[string]time.Weekday{
+	"пн": time.Monday, "понедельник": time.Monday,
+	"вт": time.Tuesday, "вторник": time.Tuesday,
+	"ср": time.Wednesday, "среда": time.Wednesday,
+	"чт": time.Thursday, "четверг": time.Thursday,
+	"пт": time.Friday, "пятница": time.Friday,
+	"сб": time.Saturday, "суббота": time.Saturday,
+	"вс": time.Sunday, "воскресенье": time.Sunday,
+}
+
+func (a *Admin) startPlanMenu(ctx context.Context, userTelegramID, chatID int64) error {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(inlineButton("Сегодня", cbPlanDay, "сегодня"), inlineButton("Завтра", cbPlanDay, "завтра")),
+		tgbotapi.NewInlineKeyboardRow(
+			inlineButton("Пн", cbPlanDay, "пн"),
+			inlineButton("Вт", cbPlanDay, "вт"),
+			inlineButton("Ср", cbPlanDay, "ср"),
+			inlineButton("Чт", cbPlanDay, "чт"),
+			inlineButton("Пт", cbPlanDay, "пт"),
+		),
+	)
+	err := a.sendText(chatID, planMenu, keyboard)
+	if err != nil {
+		return err
+	}
+	return a.msgStore.WaitMessage(ctx, userTelegramID, storage.PlanMenu, "")
+}
+
+func (a *Admin) choosePlanMenuDay(ctx context.Context, userTelegramID, chatID int64, message string) error {
+	today := a.clock.Today()
+	day, ok := parseMenuDay(message, today)
+	if !ok {
+		err := a.sendText(chatID, invalidMenuDay, nil)
+		if err != nil {
+			return err
+		}
+		return a.msgStore.WaitMessage(ctx, userTelegramID, storage.PlanMenu, "")
+	}
+	if !day.Weekly && day.Date.Before(today) {
+		err := a.sendText(chatID, menuDayInPast, nil)
+		if err != nil {
+			return err
+		}
+		return a.msgStore.WaitMessage(ctx, userTelegramID, storage.PlanMenu, "")
+	}
+	a.setPlan(day)
+
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	categories, err := a.menu.GetAllCategories(newCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("getAllCategories: %w", err)
+	}
+	return a.sendText(chatID, fmt.Sprintf(planMenuStarted, day.String()), categoriesKeyboard(categories, cbAdminCategory))
+}
+
+// setPlan starts planning the menu of the day, nil stops planning
+func (a *Admin) setPlan(day *model.MenuDay) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.plan = day
+}
+
+// takePlan stops planning and returns the day whose menu has been planned
+func (a *Admin) takePlan() *model.MenuDay {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	day := a.plan
+	a.plan = nil
+	return day
+}
+
+// showPlanDishes shows all dishes of the category marking the ones which are on the planned menu
+func (a *Admin) showPlanDishes(ctx context.Context, chatID int64, message *tgbotapi.Message, plan *model.MenuDay,
+	category string) error {
+	dishes, err := a.menu.GetDishesByCategory(ctx, category)
+	if err != nil {
+		return fmt.Errorf("getDishesByCategory: %w", err)
+	}
+	planned, err := a.menu.GetMenuDay(ctx, plan)
+	if err != nil {
+		return fmt.Errorf("getMenuDay: %w", err)
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, dish := range dishes {
+		prefix := notPlannedDishPrefix
+		if planned[dish.ID] {
+			prefix = plannedDishPrefix
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(prefix+dish.String(), cbPlanDish, dish.ID)))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(goBackToMenu, cbAdminMenu)))
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return showMessage(a.bot, chatID, message, fmt.Sprintf("%s: %s", plan.String(), category), &markup)
+}
+
+func (a *Admin) togglePlanDish(ctx context.Context, chatID int64, message *tgbotapi.Message, plan *model.MenuDay,
+	dish *model.Dish) error {
+	planned, err := a.menu.GetMenuDay(ctx, plan)
+	if err != nil {
+		return fmt.Errorf("getMenuDay: %w", err)
+	}
+	if planned[dish.ID] {
+		err = a.menu.RemoveDishFromMenuDay(ctx, plan, dish.ID)
+		if err != nil {
+			return fmt.Errorf("removeDishFromMenuDay: %w", err)
+		}
+	} else {
+		err = a.menu.AddDishToMenuDay(ctx, plan, dish.ID)
+		if err != nil {
+			return fmt.Errorf("addDishToMenuDay: %w", err)
+		}
+	}
+	return a.showPlanDishes(ctx, chatID, message, plan, dish.Category)
+}
+
+func (a *Admin) finishPlanMenu(ctx context.Context, chatID int64) error {
+	day := a.takePlan()
+	if day == nil {
+		return a.sendText(chatID, planMenuNotStarted, nil)
+	}
+
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	planned, err := a.menu.GetMenuDay(newCtx, day)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("getMenuDay: %w", err)
+	}
+	if len(planned) == 0 {
+		return a.sendText(chatID, fmt.Sprintf(planMenuFinishedFull, day.String()), nil)
+	}
+	return a.sendText(chatID, fmt.Sprintf(planMenuFinished, day.String(), len(planned)), nil)
+}
+
+func (a *Admin) clearPlanMenu(ctx context.Context, chatID int64) error {
+	_, day := a.menuMode()
+	if day == nil {
+		return a.sendText(chatID, planMenuNotStarted, nil)
+	}
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	err := a.menu.ClearMenuDay(newCtx, day)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("clearMenuDay: %w", err)
+	}
+	return a.sendText(chatID, fmt.Sprintf(planMenuCleared, day.String()), nil)
+}
+
+// parseMenuDay understands dates (20.10.2026 or 20.10), "сегодня", "завтра" and weekdays
+func parseMenuDay(message string, today time.Time) (*model.MenuDay, bool) {
+	message = strings.ToLower(strings.TrimSpace(message))
+	switch message {
+	case "сегодня":
+		return &model.MenuDay{Date: today}, true
+	case "завтра":
+		return &model.MenuDay{Date: today.AddDate(0, 0, 1)}, true
+	}
+	if weekday, ok := weekdaysByName[message]; ok {
+		return &model.MenuDay{Weekday: weekday, Weekly: true}, true
+	}
+	if date, err := time.Parse("02.01.2006", message); err == nil {
+		return &model.MenuDay{Date: date}, true
+	}
+	if date, err := time.Parse("02.01", message); err == nil {
+		date = date.AddDate(today.Year(), 0, 0)
+		if date.Before(today) {
+			date = date.AddDate(1, 0, 0)
+		}
+		return &model.MenuDay{Date: date}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,158 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/chucky-1/food-delivery-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeMenu has the only dish, it remembers whether the dish is stopped
+type fakeMenu struct {
+	service.Menu
+	mu      sync.Mutex
+	stopped bool
+}
+
+func (f *fakeMenu) GetAllCategories(context.Context) ([]*model.Category, error) {
+	return []*model.Category{{ID: 1, Name: "Супы"}}, nil
+}
+
+func (f *fakeMenu) AddCategory(ctx context.Context, category *model.Category) error {
+	categories, _ := f.GetAllCategories(ctx)
+	for _, c := range categories {
+		if c.Name == category.Name {
+			return fmt.Errorf("addCategory: %w", repository.ErrCategoryAlreadyExists)
+		}
+	}
+	return nil
+}
+
+func (f *fakeMenu) GetDish(context.Context, int) (*model.Dish, error) {
+	return &model.Dish{ID: 1, Name: "Борщ", Price: 5, Category: "Супы"}, nil
+}
+
+func (f *fakeMenu) GetActiveDishesByCategory(ctx context.Context, _ string, _ time.Time) ([]*model.Dish, error) {
+	dish, _ := f.GetDish(ctx, 1)
+	return []*model.Dish{dish}, nil
+}
+
+func (f *fakeMenu) GetStoppedDishesByCategory(ctx context.Context, _ string) ([]*model.Dish, error) {
+	dish, _ := f.GetDish(ctx, 1)
+	return []*model.Dish{dish}, nil
+}
+
+func (f *fakeMenu) StopDish(context.Context, int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	return nil
+}
+
+func (f *fakeMenu) ActivateDish(context.Context, int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = false
+	return nil
+}
+
+// TestConcurrentAdminUpdates handles updates of the admin from the private chat and from the group in parallel,
+// the way the dispatcher does it for different chats
+func TestConcurrentAdminUpdates(t *testing.T) {
+	const (
+		adminID   = 1
+		groupChat = -100
+	)
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 10, 12, 12, 0, 0, 0, time.UTC))
+	bot := messenger.NewFake(clk)
+	menu := &fakeMenu{}
+	a := NewAdmin(bot, nil, menu, nil, nil, clk, storage.NewMessage(storage.NewMemory(), clk), nil, adminID,
+		11*time.Hour, 15*time.Hour)
+
+	command := func(text string) tgbotapi.Update {
+		return tgbotapi.Update{Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: adminID},
+			Chat:     &tgbotapi.Chat{ID: adminID},
+			Text:     text,
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(text)}},
+		}}
+	}
+	press := func(action string) tgbotapi.Update {
+		return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      action,
+			From:    &tgbotapi.User{ID: adminID},
+			Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: groupChat}},
+			Data:    callbackData(action, 1),
+		}}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			a.Handle(ctx, command("/"+allStoppedDishes))
+			a.Handle(ctx, command("/"+allActivateDishes))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			a.Handle(ctx, press(cbAdminCategory))
+			a.Handle(ctx, press(cbAdminDish))
+		}
+	}()
+	wg.Wait()
+
+	// the last command shows stopped dishes, the dish is activated by its button
+	a.Handle(ctx, command("/"+allStoppedDishes))
+	menu.StopDish(ctx, 1)
+	a.Handle(ctx, press(cbAdminDish))
+	if menu.stopped {
+		t.Error("the dish isn't activated after /" + allStoppedDishes)
+	}
+}
+
+func TestAddExistingCategory(t *testing.T) {
+	const adminID = 1
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 10, 12, 12, 0, 0, 0, time.UTC))
+	bot := messenger.NewFake(clk)
+	a := NewAdmin(bot, nil, &fakeMenu{}, nil, nil, clk, storage.NewMessage(storage.NewMemory(), clk), nil, adminID,
+		11*time.Hour, 15*time.Hour)
+
+	send := func(text string) string {
+		t.Helper()
+		msg := &tgbotapi.Message{From: &tgbotapi.User{ID: adminID}, Chat: &tgbotapi.Chat{ID: adminID}, Text: text}
+		if text[0] == '/' {
+			msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(text)}}
+		}
+		a.Handle(ctx, tgbotapi.Update{Message: msg})
+		last, ok := bot.LastMessage(adminID)
+		if !ok {
+			t.Fatalf("nothing is sent after %q", text)
+		}
+		return last.Text
+	}
+
+	if got := send("/" + addCategoryCommand); got != addCategory {
+		t.Fatalf("/%s: got %q, want %q", addCategoryCommand, got, addCategory)
+	}
+	if got := send("🍲 Супы"); got != categoryAlreadyExists {
+		t.Fatalf("existing category: got %q, want %q", got, categoryAlreadyExists)
+	}
+	want := fmt.Sprintf(successfulAddCategory, "🥐 Выпечка")
+	if got := send("🥐 Выпечка"); got != want {
+		t.Fatalf("new category: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const showCart = "🛒 Мой заказ"
+
+var (
+	emptyCart       = "Ваш заказ пуст. Выберите блюда в меню"
+	cartHint        = "➕ / ➖ - изменить количество, ❌ - убрать блюдо из заказа"
+	tooManyPortions = "Нельзя заказать больше 20 порций одного блюда"
+)
+
+// orderMessage returns the text of the order for the date with quantities and total price.
+// Empty string means there is no order
+func (b *Bot) orderMessage(ctx context.Context, userTelegramID int64, date time.Time) (string, []*model.DishWithCount, error) {
+	dishesByCategories, err := b.order.GetAllDishesByCategory(ctx, userTelegramID, date)
+	if err != nil {
+		return "", nil, fmt.Errorf("getAllDishesByCategory: %w", err)
+	}
+	categories, err := b.menu.GetAllCategories(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("getAllCategories: %w", err)
+	}
+	today, err := b.order.Today(ctx, userTelegramID)
+	if err != nil {
+		return "", nil, fmt.Errorf("today: %w", err)
+	}
+
+	var (
+		message    = fmt.Sprintf("Ваш заказ на %s:\n\n", dayLabel(date, today))
+		totalPrice float32
+		ordered    []*model.DishWithCount
+	)
+	for _, category := range categories {
+		dishes, ok := dishesByCategories[category.Name]
+		if !ok {
+			continue
+		}
+		delete(dishesByCategories, category.Name)
+		ordered = append(ordered, dishes...)
+	}
+	// dishes of removed categories are still in order
+	for _, dishes := range dishesByCategories {
+		ordered = append(ordered, dishes...)
+	}
+	if len(ordered) == 0 {
+		return "", nil, nil
+	}
+	for _, d := range ordered {
+		price := d.Price * float32(d.Count)
+		if d.Count > 1 {
+			message = fmt.Sprintf("%s%s × %d - %.2f\n", message, d.Name, d.Count, price)
+		} else {
+			message = fmt.Sprintf("%s%s - %.2f\n", message, d.Name, price)
+		}
+		totalPrice += price
+	}
+	message = fmt.Sprintf("%s\nСумма вашего заказа: %.2f", message, totalPrice)
+	return message, ordered, nil
+}
+
+// showCart shows the order for the date with buttons to change the quantity of every dish
+func (b *Bot) showCart(ctx context.Context, userTelegramID, chatID int64, message *tgbotapi.Message, date time.Time) error {
+	confirmed, err := b.order.IsUserHaveConfirmedOrder(ctx, userTelegramID, date)
+	if err != nil {
+		return fmt.Errorf("isUserHaveConfirmedOrder: %w", err)
+	}
+	if confirmed {
+		return b.showMenu(ctx, userTelegramID, chatID, message, date)
+	}
+	text, dishes, err := b.orderMessage(ctx, userTelegramID, date)
+	if err != nil {
+		return err
+	}
+
+	var (
+		day     = date.Format(dateLayout)
+		buttons [][]tgbotapi.InlineKeyboardButton
+	)
+	if text == "" {
+		text = emptyCart
+	} else {
+		text = fmt.Sprintf("%s\n\n%s", text, cartHint)
+		for _, d := range dishes {
+			if d.ID == 0 {
+				continue
+			}
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+				inlineButton("➖", cbDecrease, day, d.ID),
+				inlineButton(fmt.Sprintf("%s × %d", d.Name, d.Count), cbCart, day),
+				inlineButton("➕", cbIncrease, day, d.ID),
+				inlineButton("❌", cbRemove, day, d.ID),
+			))
+		}
+		buttons = append(buttons,
+			tgbotapi.NewInlineKeyboardRow(inlineButton(saveTemplate, cbSaveTemplate, day)),
+			tgbotapi.NewInlineKeyboardRow(inlineButton(confirmOrder, cbConfirm, day)),
+			tgbotapi.NewInlineKeyboardRow(inlineButton(clearOrder, cbClear, day)),
+		)
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(goBackToMenu, cbMenu, day)))
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return showMessage(b.bot, chatID, message, text, &markup)
+}
+
+// changeQuantity handles ➕, ➖ and ❌ buttons of the cart. It returns the message for user if the order can't be changed
+func (b *Bot) changeQuantity(ctx context.Context, userTelegramID int64, action string, dishID int,
+	date time.Time) (string, bool, error) {
+	var err error
+	switch action {
+	case cbIncrease:
+		var dish *model.Dish
+		dish, err = b.menu.GetActiveDish(ctx, dishID, date)
+		if err != nil {
+			return "", false, fmt.Errorf("getActiveDish: %w", err)
+		}
+		if dish == nil {
+			return dishUnavailable, true, nil
+		}
+		err = b.order.AddDish(ctx, dish, userTelegramID, date)
+	case cbDecrease:
+		var dishes map[string][]*model.DishWithCount
+		dishes, err = b.order.GetAllDishesByCategory(ctx, userTelegramID, date)
+		if err != nil {
+			return "", false, fmt.Errorf("getAllDishesByCategory: %w", err)
+		}
+		if quantity := quantityInOrder(dishes, dishID); quantity != 0 {
+			err = b.order.SetDishQuantity(ctx, dishID, userTelegramID, date, quantity-1)
+		}
+	case cbRemove:
+		err = b.order.RemoveDish(ctx, dishID, userTelegramID, date)
+	}
+	if errors.Is(err, repository.ErrDishNotInOrder) {
+		return "", false, nil
+	}
+	if text, alert := orderErrorMessage(err); text != "" {
+		return text, alert, nil
+	}
+	return "", false, err
+}
+
+func quantityInOrder(dishesByCategories map[string][]*model.DishWithCount, dishID int) int {
+	for _, dishes := range dishesByCategories {
+		for _, d := range dishes {
+			if d.ID == dishID {
+				return d.Count
+			}
+		}
+	}
+	return 0
+}
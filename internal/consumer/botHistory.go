@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	history = "history"
+
+	repeatOrder = "🔁 Повторить заказ за %s"
+)
+
+var (
+	emptyHistory      = "У вас пока нет подтверждённых заказов"
+	historyHeader     = "Ваши заказы за последние дни:"
+	nothingToRepeat   = "Ни одного блюда из этого заказа сегодня нет в меню"
+	unavailableDishes = "Эти блюда сегодня недоступны, мы не добавили их в заказ:\n\n%s"
+	orderRepeated     = "Блюда из заказа за %s добавлены в ваш заказ"
+)
+
+// sendHistory sends confirmed orders of user by days with the buttons to repeat them
+func (b *Bot) sendHistory(ctx context.Context, userTelegramID, chatID int64) error {
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	orders, err := b.order.GetHistory(newCtx, userTelegramID)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("getHistory: %w", err)
+	}
+	if len(orders) == 0 {
+		_, err = b.bot.Send(tgbotapi.NewMessage(chatID, emptyHistory))
+		if err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+		return nil
+	}
+
+	var (
+		text    = historyHeader
+		buttons [][]tgbotapi.InlineKeyboardButton
+	)
+	for _, order := range orders {
+		date := order.Date.Format("02.01.2006")
+		text = fmt.Sprintf("%s\n\n📅 %s, заказ №%d (%s)\n", text, date, order.Number, orderStatusNames[order.Status])
+		for _, d := range order.Dishes {
+			text = fmt.Sprintf("%s%s × %d - %.2f\n", text, d.Name, d.Count, d.Price*float32(d.Count))
+		}
+		text = fmt.Sprintf("%sИтого: %.2f", text, order.Total())
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			inlineButton(fmt.Sprintf(repeatOrder, order.Date.Format("02.01")), cbRepeat, order.Date.Format(dateLayout))))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	_, err = b.bot.Send(msg)
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+// repeatOrder copies dishes of the order for the date in today's order and sends the cart.
+// It returns the message for user if the order can't be changed
+func (b *Bot) repeatOrder(ctx context.Context, userTelegramID, chatID int64, date string,
+	today time.Time) (string, bool, error) {
+	day, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return "", false, fmt.Errorf("parse: %w", err)
+	}
+	added, unavailable, err := b.order.RepeatOrder(ctx, userTelegramID, day, today)
+	return b.afterDishesAdded(ctx, userTelegramID, chatID, today, added, unavailable, err,
+		fmt.Sprintf(orderRepeated, day.Format("02.01")))
+}
+
+// afterDishesAdded tells user which dishes haven't been added in the order for the date and sends the cart.
+// It returns the message for user: the error, the alert if no portion is added or the done message
+func (b *Bot) afterDishesAdded(ctx context.Context, userTelegramID, chatID int64, date time.Time, added int,
+	unavailable []*model.Dish, err error, done string) (string, bool, error) {
+	if text, alert := orderErrorMessage(err); text != "" {
+		return text, alert, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if added == 0 {
+		return nothingToRepeat, true, nil
+	}
+	if len(unavailable) != 0 {
+		_, err = b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(unavailableDishes, dishNames(unavailable))))
+		if err != nil {
+			return "", false, fmt.Errorf("send: %w", err)
+		}
+	}
+	err = b.showCart(ctx, userTelegramID, chatID, nil, date)
+	if err != nil {
+		return "", false, err
+	}
+	return done, false, nil
+}
+
+func dishNames(dishes []*model.Dish) string {
+	names := make([]string, 0, len(dishes))
+	for _, dish := range dishes {
+		names = append(names, dish.Name)
+	}
+	return strings.Join(names, "\n")
+}
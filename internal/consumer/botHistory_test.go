@@ -0,0 +1,62 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+)
+
+// fakeOrder has the cart with the maximum quantity of the dish, so repeated orders add nothing to it
+type fakeOrder struct {
+	service.Order
+}
+
+func (f *fakeOrder) RepeatOrder(context.Context, int64, time.Time, time.Time) (int, []*model.Dish, error) {
+	return 0, nil, nil
+}
+
+func (f *fakeOrder) ApplyTemplate(context.Context, int64, int, time.Time) (int, []*model.Dish, error) {
+	return 0, nil, nil
+}
+
+func (f *fakeOrder) GetTemplates(context.Context, int64) ([]*model.Template, error) {
+	return []*model.Template{{ID: 1, Name: "Обычный"}}, nil
+}
+
+func (f *fakeOrder) GetAllDishesByCategory(context.Context, int64, time.Time) (map[string][]*model.DishWithCount, error) {
+	dish := &model.Dish{ID: 1, Name: "Борщ", Price: 5, Category: "Супы"}
+	return map[string][]*model.DishWithCount{"Супы": {{Dish: dish, Count: 20}}}, nil
+}
+
+func TestNothingIsAddedToCart(t *testing.T) {
+	const userID = 1
+	ctx := context.Background()
+	today := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(today.Add(9 * time.Hour))
+	bot := messenger.NewFake(clk)
+	b := NewBot(bot, nil, nil, nil, &fakeOrder{}, nil, clk, 0, nil)
+
+	repeat := func() (string, bool, error) {
+		return b.repeatOrder(ctx, userID, userID, today.AddDate(0, 0, -1).Format(dateLayout), today)
+	}
+	apply := func() (string, bool, error) {
+		return b.applyTemplate(ctx, userID, userID, 1, today)
+	}
+	for name, add := range map[string]func() (string, bool, error){"repeat": repeat, "template": apply} {
+		text, alert, err := add()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if text != nothingToRepeat || !alert {
+			t.Errorf("%s: got %q, alert %t, want %q", name, text, alert, nothingToRepeat)
+		}
+	}
+	if got := bot.Messages(userID); len(got) != 0 {
+		t.Errorf("the cart is sent although nothing is added: %+v", got)
+	}
+}
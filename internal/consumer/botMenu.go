@@ -0,0 +1,429 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const chooseDay = "📅 Заказать на другой день"
+
+var (
+	dishAddedToOrder     = "Добавлено: %s"
+	categoryIsNotVisible = "Этой категории больше нет в меню"
+	menuHeader           = "Меню на %s"
+	chooseDayHeader      = "На какой день вы хотите заказать обед?"
+	dayIsUnavailable     = "На этот день заказать обед уже нельзя"
+)
+
+var weekdayNames = [...]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"}
+
+// handleCallback handles the buttons under the messages of the bot
+func (b *Bot) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	if query.Message == nil {
+		return answerCallback(b.bot, query.ID, "", false)
+	}
+	var (
+		userTelegramID = query.From.ID
+		chatID         = query.Message.Chat.ID
+		message        = query.Message
+		action, args   = parseCallbackData(query.Data)
+		notification   string
+		alert          bool
+	)
+
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	today, err := b.order.Today(newCtx, userTelegramID)
+	if err != nil {
+		return failCallback(b.bot, query.ID, action, err)
+	}
+	date, dateArgs := orderDate(args, today)
+
+	switch action {
+	case cbMenu:
+		err = b.showMenu(newCtx, userTelegramID, chatID, message, date)
+
+	case cbDays:
+		err = b.showDays(newCtx, userTelegramID, chatID, message)
+
+	case cbCategory:
+		var category *model.Category
+		category, err = categoryByID(newCtx, b.menu, callbackID(dateArgs, 0))
+		if err != nil {
+			break
+		}
+		if category == nil || !category.Visible {
+			notification = categoryIsNotVisible
+			err = b.showMenu(newCtx, userTelegramID, chatID, message, date)
+			break
+		}
+		err = b.showDishes(newCtx, userTelegramID, chatID, message, category, date)
+
+	case cbDish:
+		var dish *model.Dish
+		dish, err = b.menu.GetActiveDish(newCtx, callbackID(dateArgs, 0), date)
+		if err != nil {
+			break
+		}
+		if dish == nil {
+			notification, alert = dishUnavailable, true
+			err = b.showMenu(newCtx, userTelegramID, chatID, message, date)
+			break
+		}
+		err = b.showDishDetails(newCtx, chatID, message, dish, date)
+
+	case cbAdd:
+		var dish *model.Dish
+		dish, err = b.menu.GetActiveDish(newCtx, callbackID(dateArgs, 0), date)
+		if err != nil {
+			break
+		}
+		if dish == nil {
+			notification, alert = dishUnavailable, true
+			err = b.showMenu(newCtx, userTelegramID, chatID, message, date)
+			break
+		}
+		err = b.order.AddDish(newCtx, dish, userTelegramID, date)
+		notification, alert = orderErrorMessage(err)
+		if notification != "" {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		notification = fmt.Sprintf(dishAddedToOrder, dish.Name)
+		var category *model.Category
+		category, err = b.menu.GetCategory(newCtx, dish.Category)
+		if err != nil || category == nil {
+			break
+		}
+		err = b.showDishes(newCtx, userTelegramID, chatID, message, category, date)
+
+	case cbCart:
+		err = b.showCart(newCtx, userTelegramID, chatID, message, date)
+
+	case cbIncrease, cbDecrease, cbRemove:
+		notification, alert, err = b.changeQuantity(newCtx, userTelegramID, action, callbackID(dateArgs, 0), date)
+		if err != nil {
+			break
+		}
+		err = b.showCart(newCtx, userTelegramID, chatID, message, date)
+
+	case cbRepeat:
+		if len(args) == 0 {
+			break
+		}
+		notification, alert, err = b.repeatOrder(newCtx, userTelegramID, chatID, args[0], today)
+
+	case cbSaveTemplate:
+		err = b.askTemplateName(newCtx, userTelegramID, chatID, date)
+
+	case cbApplyTemplate:
+		notification, alert, err = b.applyTemplate(newCtx, userTelegramID, chatID, callbackID(args, 0), today)
+
+	case cbDeleteTemplate:
+		err = b.order.DeleteTemplate(newCtx, userTelegramID, callbackID(args, 0))
+		if err != nil && !errors.Is(err, repository.ErrTemplateNotFound) {
+			break
+		}
+		notification = successfulDeleteTemplate
+		err = b.showTemplates(newCtx, userTelegramID, chatID, message)
+
+	case cbConfirm:
+		var number int
+		number, err = b.order.ConfirmOrderByUser(newCtx, userTelegramID, date)
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			notification, alert = nothingToConfirm, true
+			err = b.showMenu(newCtx, userTelegramID, chatID, message, date)
+			break
+		}
+		if errors.Is(err, repository.ErrLunchTimePassed) {
+			notification, alert, err = lunchTimePassed, true, nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		text := successfulConfirmOrder
+		if date.After(today) {
+			text = fmt.Sprintf(successfulConfirmPreOrder, dayLabel(date, today))
+		}
+		err = showMessage(b.bot, chatID, message, text+fmt.Sprintf(orderNumber, number), nil)
+
+	case cbClear:
+		err = b.order.ClearOrdersByUser(newCtx, userTelegramID, date)
+		if err != nil {
+			break
+		}
+		notification = successfulClearOrder
+		err = b.showMenu(newCtx, userTelegramID, chatID, message, date)
+
+	case cbCancel:
+		err = b.order.ClearOrdersByUserWithCheckLunchTime(newCtx, userTelegramID, date)
+		if errors.Is(err, repository.ErrLunchTimePassed) {
+			notification, alert, err = cannotCancelOrderMessage, true, nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		notification = successfulCancelOrder
+		err = b.showMenu(newCtx, userTelegramID, chatID, message, date)
+	}
+	if err != nil {
+		return failCallback(b.bot, query.ID, action, err)
+	}
+	return answerCallback(b.bot, query.ID, notification, alert)
+}
+
+// sendMenu sends the menu for today or for the nearest working day if today is a day off
+func (b *Bot) sendMenu(ctx context.Context, userTelegramID, chatID int64) error {
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	days, err := b.order.OrderDays(newCtx, userTelegramID)
+	if err != nil {
+		return err
+	}
+	date, err := b.order.Today(newCtx, userTelegramID)
+	if err != nil {
+		return err
+	}
+	if len(days) != 0 {
+		date = days[0]
+	}
+	return b.showMenu(newCtx, userTelegramID, chatID, nil, date)
+}
+
+// orderDate returns the date of the order from the first argument of the callback and the rest arguments.
+// Buttons without the date and with the past date are for today
+func orderDate(args []string, today time.Time) (time.Time, []string) {
+	if len(args) == 0 {
+		return today, args
+	}
+	date, err := time.Parse(dateLayout, args[0])
+	if err != nil {
+		return today, args
+	}
+	if date.Before(today) {
+		return today, args[1:]
+	}
+	return date, args[1:]
+}
+
+// showMenu shows categories of the menu for the date instead of the message. If the message is nil the new one is sent
+func (b *Bot) showMenu(ctx context.Context, userTelegramID, chatID int64, message *tgbotapi.Message, date time.Time) error {
+	days, err := b.order.OrderDays(ctx, userTelegramID)
+	if err != nil {
+		return err
+	}
+	today, err := b.order.Today(ctx, userTelegramID)
+	if err != nil {
+		return err
+	}
+	if !containsDay(days, date) {
+		markup := daysKeyboard(days, today)
+		return showMessage(b.bot, chatID, message, dayIsUnavailable, &markup)
+	}
+	header := fmt.Sprintf(menuHeader, dayLabel(date, today))
+
+	isUserHaveConfirmedOrder, err := b.order.IsUserHaveConfirmedOrder(ctx, userTelegramID, date)
+	if err != nil {
+		return err
+	}
+	if isUserHaveConfirmedOrder {
+		buttons := [][]tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardRow(inlineButton(cancelOrder, cbCancel, date.Format(dateLayout))),
+		}
+		if len(days) > 1 {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(chooseDay, cbDays)))
+		}
+		markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+		return showMessage(b.bot, chatID, message, fmt.Sprintf("%s\n\n%s", header, userAlreadyHasConfirmedOrder), &markup)
+	}
+
+	categories, err := b.menu.GetVisibleCategories(ctx)
+	if err != nil {
+		return err
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, category := range categories {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			inlineButton(category.String(), cbCategory, date.Format(dateLayout), category.ID)))
+	}
+	orderButtons, err := b.orderButtons(ctx, userTelegramID, date)
+	if err != nil {
+		return err
+	}
+	buttons = append(buttons, orderButtons...)
+	if len(days) > 1 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(chooseDay, cbDays)))
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return showMessage(b.bot, chatID, message, header, &markup)
+}
+
+// showDays shows the days when user can order lunch
+func (b *Bot) showDays(ctx context.Context, userTelegramID, chatID int64, message *tgbotapi.Message) error {
+	days, err := b.order.OrderDays(ctx, userTelegramID)
+	if err != nil {
+		return err
+	}
+	today, err := b.order.Today(ctx, userTelegramID)
+	if err != nil {
+		return err
+	}
+	markup := daysKeyboard(days, today)
+	return showMessage(b.bot, chatID, message, chooseDayHeader, &markup)
+}
+
+func daysKeyboard(days []time.Time, today time.Time) tgbotapi.InlineKeyboardMarkup {
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, day := range days {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(dayLabel(day, today), cbMenu, day.Format(dateLayout))))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(buttons...)
+}
+
+// dayLabel returns the day for user, e.g. "сегодня, 17.10" or "пн, 19.10"
+func dayLabel(date, today time.Time) string {
+	name := weekdayNames[date.Weekday()]
+	switch {
+	case date.Equal(today):
+		name = "сегодня"
+	case date.Equal(today.AddDate(0, 0, 1)):
+		name = "завтра"
+	}
+	return fmt.Sprintf("%s, %s", name, date.Format("02.01"))
+}
+
+func containsDay(days []time.Time, date time.Time) bool {
+	for _, day := range days {
+		if day.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// showDishes shows the dishes of the category which can be ordered for the date with their quantities in order
+func (b *Bot) showDishes(ctx context.Context, userTelegramID, chatID int64, message *tgbotapi.Message,
+	category *model.Category, date time.Time) error {
+	dishes, err := b.menu.GetActiveDishesByCategory(ctx, category.Name, date)
+	if err != nil {
+		return err
+	}
+	ordered, err := b.order.GetAllDishesByCategory(ctx, userTelegramID, date)
+	if err != nil {
+		return err
+	}
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, dish := range dishes {
+		text := dish.String()
+		if quantity := quantityInOrder(ordered, dish.ID); quantity != 0 {
+			text = fmt.Sprintf("%s (× %d)", text, quantity)
+		}
+		action := cbAdd
+		if dish.HasDetails() {
+			action = cbDish
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(text, action, date.Format(dateLayout), dish.ID)))
+	}
+	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(inlineButton(goBackToMenu, cbMenu, date.Format(dateLayout))))
+	orderButtons, err := b.orderButtons(ctx, userTelegramID, date)
+	if err != nil {
+		return err
+	}
+	buttons = append(buttons, orderButtons...)
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return showMessage(b.bot, chatID, message, category.String(), &markup)
+}
+
+// showDishDetails shows photo and description of the dish with the button which adds the dish in order
+func (b *Bot) showDishDetails(ctx context.Context, chatID int64, message *tgbotapi.Message, dish *model.Dish,
+	date time.Time) error {
+	back := inlineButton(goBackToMenu, cbMenu, date.Format(dateLayout))
+	category, err := b.menu.GetCategory(ctx, dish.Category)
+	if err != nil {
+		return err
+	}
+	if category != nil {
+		back = inlineButton(goBackToCategory, cbCategory, date.Format(dateLayout), category.ID)
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(inlineButton(addDishToOrder, cbAdd, date.Format(dateLayout), dish.ID)),
+		tgbotapi.NewInlineKeyboardRow(back),
+	)
+	if dish.Photo == "" {
+		return showMessage(b.bot, chatID, message, dish.Details(), &markup)
+	}
+
+	if message != nil {
+		err = b.bot.Delete(chatID, message.MessageID)
+		if err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+	}
+	var file tgbotapi.RequestFileData = tgbotapi.FileID(dish.Photo)
+	if strings.HasPrefix(dish.Photo, "http://") || strings.HasPrefix(dish.Photo, "https://") {
+		file = tgbotapi.FileURL(dish.Photo)
+	}
+	msg := tgbotapi.NewPhoto(chatID, file)
+	caption := []rune(dish.Details())
+	if len(caption) > maxCaptionLength {
+		caption = caption[:maxCaptionLength]
+	}
+	msg.Caption = string(caption)
+	msg.ReplyMarkup = markup
+	_, err = b.bot.Send(msg)
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+// orderButtons returns the buttons to manage the order for the date if user has already added something
+func (b *Bot) orderButtons(ctx context.Context, userTelegramID int64, date time.Time) ([][]tgbotapi.InlineKeyboardButton, error) {
+	exist, err := b.order.IsUserHaveAnyOrders(ctx, userTelegramID, date)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(inlineButton(showCart, cbCart, date.Format(dateLayout))),
+		tgbotapi.NewInlineKeyboardRow(inlineButton(confirmOrder, cbConfirm, date.Format(dateLayout))),
+		tgbotapi.NewInlineKeyboardRow(inlineButton(clearOrder, cbClear, date.Format(dateLayout))),
+	}, nil
+}
+
+// orderErrorMessage returns the message for user if the order can't be changed because of the err
+func orderErrorMessage(err error) (string, bool) {
+	switch {
+	case errors.Is(err, service.ErrDayOff):
+		return dayOffMessage, true
+	case errors.Is(err, service.ErrInvalidDate):
+		return dayIsUnavailable, true
+	case errors.Is(err, repository.ErrLunchTimePassed):
+		return lunchTimePassed, true
+	case errors.Is(err, repository.ErrOrderConfirmed):
+		return userAlreadyHasConfirmedOrder, true
+	case errors.Is(err, service.ErrInvalidQuantity):
+		return tooManyPortions, true
+	}
+	return "", false
+}
@@ -0,0 +1,152 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/chucky-1/food-delivery-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	templates = "templates"
+
+	saveTemplate = "⭐ Сохранить как шаблон"
+
+	// user names the template right after pressing the button
+	templateNameTTL = 10 * time.Minute
+)
+
+var (
+	inputTemplateName        = "Введите название шаблона, например «Обычный обед»"
+	invalidTemplateName      = "Название шаблона должно быть не длиннее 50 символов. Попробуйте ещё раз"
+	templateAlreadyExists    = "Шаблон с таким названием уже есть. Введите другое название"
+	tooManyTemplates         = "Можно сохранить не больше 10 шаблонов. Удалите ненужные: /templates"
+	nothingToSave            = "Ваш заказ пуст, сохранять нечего"
+	successfulSaveTemplate   = "Шаблон «%s» сохранён. Все шаблоны: /templates"
+	emptyTemplates           = "У вас пока нет шаблонов. Соберите заказ и нажмите «" + saveTemplate + "»"
+	templatesHeader          = "Ваши шаблоны. ▶️ - добавить блюда шаблона в заказ, 🗑 - удалить шаблон"
+	templateApplied          = "Блюда из шаблона «%s» добавлены в ваш заказ"
+	successfulDeleteTemplate = "Шаблон удалён"
+)
+
+// askTemplateName waits the name of the template for the order for the date
+func (b *Bot) askTemplateName(ctx context.Context, userTelegramID, chatID int64, date time.Time) error {
+	_, err := b.bot.Send(tgbotapi.NewMessage(chatID, inputTemplateName))
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return b.waitTemplateName(ctx, userTelegramID, date.Format(dateLayout))
+}
+
+// waitTemplateName waits the name of the template for the order for the day, it's waited for less than other steps
+func (b *Bot) waitTemplateName(ctx context.Context, userTelegramID int64, day string) error {
+	return b.msgStore.Wait(ctx, userTelegramID, &model.Conversation{
+		Action:          storage.SaveTemplate,
+		DataOnFirstStep: day,
+	}, templateNameTTL)
+}
+
+func (b *Bot) saveTemplate(ctx context.Context, userTelegramID, chatID int64, name, day string) error {
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	date, err := time.Parse(dateLayout, day)
+	if err != nil {
+		date, err = b.order.Today(newCtx, userTelegramID)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("today: %w", err)
+		}
+	}
+	template, err := b.order.SaveTemplate(newCtx, userTelegramID, name, date)
+	cancel()
+
+	var text string
+	switch {
+	case errors.Is(err, service.ErrInvalidTemplateName):
+		text = invalidTemplateName
+		err = b.waitTemplateName(ctx, userTelegramID, day)
+		if err != nil {
+			return err
+		}
+	case errors.Is(err, repository.ErrTemplateAlreadyExists):
+		text = templateAlreadyExists
+		err = b.waitTemplateName(ctx, userTelegramID, day)
+		if err != nil {
+			return err
+		}
+	case errors.Is(err, service.ErrTooManyTemplates):
+		text = tooManyTemplates
+	case errors.Is(err, service.ErrEmptyOrder):
+		text = nothingToSave
+	case err != nil:
+		return fmt.Errorf("saveTemplate: %w", err)
+	default:
+		text = fmt.Sprintf(successfulSaveTemplate, template.Name)
+	}
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+// showTemplates shows templates of user with the buttons to apply and delete them
+func (b *Bot) showTemplates(ctx context.Context, userTelegramID, chatID int64, message *tgbotapi.Message) error {
+	all, err := b.order.GetTemplates(ctx, userTelegramID)
+	if err != nil {
+		return fmt.Errorf("getTemplates: %w", err)
+	}
+	if len(all) == 0 {
+		return showMessage(b.bot, chatID, message, emptyTemplates, nil)
+	}
+
+	var (
+		text    = templatesHeader
+		buttons [][]tgbotapi.InlineKeyboardButton
+	)
+	for _, template := range all {
+		text = fmt.Sprintf("%s\n\n⭐ %s\n", text, template.Name)
+		for _, d := range template.Dishes {
+			text = fmt.Sprintf("%s%s × %d\n", text, d.Name, d.Count)
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
+			inlineButton("▶️ "+template.Name, cbApplyTemplate, template.ID),
+			inlineButton("🗑", cbDeleteTemplate, template.ID),
+		))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return showMessage(b.bot, chatID, message, text, &markup)
+}
+
+func (b *Bot) sendTemplates(ctx context.Context, userTelegramID, chatID int64) error {
+	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	return b.showTemplates(newCtx, userTelegramID, chatID, nil)
+}
+
+// applyTemplate adds dishes of the template in today's order and sends the cart.
+// It returns the message for user if the order can't be changed
+func (b *Bot) applyTemplate(ctx context.Context, userTelegramID, chatID int64, id int,
+	today time.Time) (string, bool, error) {
+	all, err := b.order.GetTemplates(ctx, userTelegramID)
+	if err != nil {
+		return "", false, fmt.Errorf("getTemplates: %w", err)
+	}
+	var name string
+	for _, template := range all {
+		if template.ID == id {
+			name = template.Name
+		}
+	}
+	added, unavailable, err := b.order.ApplyTemplate(ctx, userTelegramID, id, today)
+	if errors.Is(err, repository.ErrTemplateNotFound) {
+		return actionIsOutdated, false, nil
+	}
+	return b.afterDishesAdded(ctx, userTelegramID, chatID, today, added, unavailable, err,
+		fmt.Sprintf(templateApplied, name))
+}
@@ -0,0 +1,183 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/chucky-1/food-delivery-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Callback data has the form "action:arg:arg". Telegram limits it with 64 bytes,
+// so actions are short and dishes and categories are passed by ID
+const (
+	callbackSeparator = ":"
+
+	// customer
+	cbMenu     = "m"
+	cbCategory = "c"
+	cbDish     = "d"
+	cbAdd      = "a"
+	cbCart     = "k"
+	cbIncrease = "i"
+	cbDecrease = "r"
+	cbRemove   = "x"
+	cbConfirm  = "cf"
+	cbClear    = "cl"
+	cbCancel   = "cn"
+	cbRepeat   = "rp"
+	cbDays     = "dd"
+
+	cbSaveTemplate   = "ts"
+	cbApplyTemplate  = "ta"
+	cbDeleteTemplate = "td"
+
+	// admin
+	cbAdminMenu     = "am"
+	cbAdminCategory = "ac"
+	cbAdminDish     = "as"
+	cbPlanDish      = "ap"
+	cbPlanDay       = "pd"
+	cbFlowCategory  = "fc"
+	cbFlowDish      = "fd"
+	cbFlowKeep      = "fk"
+	cbFlowYes       = "fy"
+	cbFlowNo        = "fn"
+
+	cbAdvanceShipment = "ab"
+)
+
+var (
+	successfulCancelConversation = "Действие отменено"
+	nothingToCancel              = "Сейчас нечего отменять"
+	callbackFailed               = "Что-то пошло не так, попробуйте ещё раз"
+)
+
+// dateLayout is used to pass dates in callback data
+const dateLayout = "2006-01-02"
+
+func callbackData(action string, args ...interface{}) string {
+	data := action
+	for _, arg := range args {
+		data = fmt.Sprintf("%s%s%v", data, callbackSeparator, arg)
+	}
+	return data
+}
+
+func parseCallbackData(data string) (string, []string) {
+	fields := strings.Split(data, callbackSeparator)
+	return fields[0], fields[1:]
+}
+
+// callbackID returns 0 if there is no such argument or it isn't a number
+func callbackID(args []string, i int) int {
+	if i >= len(args) {
+		return 0
+	}
+	id, err := strconv.Atoi(args[i])
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+func inlineButton(text, action string, args ...interface{}) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, callbackData(action, args...))
+}
+
+// showMessage replaces the message with the new text and buttons. If the message can't be edited (there is
+// no message or it's a photo) the old one is removed and the new one is sent
+func showMessage(bot messenger.Messenger, chatID int64, message *tgbotapi.Message, text string,
+	markup *tgbotapi.InlineKeyboardMarkup) error {
+	if message != nil && len(message.Photo) == 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, message.MessageID, text)
+		edit.ReplyMarkup = markup
+		err := bot.Edit(edit)
+		if err != nil {
+			return fmt.Errorf("edit: %w", err)
+		}
+		return nil
+	}
+	if message != nil {
+		err := bot.Delete(chatID, message.MessageID)
+		if err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	_, err := bot.Send(msg)
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+// removeButtons removes the buttons from the message, so they can't be pressed twice
+func removeButtons(bot messenger.Messenger, message *tgbotapi.Message) error {
+	edit := tgbotapi.NewEditMessageReplyMarkup(message.Chat.ID, message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+	err := bot.Edit(edit)
+	if err != nil {
+		return fmt.Errorf("edit: %w", err)
+	}
+	return nil
+}
+
+func answerCallback(bot messenger.Messenger, callbackID, text string, alert bool) error {
+	err := bot.AnswerCallback(callbackID, text, alert)
+	if err != nil {
+		return fmt.Errorf("answerCallback: %w", err)
+	}
+	return nil
+}
+
+// failCallback answers the callback of the failed action, so the button doesn't load until the timeout,
+// and returns the error of the action
+func failCallback(bot messenger.Messenger, callbackID, action string, err error) error {
+	errAnswer := answerCallback(bot, callbackID, callbackFailed, true)
+	if errAnswer != nil {
+		logrus.Errorf("%s: %s", action, errAnswer.Error())
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
+
+// cancelConversation forgets the message which the bot waits from user, so user can start another action
+func cancelConversation(ctx context.Context, bot messenger.Messenger, msgStore *storage.Messages, userTelegramID,
+	chatID int64) error {
+	ok, err := msgStore.Cancel(ctx, userTelegramID)
+	if err != nil {
+		return fmt.Errorf("cancel: %w", err)
+	}
+	text := successfulCancelConversation
+	if !ok {
+		text = nothingToCancel
+	}
+	_, err = bot.Send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+// categoryByID returns nil if there is no such category
+func categoryByID(ctx context.Context, menu service.Menu, id int) (*model.Category, error) {
+	categories, err := menu.GetAllCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getAllCategories: %w", err)
+	}
+	for _, category := range categories {
+		if category.ID == id {
+			return category, nil
+		}
+	}
+	return nil, nil
+}
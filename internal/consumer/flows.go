@@ -0,0 +1,211 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/fsm"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	join = "join"
+
+	createOrganizationCommand = "create_organization"
+	addAddress                = "add_address"
+	addCategoryCommand        = "add_category"
+
+	// users may need time to find out their organization ID
+	customerStepTTL = 24 * time.Hour
+)
+
+var (
+	invalidString         = "Вы ввели некорректную строку. Попробуйте ещё раз"
+	invalidOrganizationID = "Вы ввели некорректный ID организации. Попробуйте ещё раз"
+)
+
+func (b *Bot) registrationFlow() *fsm.Flow {
+	return &fsm.Flow{
+		Command: register,
+		Start: func(ctx context.Context, user *tgbotapi.User, chatID int64) error {
+			newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			err := b.auth.Register(newCtx, &model.TelegramUser{
+				ID:       user.ID,
+				ChatID:   chatID,
+				Username: user.UserName,
+			})
+			if err != nil {
+				return fmt.Errorf("register: %w", err)
+			}
+			logrus.Debugf("user registered: %s %d", user.UserName, user.ID)
+			return nil
+		},
+		Steps: []*fsm.Step{
+			{
+				Name:   "first_name",
+				Prompt: startRegister,
+				TTL:    customerStepTTL,
+				Handle: func(ctx context.Context, userTelegramID int64, answer string, _ fsm.Answers) error {
+					return b.auth.UpdateFirstName(ctx, int(userTelegramID), answer)
+				},
+			},
+			{
+				Name:   "last_name",
+				Prompt: inputLastName,
+				TTL:    customerStepTTL,
+				Handle: func(ctx context.Context, userTelegramID int64, answer string, _ fsm.Answers) error {
+					return b.auth.UpdateLastName(ctx, int(userTelegramID), answer)
+				},
+			},
+			{
+				Name:   "middle_name",
+				Prompt: inputMiddleName,
+				TTL:    customerStepTTL,
+				Handle: func(ctx context.Context, userTelegramID int64, answer string, _ fsm.Answers) error {
+					return b.auth.UpdateMiddleName(ctx, int(userTelegramID), answer)
+				},
+			},
+		},
+		Complete: func(_ context.Context, _ int64, _ fsm.Answers) ([]string, error) {
+			return []string{successfulRegistered}, nil
+		},
+	}
+}
+
+func (b *Bot) joinFlow() *fsm.Flow {
+	return &fsm.Flow{
+		Command: join,
+		Steps: []*fsm.Step{
+			{
+				Name:   "organization_id",
+				Prompt: joinToOrganization,
+				TTL:    customerStepTTL,
+				Handle: func(ctx context.Context, userTelegramID int64, answer string, _ fsm.Answers) error {
+					orgID, err := uuid.Parse(answer)
+					if err != nil {
+						return fsm.Invalid(invalidString)
+					}
+					newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+					defer cancel()
+					err = b.org.Join(newCtx, orgID, userTelegramID)
+					if err != nil {
+						logrus.Errorf("joinToOrganization: %s", err.Error())
+						return fsm.Invalid(errJoinToOrganization)
+					}
+					return nil
+				},
+			},
+		},
+		Complete: func(_ context.Context, _ int64, _ fsm.Answers) ([]string, error) {
+			return []string{successfulJoinOrganization, menuRequest}, nil
+		},
+	}
+}
+
+func (a *Admin) createOrganizationFlow() *fsm.Flow {
+	return &fsm.Flow{
+		Command: createOrganizationCommand,
+		Steps: []*fsm.Step{
+			{
+				Name:   "organization",
+				Prompt: createOrganization,
+				Handle: func(_ context.Context, _ int64, answer string, _ fsm.Answers) error {
+					// format: Название организации 12:30, where 12:30 is lunch time
+					if len(strings.Fields(answer)) < 2 {
+						return fsm.Invalid(invalidString)
+					}
+					_, errHandle := a.handleCreateOrganization(answer)
+					if errHandle != "" {
+						return fsm.Invalid(errHandle)
+					}
+					return nil
+				},
+			},
+		},
+		Complete: func(ctx context.Context, _ int64, answers fsm.Answers) ([]string, error) {
+			organization, _ := a.handleCreateOrganization(answers["organization"])
+			newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			err := a.org.Add(newCtx, organization)
+			if err != nil {
+				return nil, fmt.Errorf("add: %w", err)
+			}
+			return []string{fmt.Sprintf(successfulOrganizationRegistered, organization.Name), organization.ID.String()}, nil
+		},
+	}
+}
+
+func (a *Admin) addAddressFlow() *fsm.Flow {
+	return &fsm.Flow{
+		Command: addAddress,
+		Steps: []*fsm.Step{
+			{
+				Name:   "organization_id",
+				Prompt: addAddressStep1,
+				Handle: func(_ context.Context, _ int64, answer string, _ fsm.Answers) error {
+					_, err := uuid.Parse(answer)
+					if err != nil {
+						return fsm.Invalid(invalidOrganizationID)
+					}
+					return nil
+				},
+			},
+			{
+				Name:   "address",
+				Prompt: addAddressStep2,
+			},
+		},
+		Complete: func(ctx context.Context, _ int64, answers fsm.Answers) ([]string, error) {
+			orgID, err := uuid.Parse(answers["organization_id"])
+			if err != nil {
+				return nil, fmt.Errorf("parse: %w", err)
+			}
+			newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+			err = a.org.UpdateAddress(newCtx, orgID, answers["address"])
+			if err != nil {
+				return nil, fmt.Errorf("updateAddress: %w", err)
+			}
+			return []string{successfulAddAddress}, nil
+		},
+	}
+}
+
+func (a *Admin) addCategoryFlow() *fsm.Flow {
+	return &fsm.Flow{
+		Command: addCategoryCommand,
+		Steps: []*fsm.Step{
+			{
+				Name:   "category",
+				Prompt: addCategory,
+				Handle: func(ctx context.Context, _ int64, answer string, _ fsm.Answers) error {
+					newCtx, cancel := context.WithTimeout(ctx, time.Minute)
+					defer cancel()
+					err := a.menu.AddCategory(newCtx, parseCategory(answer))
+					switch {
+					case errors.Is(err, service.ErrInvalidCategoryName):
+						return fsm.Invalid(invalidCategoryName)
+					case errors.Is(err, repository.ErrCategoryAlreadyExists):
+						return fsm.Invalid(categoryAlreadyExists)
+					}
+					if err != nil {
+						return fmt.Errorf("addCategory: %w", err)
+					}
+					return nil
+				},
+			},
+		},
+		Complete: func(_ context.Context, _ int64, answers fsm.Answers) ([]string, error) {
+			return []string{fmt.Sprintf(successfulAddCategory, parseCategory(answers["category"]).String())}, nil
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// orderRowsFixture are rows of internal.orders before V23. Rows of the day could disagree: dishes added by repeat or
+// template after the confirmation are unconfirmed drafts, shipped rows could be left behind by the admin
+const orderRowsFixture = `
+INSERT INTO internal.categories (id, name) VALUES (1, 'Супы');
+INSERT INTO internal.dishes (id, name, price, category_id) VALUES (1, 'Борщ', 5, 1), (2, 'Солянка', 6, 1);
+INSERT INTO internal.organizations (id, name, lunch_time)
+VALUES ('6f1c2b0e-4d2a-4a8e-9d55-0c1f0b6a7e11', 'Рога и копыта', '13:00');
+INSERT INTO internal.shipments (id, organization_id, date, lunch_time, ship_at, status, next_attempt_at)
+VALUES (1, '6f1c2b0e-4d2a-4a8e-9d55-0c1f0b6a7e11', '2026-01-05', '13:00', '2026-01-05 12:00+03', 'sent',
+        '2026-01-05 12:00+03');
+INSERT INTO internal.orders (date, user_telegram_id, dish_id, dish_name, dish_price, category, confirmed, quantity,
+                             shipment_id, status)
+VALUES ('2026-01-05', 1, 1, 'Борщ', 5, 'Супы', true, 1, 1, 'sent_to_kitchen'),
+       ('2026-01-05', 1, 2, 'Солянка', 6, 'Супы', false, 2, NULL, 'draft'),
+       ('2026-01-05', 2, 1, 'Борщ', 5, 'Супы', true, 1, 1, 'delivered'),
+       ('2026-01-05', 2, 2, 'Солянка', 6, 'Супы', true, 1, 1, 'out_for_delivery'),
+       (current_date + 1, 1, 1, 'Борщ', 5, 'Супы', true, 2, NULL, 'confirmed'),
+       (current_date + 1, 1, 2, 'Солянка', 6, 'Супы', false, 1, NULL, 'draft'),
+       (current_date + 1, 2, 1, 'Борщ', 5, 'Супы', false, 1, NULL, 'confirmed');
+`
+
+func TestOrderHeadersMigration(t *testing.T) {
+	pool := createDatabase(t)
+	ctx := context.Background()
+
+	var before, after []string
+	for _, file := range migrations(t) {
+		if migrationVersion(file)[0] < 23 {
+			before = append(before, file)
+		} else {
+			after = append(after, file)
+		}
+	}
+	migrate(t, pool, before)
+	if _, err := pool.Exec(ctx, orderRowsFixture); err != nil {
+		t.Fatal(err)
+	}
+	migrate(t, pool, after)
+
+	rows, err := pool.Query(ctx, `
+		SELECT o.number, o.user_telegram_id, o.date = current_date + 1, o.status, o.total::float, o.dishes_count,
+		       coalesce(o.shipment_id, 0),
+		       coalesce(string_agg(i.dish_name || ' x' || i.quantity, ', ' ORDER BY i.dish_name), '')
+		FROM internal.orders AS o
+		LEFT JOIN internal.order_items AS i ON i.order_id = o.id
+		GROUP BY o.id
+		ORDER BY o.number`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var (
+			number, count, shipmentID int
+			user                      int64
+			future                    bool
+			status, items             string
+			total                     float64
+		)
+		if err = rows.Scan(&number, &user, &future, &status, &total, &count, &shipmentID, &items); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d user %d future %t %s %.2f %d shipment %d: %s",
+			number, user, future, status, total, count, shipmentID, items))
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		// the shipped day of the past is delivered with the shipped dish only
+		"1000 user 1 future false delivered 5.00 1 shipment 1: Борщ x1",
+		// the least advanced status of shipped rows
+		"1001 user 2 future false out_for_delivery 11.00 2 shipment 1: Борщ x1, Солянка x1",
+		// the dish added after the confirmation isn't placed
+		"1002 user 1 future true confirmed 10.00 2 shipment 0: Борщ x2",
+		// nothing is confirmed whatever the status says
+		"1003 user 2 future true draft 5.00 1 shipment 0: Борщ x1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d orders, want %d:\n%v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %q, want %q", got[i], want[i])
+		}
+	}
+}
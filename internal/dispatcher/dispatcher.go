@@ -0,0 +1,145 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/chucky-1/food-delivery-bot/internal/metrics"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	queuedUpdates = metrics.NewGauge("bot_updates_queued",
+		"Updates which are received from telegram and wait for the worker or are being handled")
+	busyWorkers    = metrics.NewGauge("bot_workers_busy", "Workers which are handling updates")
+	handledUpdates = metrics.NewCounter("bot_updates_handled_total", "Updates which have been handled")
+)
+
+// Handler handles the update. Updates of the same chat are passed to it one by one in the order they are received
+type Handler func(ctx context.Context, update tgbotapi.Update)
+
+// Dispatcher handles updates of different chats in parallel by the pool of workers and updates of the same chat in order
+type Dispatcher struct {
+	handle  Handler
+	workers int
+
+	// slots limits the number of queued updates, receiving of updates is blocked when the queue is full
+	slots chan struct{}
+
+	// ready are chats whose first update isn't handled by anybody yet
+	ready chan int64
+
+	mu sync.Mutex
+	// updates of chats which are being handled or are ready, the first update of chat is handled first
+	queueByChatID map[int64][]tgbotapi.Update
+}
+
+func New(handle Handler, workers, queueSize int) *Dispatcher {
+	return &Dispatcher{
+		handle:        handle,
+		workers:       workers,
+		slots:         make(chan struct{}, queueSize),
+		ready:         make(chan int64, queueSize),
+		queueByChatID: make(map[int64][]tgbotapi.Update),
+	}
+}
+
+// Run dispatches updates until ctx is done, then it waits for the workers to finish the updates they are handling
+func (d *Dispatcher) Run(ctx context.Context, updates <-chan tgbotapi.Update) {
+	logrus.Infof("dispatcher started with %d workers", d.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Infof("dispatcher stopped: %s", ctx.Err().Error())
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			d.dispatch(ctx, update)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, update tgbotapi.Update) {
+	select {
+	case <-ctx.Done():
+		return
+	case d.slots <- struct{}{}:
+	}
+	queuedUpdates.Inc()
+
+	chatID := chatOf(update)
+	d.mu.Lock()
+	queue, busy := d.queueByChatID[chatID]
+	d.queueByChatID[chatID] = append(queue, update)
+	d.mu.Unlock()
+	// every chat in the map has a slot, so the ready channel is never full
+	if !busy {
+		d.ready <- chatID
+	}
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chatID := <-d.ready:
+			d.mu.Lock()
+			update := d.queueByChatID[chatID][0]
+			d.mu.Unlock()
+
+			d.handleUpdate(ctx, update)
+
+			<-d.slots
+			queuedUpdates.Dec()
+			d.mu.Lock()
+			queue := d.queueByChatID[chatID][1:]
+			if len(queue) == 0 {
+				delete(d.queueByChatID, chatID)
+			} else {
+				d.queueByChatID[chatID] = queue
+			}
+			d.mu.Unlock()
+			if len(queue) != 0 {
+				d.ready <- chatID
+			}
+		}
+	}
+}
+
+// handleUpdate doesn't let the panic in the handler stop the worker
+func (d *Dispatcher) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	busyWorkers.Inc()
+	defer func() {
+		busyWorkers.Dec()
+		handledUpdates.Inc()
+		if r := recover(); r != nil {
+			logrus.Errorf("dispatcher: panic while handling update %d: %v", update.UpdateID, r)
+		}
+	}()
+	d.handle(ctx, update)
+}
+
+// chatOf returns the chat of the update or the user if the update isn't from chat, e.g. the inline query
+func chatOf(update tgbotapi.Update) int64 {
+	if chat := update.FromChat(); chat != nil {
+		return chat.ID
+	}
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+	return 0
+}
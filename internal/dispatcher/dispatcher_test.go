@@ -0,0 +1,122 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func message(chatID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		From: &tgbotapi.User{ID: chatID},
+		Chat: &tgbotapi.Chat{ID: chatID},
+		Text: text,
+	}}
+}
+
+func TestDispatcherKeepsOrderOfChat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	textsByChatID := make(map[int64][]string)
+	var wg sync.WaitGroup
+	handle := func(ctx context.Context, update tgbotapi.Update) {
+		defer wg.Done()
+		// the first updates are slower to let the later ones overtake them if the order isn't kept
+		if update.Message.Text == "0" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		mu.Lock()
+		textsByChatID[update.Message.Chat.ID] = append(textsByChatID[update.Message.Chat.ID], update.Message.Text)
+		mu.Unlock()
+	}
+
+	updates := make(chan tgbotapi.Update)
+	go New(handle, 4, 10).Run(ctx, updates)
+	texts := []string{"0", "1", "2", "3", "4"}
+	for _, text := range texts {
+		for chatID := int64(1); chatID <= 3; chatID++ {
+			wg.Add(1)
+			updates <- message(chatID, text)
+		}
+	}
+	wg.Wait()
+
+	for chatID := int64(1); chatID <= 3; chatID++ {
+		got := textsByChatID[chatID]
+		if len(got) != len(texts) {
+			t.Fatalf("chat %d: got %v, want %v", chatID, got, texts)
+		}
+		for i := range texts {
+			if got[i] != texts[i] {
+				t.Fatalf("chat %d: got %v, want %v", chatID, got, texts)
+			}
+		}
+	}
+}
+
+func TestDispatcherDoesNotBlockOtherChats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+	handled := make(chan int64)
+	handle := func(ctx context.Context, update tgbotapi.Update) {
+		if update.Message.Chat.ID == 1 {
+			<-release
+		}
+		handled <- update.Message.Chat.ID
+	}
+
+	updates := make(chan tgbotapi.Update)
+	go New(handle, 2, 10).Run(ctx, updates)
+	updates <- message(1, "slow")
+	updates <- message(1, "waits for the slow one")
+	updates <- message(2, "fast")
+
+	select {
+	case chatID := <-handled:
+		if chatID != 2 {
+			t.Fatalf("handled chat %d, want 2", chatID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update of chat 2 is blocked by chat 1")
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if chatID := <-handled; chatID != 1 {
+			t.Fatalf("handled chat %d, want 1", chatID)
+		}
+	}
+}
+
+func TestDispatcherRecoversFromPanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handled := make(chan string)
+	handle := func(ctx context.Context, update tgbotapi.Update) {
+		if update.Message.Text == "panic" {
+			panic("handler failed")
+		}
+		handled <- update.Message.Text
+	}
+
+	updates := make(chan tgbotapi.Update)
+	go New(handle, 1, 10).Run(ctx, updates)
+	updates <- message(1, "panic")
+	updates <- message(1, "next")
+
+	select {
+	case text := <-handled:
+		if text != "next" {
+			t.Fatalf("handled %q, want next", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("worker is stopped by the panic")
+	}
+}
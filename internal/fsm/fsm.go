@@ -0,0 +1,191 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// separator separates the flow and the step in the action of the conversation
+const separator = "/"
+
+// Answers are the answers of user by names of steps
+type Answers map[string]string
+
+// Step is the state of the flow: the bot sends the prompt and waits for the answer
+type Step struct {
+	Name   string
+	Prompt string
+
+	// TTL is how long the answer is waited for, zero is storage.DefaultTTL
+	TTL time.Duration
+
+	// Handle is called with the valid answer, e.g. to save it. If it returns Invalid, user is asked again. Optional
+	Handle func(ctx context.Context, userTelegramID int64, answer string, answers Answers) error
+}
+
+// Flow is the dialog which is started by the command and goes through the steps one by one
+type Flow struct {
+	Command string
+
+	// Start is called before the first step. If it fails, the flow isn't started. Optional
+	Start func(ctx context.Context, user *tgbotapi.User, chatID int64) error
+
+	Steps []*Step
+
+	// Complete is called when all steps are answered, it returns messages for user
+	Complete func(ctx context.Context, userTelegramID int64, answers Answers) ([]string, error)
+}
+
+type invalidAnswer struct {
+	text string
+}
+
+func (e *invalidAnswer) Error() string {
+	return e.text
+}
+
+// Invalid is returned by steps if the answer is wrong. The text is sent to user and the step is asked again
+func Invalid(text string) error {
+	return &invalidAnswer{text: text}
+}
+
+// Machine starts flows by their commands and passes answers of users to the steps they are at
+type Machine struct {
+	bot             messenger.Messenger
+	msgStore        *storage.Messages
+	flowsByCommands map[string]*Flow
+}
+
+func New(bot messenger.Messenger, msgStore *storage.Messages, flows ...*Flow) *Machine {
+	flowsByCommands := make(map[string]*Flow, len(flows))
+	for _, flow := range flows {
+		flowsByCommands[flow.Command] = flow
+	}
+	return &Machine{
+		bot:             bot,
+		msgStore:        msgStore,
+		flowsByCommands: flowsByCommands,
+	}
+}
+
+// Starts returns true if the command starts one of the flows
+func (m *Machine) Starts(command string) bool {
+	_, ok := m.flowsByCommands[command]
+	return ok
+}
+
+// Start starts the flow of the command. The previous flow of user is forgotten
+func (m *Machine) Start(ctx context.Context, command string, user *tgbotapi.User, chatID int64) error {
+	flow, ok := m.flowsByCommands[command]
+	if !ok {
+		return fmt.Errorf("there is no flow %s", command)
+	}
+	if flow.Start != nil {
+		err := flow.Start(ctx, user, chatID)
+		if err != nil {
+			return fmt.Errorf("%s: start: %w", command, err)
+		}
+	}
+	return m.ask(ctx, user.ID, chatID, flow, 0, Answers{}, "")
+}
+
+// Handle passes the answer to the step of the conversation. It returns false if the conversation isn't one of the flows
+func (m *Machine) Handle(ctx context.Context, conversation *model.Conversation, message *tgbotapi.Message) (bool, error) {
+	flow, i := m.step(conversation.Action)
+	if flow == nil {
+		return false, nil
+	}
+	answers := Answers{}
+	if conversation.DataOnFirstStep != "" {
+		err := json.Unmarshal([]byte(conversation.DataOnFirstStep), &answers)
+		if err != nil {
+			return true, fmt.Errorf("%s: unmarshal: %w", conversation.Action, err)
+		}
+	}
+
+	var (
+		userTelegramID = message.From.ID
+		chatID         = message.Chat.ID
+		step           = flow.Steps[i]
+		answer         = strings.TrimSpace(message.Text)
+	)
+	if step.Handle != nil {
+		err := step.Handle(ctx, userTelegramID, answer, answers)
+		var invalid *invalidAnswer
+		if errors.As(err, &invalid) {
+			return true, m.ask(ctx, userTelegramID, chatID, flow, i, answers, invalid.text)
+		}
+		if err != nil {
+			return true, fmt.Errorf("%s: %w", conversation.Action, err)
+		}
+	}
+	answers[step.Name] = answer
+	if i+1 < len(flow.Steps) {
+		return true, m.ask(ctx, userTelegramID, chatID, flow, i+1, answers, "")
+	}
+
+	texts, err := flow.Complete(ctx, userTelegramID, answers)
+	if err != nil {
+		return true, fmt.Errorf("%s: complete: %w", flow.Command, err)
+	}
+	for _, text := range texts {
+		_, err = m.bot.Send(tgbotapi.NewMessage(chatID, text))
+		if err != nil {
+			return true, fmt.Errorf("send: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// ask sends the prompt of the step, after the reason why the step is asked again if it's set, and waits for the answer
+func (m *Machine) ask(ctx context.Context, userTelegramID, chatID int64, flow *Flow, i int, answers Answers, reason string) error {
+	step := flow.Steps[i]
+	text := step.Prompt
+	if reason != "" {
+		text = reason
+	}
+	_, err := m.bot.Send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+
+	data, err := json.Marshal(answers)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	err = m.msgStore.Wait(ctx, userTelegramID, &model.Conversation{
+		Action:          flow.Command + separator + step.Name,
+		DataOnFirstStep: string(data),
+	}, step.TTL)
+	if err != nil {
+		return fmt.Errorf("wait: %w", err)
+	}
+	return nil
+}
+
+// step returns the flow and the index of the step by the action of the conversation, nil if it isn't the flow's action
+func (m *Machine) step(action string) (*Flow, int) {
+	command, name, ok := strings.Cut(action, separator)
+	if !ok {
+		return nil, 0
+	}
+	flow, ok := m.flowsByCommands[command]
+	if !ok {
+		return nil, 0
+	}
+	for i, step := range flow.Steps {
+		if step.Name == name {
+			return flow, i
+		}
+	}
+	return nil, 0
+}
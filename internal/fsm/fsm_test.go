@@ -0,0 +1,151 @@
+package fsm
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const userID = 1
+
+type dialog struct {
+	t        *testing.T
+	clock    *clock.Fake
+	bot      *messenger.Fake
+	msgStore *storage.Messages
+	machine  *Machine
+}
+
+func newDialog(t *testing.T, flows ...*Flow) *dialog {
+	clk := clock.NewFake(time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC))
+	bot := messenger.NewFake(clk)
+	msgStore := storage.NewMessage(storage.NewMemory(), clk)
+	return &dialog{t: t, clock: clk, bot: bot, msgStore: msgStore, machine: New(bot, msgStore, flows...)}
+}
+
+// answer passes the text to the machine and returns the last message of the bot, empty if user isn't in the flow
+func (d *dialog) answer(text string) string {
+	d.t.Helper()
+	ctx := context.Background()
+	conversation, err := d.msgStore.Extract(ctx, userID)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	if conversation == nil {
+		return ""
+	}
+	handled, err := d.machine.Handle(ctx, conversation, &tgbotapi.Message{
+		From: &tgbotapi.User{ID: userID},
+		Chat: &tgbotapi.Chat{ID: userID},
+		Text: text,
+	})
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	if !handled {
+		d.t.Fatalf("conversation %s isn't handled", conversation.Action)
+	}
+	return d.last()
+}
+
+func (d *dialog) last() string {
+	msg, _ := d.bot.LastMessage(userID)
+	return msg.Text
+}
+
+func orderFlow(completed *Answers) *Flow {
+	return &Flow{
+		Command: "order",
+		Steps: []*Step{
+			{Name: "dish", Prompt: "Что заказать?"},
+			{
+				Name:   "quantity",
+				Prompt: "Сколько?",
+				TTL:    time.Minute,
+				Handle: func(_ context.Context, _ int64, answer string, answers Answers) error {
+					if _, err := strconv.Atoi(answer); err != nil {
+						return Invalid("Введите число")
+					}
+					if answers["dish"] == "" {
+						return Invalid("Блюдо не выбрано")
+					}
+					return nil
+				},
+			},
+		},
+		Complete: func(_ context.Context, _ int64, answers Answers) ([]string, error) {
+			*completed = answers
+			return []string{"Готово", answers["quantity"] + " × " + answers["dish"]}, nil
+		},
+	}
+}
+
+func TestFlow(t *testing.T) {
+	var completed Answers
+	d := newDialog(t, orderFlow(&completed))
+	if !d.machine.Starts("order") || d.machine.Starts("menu") {
+		t.Fatal("Starts() doesn't match commands of flows")
+	}
+
+	err := d.machine.Start(context.Background(), "order", &tgbotapi.User{ID: userID}, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.last(); got != "Что заказать?" {
+		t.Errorf("first prompt = %q", got)
+	}
+	if got := d.answer("Борщ"); got != "Сколько?" {
+		t.Errorf("second prompt = %q", got)
+	}
+	if got := d.answer("два"); got != "Введите число" {
+		t.Errorf("invalid answer reply = %q", got)
+	}
+	if got := d.answer(" 2 "); got != "2 × Борщ" {
+		t.Errorf("completion = %q", got)
+	}
+	if completed["dish"] != "Борщ" || completed["quantity"] != "2" {
+		t.Errorf("completed with %v", completed)
+	}
+	if got := d.answer("3"); got != "" {
+		t.Errorf("answer after completion is handled: %q", got)
+	}
+}
+
+func TestFlowExpired(t *testing.T) {
+	var completed Answers
+	d := newDialog(t, orderFlow(&completed))
+	err := d.machine.Start(context.Background(), "order", &tgbotapi.User{ID: userID}, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.answer("Борщ")
+
+	d.clock.Add(2 * time.Minute)
+	d.answer("2")
+	if completed != nil {
+		t.Errorf("expired flow is completed with %v", completed)
+	}
+}
+
+func TestHandleOtherConversation(t *testing.T) {
+	var completed Answers
+	d := newDialog(t, orderFlow(&completed))
+	err := d.msgStore.WaitMessage(context.Background(), userID, storage.SaveTemplate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversation, err := d.msgStore.Extract(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled, err := d.machine.Handle(context.Background(), conversation, &tgbotapi.Message{})
+	if err != nil || handled {
+		t.Errorf("Handle() = %t, %v for the conversation of another action", handled, err)
+	}
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Check returns an error if the dependency isn't available
+type Check func(ctx context.Context) error
+
+// Live answers while the process is running, it doesn't check dependencies
+func Live(w http.ResponseWriter, _ *http.Request) {
+	_, err := io.WriteString(w, "ok\n")
+	if err != nil {
+		logrus.Errorf("health: live: %s", err.Error())
+	}
+}
+
+// Ready answers ok if all checks pass in the timeout, otherwise it answers 503 with failed checks
+type Ready struct {
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+func NewReady(timeout time.Duration, checks map[string]Check) *Ready {
+	return &Ready{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+func (r *Ready) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), r.timeout)
+	defer cancel()
+
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := http.StatusOK
+	var body string
+	for _, name := range names {
+		err := run(ctx, r.checks[name])
+		if err != nil {
+			logrus.Warnf("health: %s isn't ready: %s", name, err.Error())
+			status = http.StatusServiceUnavailable
+			body = fmt.Sprintf("%s%s: %s\n", body, name, err.Error())
+			continue
+		}
+		body = fmt.Sprintf("%s%s: ok\n", body, name)
+	}
+	w.WriteHeader(status)
+	_, err := io.WriteString(w, body)
+	if err != nil {
+		logrus.Errorf("health: ready: %s", err.Error())
+	}
+}
+
+// run stops waiting for the check when ctx is done, even if the check doesn't use ctx
+func run(ctx context.Context, check Check) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- check(ctx)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errChan:
+		return err
+	}
+}
+
+// Metrics writes all metrics in the text format of prometheus
+func Metrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := metrics.Write(w)
+	if err != nil {
+		logrus.Errorf("health: metrics: %s", err.Error())
+	}
+}
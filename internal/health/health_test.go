@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	hanging := func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	tests := []struct {
+		name   string
+		checks map[string]Check
+		code   int
+		body   string
+	}{
+		{
+			name:   "all ok",
+			checks: map[string]Check{"telegram": ok, "database": ok},
+			code:   http.StatusOK,
+			body:   "database: ok\ntelegram: ok\n",
+		},
+		{
+			name:   "database is down",
+			checks: map[string]Check{"telegram": ok, "database": down},
+			code:   http.StatusServiceUnavailable,
+			body:   "database: connection refused\ntelegram: ok\n",
+		},
+		{
+			name:   "telegram doesn't answer",
+			checks: map[string]Check{"telegram": hanging},
+			code:   http.StatusServiceUnavailable,
+			body:   "telegram: context deadline exceeded\n",
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		NewReady(50*time.Millisecond, tt.checks).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
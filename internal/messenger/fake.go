@@ -0,0 +1,217 @@
+package messenger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Message is the message which was sent to the fake
+type Message struct {
+	ID     int
+	ChatID int64
+
+	// Text is the caption if the message is the photo
+	Text     string
+	Photo    bool
+	Document string
+	Keyboard *tgbotapi.InlineKeyboardMarkup
+	Deleted  bool
+	SentAt   time.Time
+}
+
+// Button returns callback data of the button whose text contains the text
+func (m Message) Button(text string) (string, bool) {
+	if m.Keyboard == nil {
+		return "", false
+	}
+	for _, row := range m.Keyboard.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && strings.Contains(button.Text, text) {
+				return *button.CallbackData, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Telegram returns the message the way telegram sends it in updates
+func (m Message) Telegram() *tgbotapi.Message {
+	msg := &tgbotapi.Message{
+		MessageID: m.ID,
+		Chat:      &tgbotapi.Chat{ID: m.ChatID},
+		Text:      m.Text,
+		Date:      int(m.SentAt.Unix()),
+	}
+	if m.Photo {
+		msg.Text = ""
+		msg.Caption = m.Text
+		msg.Photo = []tgbotapi.PhotoSize{{FileID: "photo"}}
+	}
+	if m.Keyboard != nil {
+		msg.ReplyMarkup = m.Keyboard
+	}
+	return msg
+}
+
+// Answer is the answer to the callback query
+type Answer struct {
+	CallbackID string
+	Text       string
+	Alert      bool
+}
+
+// Fake records messages instead of sending them to telegram. It's used in tests
+type Fake struct {
+	mu       sync.Mutex
+	clock    clock.Clock
+	messages []*Message
+	answers  []Answer
+
+	// err is returned by Send instead of sending the message
+	err error
+}
+
+func NewFake(clock clock.Clock) *Fake {
+	return &Fake{
+		clock: clock,
+	}
+}
+
+// Fail makes Send return the error until Fail(nil) is called, it's used to test retries
+func (f *Fake) Fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *Fake) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("send: %w", f.err)
+	}
+	var msg *Message
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		msg = f.add(config.ChatID, config.Text, config.ReplyMarkup)
+	case tgbotapi.PhotoConfig:
+		msg = f.add(config.ChatID, config.Caption, config.ReplyMarkup)
+		msg.Photo = true
+	default:
+		return tgbotapi.Message{}, fmt.Errorf("send: unsupported %T", c)
+	}
+	return *msg.Telegram(), nil
+}
+
+func (f *Fake) Edit(c tgbotapi.Chattable) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch config := c.(type) {
+	case tgbotapi.EditMessageTextConfig:
+		msg, err := f.find(config.ChatID, config.MessageID)
+		if err != nil {
+			return err
+		}
+		if msg.Photo {
+			return fmt.Errorf("edit: there is no text in the message to edit")
+		}
+		msg.Text = config.Text
+		msg.Keyboard = config.ReplyMarkup
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		msg, err := f.find(config.ChatID, config.MessageID)
+		if err != nil {
+			return err
+		}
+		msg.Keyboard = config.ReplyMarkup
+	default:
+		return fmt.Errorf("edit: unsupported %T", c)
+	}
+	return nil
+}
+
+func (f *Fake) Delete(chatID int64, messageID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msg, err := f.find(chatID, messageID)
+	if err != nil {
+		return err
+	}
+	msg.Deleted = true
+	return nil
+}
+
+func (f *Fake) AnswerCallback(callbackID, text string, alert bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answers = append(f.answers, Answer{CallbackID: callbackID, Text: text, Alert: alert})
+	return nil
+}
+
+func (f *Fake) SendDocument(chatID int64, name string, _ []byte) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	msg := f.add(chatID, "", nil)
+	msg.Document = name
+	return *msg.Telegram(), nil
+}
+
+// Messages returns messages of the chat which aren't deleted in the order they were sent
+func (f *Fake) Messages(chatID int64) []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var messages []Message
+	for _, msg := range f.messages {
+		if msg.ChatID == chatID && !msg.Deleted {
+			messages = append(messages, *msg)
+		}
+	}
+	return messages
+}
+
+// LastMessage returns the last message of the chat which isn't deleted
+func (f *Fake) LastMessage(chatID int64) (Message, bool) {
+	messages := f.Messages(chatID)
+	if len(messages) == 0 {
+		return Message{}, false
+	}
+	return messages[len(messages)-1], true
+}
+
+func (f *Fake) Answers() []Answer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Answer(nil), f.answers...)
+}
+
+// add must be called under the lock. Message IDs are unique across chats, it's enough for tests
+func (f *Fake) add(chatID int64, text string, markup interface{}) *Message {
+	msg := &Message{
+		ID:     len(f.messages) + 1,
+		ChatID: chatID,
+		Text:   text,
+		SentAt: f.clock.Now(),
+	}
+	switch keyboard := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		msg.Keyboard = &keyboard
+	case *tgbotapi.InlineKeyboardMarkup:
+		msg.Keyboard = keyboard
+	}
+	f.messages = append(f.messages, msg)
+	return msg
+}
+
+// find must be called under the lock
+func (f *Fake) find(chatID int64, messageID int) (*Message, error) {
+	for _, msg := range f.messages {
+		if msg.ID == messageID && msg.ChatID == chatID && !msg.Deleted {
+			return msg, nil
+		}
+	}
+	return nil, fmt.Errorf("message to edit not found")
+}
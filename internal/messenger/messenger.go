@@ -0,0 +1,108 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chucky-1/food-delivery-bot/internal/metrics"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const messageIsNotModified = "message is not modified"
+
+var sendFailures = metrics.NewCounter("bot_send_failures_total", "Requests to telegram which have failed")
+
+// Messenger is the part of telegram Bot API which consumers and producers use
+type Messenger interface {
+	// Send sends the message or the photo
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+
+	// Edit changes the text or the buttons of the sent message. It isn't an error if nothing is changed
+	Edit(c tgbotapi.Chattable) error
+
+	Delete(chatID int64, messageID int) error
+	AnswerCallback(callbackID, text string, alert bool) error
+	SendDocument(chatID int64, name string, data []byte) (tgbotapi.Message, error)
+}
+
+type telegram struct {
+	bot *tgbotapi.BotAPI
+}
+
+func New(bot *tgbotapi.BotAPI) *telegram {
+	return &telegram{
+		bot: bot,
+	}
+}
+
+// Ping calls getMe of Bot API. Unlike the calls of the library, the request is cancelled when ctx is done
+func (t *telegram) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(tgbotapi.APIEndpoint, t.bot.Token, "getMe"), nil)
+	if err != nil {
+		return fmt.Errorf("newRequest: %w", err)
+	}
+	resp, err := t.bot.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+	var apiResp tgbotapi.APIResponse
+	err = json.NewDecoder(resp.Body).Decode(&apiResp)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	if !apiResp.Ok {
+		return fmt.Errorf("getMe: %s", apiResp.Description)
+	}
+	return nil
+}
+
+func (t *telegram) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg, err := t.bot.Send(c)
+	if err != nil {
+		sendFailures.Inc()
+		return tgbotapi.Message{}, fmt.Errorf("send: %w", err)
+	}
+	return msg, nil
+}
+
+func (t *telegram) Edit(c tgbotapi.Chattable) error {
+	_, err := t.bot.Send(c)
+	if err != nil && !strings.Contains(err.Error(), messageIsNotModified) {
+		sendFailures.Inc()
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+func (t *telegram) Delete(chatID int64, messageID int) error {
+	_, err := t.bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+	if err != nil {
+		sendFailures.Inc()
+		return fmt.Errorf("request: %w", err)
+	}
+	return nil
+}
+
+func (t *telegram) AnswerCallback(callbackID, text string, alert bool) error {
+	callback := tgbotapi.NewCallback(callbackID, text)
+	callback.ShowAlert = alert
+	_, err := t.bot.Request(callback)
+	if err != nil {
+		sendFailures.Inc()
+		return fmt.Errorf("request: %w", err)
+	}
+	return nil
+}
+
+func (t *telegram) SendDocument(chatID int64, name string, data []byte) (tgbotapi.Message, error) {
+	msg, err := t.bot.Send(tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data}))
+	if err != nil {
+		sendFailures.Inc()
+		return tgbotapi.Message{}, fmt.Errorf("send: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,52 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeClient answers getMe with the body, or hangs until the request is cancelled if there is no body
+type fakeClient struct {
+	body string
+}
+
+func (f *fakeClient) Do(req *http.Request) (*http.Response, error) {
+	if f.body == "" {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(f.body))}, nil
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "ok", body: `{"ok":true,"result":{"id":1,"is_bot":true}}`},
+		{name: "unauthorized", body: `{"ok":false,"error_code":401,"description":"Unauthorized"}`, wantErr: true},
+		{name: "hanging", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := New(&tgbotapi.BotAPI{Token: "token", Client: &fakeClient{body: tt.body}})
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := bot.Ping(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ping() = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.body == "" && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Ping() = %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	gaugeType   = "gauge"
+	counterType = "counter"
+	summaryType = "summary"
+)
+
+type metric struct {
+	name string
+	help string
+	kind string
+
+	// labels are written in braces after the name, metrics with the same name and different labels are one family
+	labels string
+	value  atomic.Int64
+
+	// sum and count are written instead of the value by summaries
+	sum   atomic.Int64
+	count atomic.Int64
+}
+
+func (m *metric) write(w io.Writer) error {
+	if m.kind != summaryType {
+		_, err := fmt.Fprintf(w, "%s%s %d\n", m.name, m.labels, m.value.Load())
+		return err
+	}
+	seconds := time.Duration(m.sum.Load()).Seconds()
+	_, err := fmt.Fprintf(w, "%s_sum%s %g\n%s_count%s %d\n", m.name, m.labels, seconds, m.name, m.labels, m.count.Load())
+	return err
+}
+
+var (
+	mu       sync.Mutex
+	registry = make(map[string]*metric)
+)
+
+func register(name, help, kind string, labels []string) *metric {
+	key := name + formatLabels(labels)
+	mu.Lock()
+	defer mu.Unlock()
+	if m, ok := registry[key]; ok {
+		return m
+	}
+	m := &metric{name: name, help: help, kind: kind, labels: formatLabels(labels)}
+	registry[key] = m
+	return m
+}
+
+// formatLabels turns pairs of names and values into {name="value",...}, the last name without value is skipped
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Gauge is the value which goes up and down, e.g. the number of queued updates
+type Gauge struct {
+	m *metric
+}
+
+// NewGauge returns the gauge with the name and labels given as pairs of names and values.
+// The gauge with the same name and labels is shared
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{m: register(name, help, gaugeType, labels)}
+}
+
+func (g *Gauge) Inc() {
+	g.m.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.m.value.Add(-1)
+}
+
+func (g *Gauge) Set(value int64) {
+	g.m.value.Store(value)
+}
+
+func (g *Gauge) Value() int64 {
+	return g.m.value.Load()
+}
+
+// Counter is the value which only goes up, e.g. the number of handled updates
+type Counter struct {
+	m *metric
+}
+
+// NewCounter returns the counter with the name and labels given as pairs of names and values.
+// The counter with the same name and labels is shared
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{m: register(name, help, counterType, labels)}
+}
+
+func (c *Counter) Inc() {
+	c.m.value.Add(1)
+}
+
+// Add adds n to the counter, n must not be negative
+func (c *Counter) Add(n int64) {
+	c.m.value.Add(n)
+}
+
+func (c *Counter) Value() int64 {
+	return c.m.value.Load()
+}
+
+// Summary is the sum and the count of observed durations, e.g. of database queries. The sum is written in seconds
+type Summary struct {
+	m *metric
+}
+
+// NewSummary returns the summary with the name and labels given as pairs of names and values.
+// The summary with the same name and labels is shared
+func NewSummary(name, help string, labels ...string) *Summary {
+	return &Summary{m: register(name, help, summaryType, labels)}
+}
+
+func (s *Summary) Observe(d time.Duration) {
+	s.m.sum.Add(int64(d))
+	s.m.count.Add(1)
+}
+
+// Since observes the duration from the start, it's handy with defer
+func (s *Summary) Since(start time.Time) {
+	s.Observe(time.Since(start))
+}
+
+func (s *Summary) Count() int64 {
+	return s.m.count.Load()
+}
+
+// Write writes all metrics in the text format of prometheus sorted by names
+func Write(w io.Writer) error {
+	mu.Lock()
+	metrics := make([]*metric, 0, len(registry))
+	for _, m := range registry {
+		metrics = append(metrics, m)
+	}
+	mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].name != metrics[j].name {
+			return metrics[i].name < metrics[j].name
+		}
+		return metrics[i].labels < metrics[j].labels
+	})
+	for i, m := range metrics {
+		if i == 0 || metrics[i-1].name != m.name {
+			_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			if err != nil {
+				return fmt.Errorf("write: %w", err)
+			}
+		}
+		err := m.write(w)
+		if err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	sent := NewCounter("test_messages_total", "Messages", "result", "sent")
+	failed := NewCounter("test_messages_total", "Messages", "result", "failed")
+	queued := NewGauge("test_queued", "Queued")
+	latency := NewSummary("test_latency_seconds", "Latency")
+
+	sent.Inc()
+	sent.Inc()
+	failed.Add(2)
+	NewCounter("test_messages_total", "Messages", "result", "sent").Inc()
+	queued.Set(5)
+	queued.Dec()
+	latency.Observe(1500 * time.Millisecond)
+	latency.Observe(500 * time.Millisecond)
+
+	var b strings.Builder
+	err := Write(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_latency_seconds Latency
+# TYPE test_latency_seconds summary
+test_latency_seconds_sum 2
+test_latency_seconds_count 2
+# HELP test_messages_total Messages
+# TYPE test_messages_total counter
+test_messages_total{result="failed"} 2
+test_messages_total{result="sent"} 3
+# HELP test_queued Queued
+# TYPE test_queued gauge
+test_queued 4
+`
+	if b.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
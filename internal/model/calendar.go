@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarDay is a holiday or an extra working day. Nil OrganizationID means the day is for all organizations
+type CalendarDay struct {
+	Date           time.Time
+	OrganizationID uuid.UUID
+	Working        bool
+}
+
+// UsuallyWorking returns true if the date is a working day without any calendar days: monday - friday
+func UsuallyWorking(date time.Time) bool {
+	day := date.Weekday()
+	return day != time.Saturday && day != time.Sunday
+}
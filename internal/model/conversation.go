@@ -0,0 +1,12 @@
+package model
+
+// Conversation is the step of the flow when the bot waits for the message of user to do the action
+type Conversation struct {
+	Action string
+
+	// Data if action has 2 step
+	DataOnFirstStep string
+
+	// Dish which is being created or edited if action has more than 2 steps
+	Dish *Dish
+}
@@ -1,22 +1,109 @@
 package model
 
-import "fmt"
-
-const (
-	Soups      = "Супы"
-	Salads     = "Салаты"
-	MainCourse = "Основные блюда"
-	Desserts   = "Десерты"
-	Drinks     = "Напитки"
+import (
+	"fmt"
+	"strings"
+	"time"
 )
 
+type Category struct {
+	ID        int
+	Name      string
+	SortOrder int
+	Emoji     string
+	Visible   bool
+}
+
+// String returns the text of the category button
+func (c *Category) String() string {
+	if c.Emoji == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s %s", c.Emoji, c.Name)
+}
+
 type Dish struct {
-	Name     string
-	Price    float32
-	Category string
-	Stop     bool
+	ID          int
+	Name        string
+	Price       float32
+	Category    string
+	Stop        bool
+	Description string
+
+	// Photo is telegram file_id or URL of the photo
+	Photo string
+	// Weight of the portion in grams
+	Weight    int
+	Calories  int
+	Allergens []string
 }
 
 func (d *Dish) String() string {
 	return fmt.Sprintf("%s - %.2f", d.Name, d.Price)
 }
+
+func (d *Dish) HasDetails() bool {
+	return d.Description != "" || d.Photo != "" || d.Weight != 0 || d.Calories != 0 || len(d.Allergens) != 0
+}
+
+// Details returns the description of the dish shown to customers before they add it in order
+func (d *Dish) Details() string {
+	details := d.String()
+	if d.Description != "" {
+		details = fmt.Sprintf("%s\n\n%s", details, d.Description)
+	}
+	var info []string
+	if d.Weight != 0 {
+		info = append(info, fmt.Sprintf("Вес: %d г", d.Weight))
+	}
+	if d.Calories != 0 {
+		info = append(info, fmt.Sprintf("Калорийность: %d ккал", d.Calories))
+	}
+	if len(d.Allergens) != 0 {
+		info = append(info, fmt.Sprintf("Аллергены: %s", strings.Join(d.Allergens, ", ")))
+	}
+	if len(info) != 0 {
+		details = fmt.Sprintf("%s\n\n%s", details, strings.Join(info, "\n"))
+	}
+	return details
+}
+
+// Button returns the text of the dish button. ID at the end of the text lets us find the dish
+// even after it has been renamed or its price has been changed
+func (d *Dish) Button() string {
+	return fmt.Sprintf("%s #%d", d.String(), d.ID)
+}
+
+// MenuDay is a day the menu is planned for: a particular date or the same weekday every week
+type MenuDay struct {
+	Date    time.Time
+	Weekday time.Weekday
+	Weekly  bool
+}
+
+func (d *MenuDay) String() string {
+	if d.Weekly {
+		return fmt.Sprintf("%s (каждую неделю)", TranslateWeekday(d.Weekday))
+	}
+	return d.Date.Format("02.01.2006")
+}
+
+func TranslateWeekday(weekday time.Weekday) string {
+	switch weekday {
+	case time.Monday:
+		return "Понедельник"
+	case time.Tuesday:
+		return "Вторник"
+	case time.Wednesday:
+		return "Среда"
+	case time.Thursday:
+		return "Четверг"
+	case time.Friday:
+		return "Пятница"
+	case time.Saturday:
+		return "Суббота"
+	case time.Sunday:
+		return "Воскресенье"
+	}
+	return ""
+}
@@ -1,5 +1,33 @@
 package model
 
+import "time"
+
+// Statuses of the order of user for the day. The order is the draft until user confirms it, the confirmed order is
+// sent to the kitchen with the shipment of the organization and then the admin moves the shipment through the rest
+// of statuses. The order can be cancelled until it's sent to the kitchen
+const (
+	OrderDraft          = "draft"
+	OrderConfirmed      = "confirmed"
+	OrderSentToKitchen  = "sent_to_kitchen"
+	OrderCooking        = "cooking"
+	OrderOutForDelivery = "out_for_delivery"
+	OrderDelivered      = "delivered"
+	OrderCancelled      = "cancelled"
+)
+
+// NextOrderStatus returns the status the admin moves the shipped order to, empty if the order can't be moved
+func NextOrderStatus(status string) string {
+	switch status {
+	case OrderSentToKitchen:
+		return OrderCooking
+	case OrderCooking:
+		return OrderOutForDelivery
+	case OrderOutForDelivery:
+		return OrderDelivered
+	}
+	return ""
+}
+
 type DishWithCount struct {
 	*Dish
 	Count int
@@ -8,5 +36,29 @@ type DishWithCount struct {
 type OrderingData struct {
 	OrganizationName    string
 	OrganizationAddress string
-	DishesByCategories  map[string][]*DishWithCount
+
+	// LunchTime is local for the organization, Timezone is empty if the organization has the time zone of the bot
+	LunchTime time.Duration
+	Timezone  string
+
+	// Date is the day of orders, it's local for the organization
+	Date time.Time
+
+	DishesByCategories map[string][]*DishWithCount
+}
+
+// DayOrder is the confirmed order of user for the day
+type DayOrder struct {
+	Number int
+	Date   time.Time
+	Status string
+	Dishes []*DishWithCount
+}
+
+func (o *DayOrder) Total() float32 {
+	var total float32
+	for _, dish := range o.Dishes {
+		total += dish.Price * float32(dish.Count)
+	}
+	return total
 }
@@ -10,4 +10,13 @@ type Organization struct {
 	ID        uuid.UUID
 	Name      string
 	LunchTime time.Duration
+
+	// Timezone is the IANA time zone of the organization, empty means the time zone of the bot
+	Timezone string
+}
+
+// LunchTime is the time organizations have lunch at in the time zone, empty Timezone means the time zone of the bot
+type LunchTime struct {
+	Time     time.Duration
+	Timezone string
 }
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of shipments. The admin gets two messages for every lunch slot: orders of organizations and the total,
+// listed means the first one is sent, so only the total is sent on retry
+const (
+	ShipmentPending = "pending"
+	ShipmentListed  = "listed"
+	ShipmentSent    = "sent"
+)
+
+// Shipment is the batch of confirmed orders of the organization for its lunch on the date
+type Shipment struct {
+	ID             int
+	OrganizationID uuid.UUID
+
+	// Date is local for the organization
+	Date time.Time
+
+	// ShipAt is when orders are shipped, shipments with the same ShipAt are sent to the admin together
+	ShipAt   time.Time
+	Status   string
+	Attempts int
+
+	// OrderStatus is the status of orders of the shipment, they are moved together
+	OrderStatus string
+
+	*OrderingData
+}
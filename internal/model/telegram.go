@@ -1,7 +1,17 @@
 package model
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type TelegramUser struct {
 	ID       int64
 	ChatID   int64
 	Username string
+
+	// OrganizationID and Today, the local date of the organization, are filled only for reminders
+	OrganizationID uuid.UUID
+	Today          time.Time
 }
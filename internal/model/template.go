@@ -0,0 +1,8 @@
+package model
+
+// Template is the saved order which user can add in order with one tap
+type Template struct {
+	ID     int
+	Name   string
+	Dishes []*DishWithCount
+}
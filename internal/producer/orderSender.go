@@ -3,101 +3,175 @@ package producer
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
 	"github.com/chucky-1/food-delivery-bot/internal/service"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sirupsen/logrus"
 )
 
 type OrderSender struct {
-	bot                                *tgbotapi.BotAPI
-	order                              service.Order
-	timezone                           time.Duration
-	startingMinutes                    []int
-	tickInterval                       time.Duration
+	bot                                messenger.Messenger
+	shipments                          service.Shipment
+	organizations                      service.Organization
+	clock                              clock.Clock
 	periodOfTimeBeforeLunchToShipOrder time.Duration
 	adminChatID                        int64
+
+	// sending is held while unsent shipments are sent, so jobs don't send them twice
+	sending sync.Mutex
 }
 
-func NewOrderSender(bot *tgbotapi.BotAPI, order service.Order, timezone time.Duration, startingMinutes []int, tickInterval time.Duration,
-	periodOfTimeBeforeLunchToShipOrder time.Duration, adminChatID int64) *OrderSender {
+func NewOrderSender(bot messenger.Messenger, shipments service.Shipment, organizations service.Organization,
+	clock clock.Clock, periodOfTimeBeforeLunchToShipOrder time.Duration, adminChatID int64) *OrderSender {
 	return &OrderSender{
 		bot:                                bot,
-		order:                              order,
-		timezone:                           timezone,
-		startingMinutes:                    startingMinutes,
-		tickInterval:                       tickInterval,
+		shipments:                          shipments,
+		organizations:                      organizations,
+		clock:                              clock,
 		periodOfTimeBeforeLunchToShipOrder: periodOfTimeBeforeLunchToShipOrder,
 		adminChatID:                        adminChatID,
 	}
 }
 
-func (s *OrderSender) Send(ctx context.Context) {
-	logrus.Info("orderSender producer started")
-	waitTimeToCreateTicker(ctx, s.startingMinutes)
-	logrus.Infof("orderSender is ready to create ticker: %s", time.Now().UTC().String())
-	t := time.NewTicker(s.tickInterval)
-	for {
-		select {
-		case <-ctx.Done():
-			logrus.Infof("orderSender producer stopped: %s", ctx.Err().Error())
-			t.Stop()
-			return
-		case <-t.C:
-			truncatedNowWithTimezone := time.Now().UTC().Add(s.timezone).Truncate(time.Minute)
-			hour := time.Duration(truncatedNowWithTimezone.Add(s.periodOfTimeBeforeLunchToShipOrder).Hour())
-			minute := time.Duration(truncatedNowWithTimezone.Add(s.periodOfTimeBeforeLunchToShipOrder).Minute())
-			lunchTime := hour*time.Hour + minute*time.Minute
-			logrus.Debugf("orderSender: lunch time: %s", lunchTime.String())
-
-			newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-			dataByOrganizationID, err := s.order.GetUserOrdersByOrganizationLunchTime(newCtx, lunchTime.String())
-			if err != nil {
-				logrus.Errorf("orderSender: %s", err.Error())
-				cancel()
-				continue
-			}
-			cancel()
-			if len(dataByOrganizationID) == 0 {
-				continue
-			}
+// Jobs record shipments of organizations the period before their lunch, including the missed ones, and send them
+// to the admin. Shipments which have failed are sent again every minute
+func (s *OrderSender) Jobs() []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:     "orderSender",
+			Schedule: scheduler.BeforeLunch(s.periodOfTimeBeforeLunchToShipOrder, s.organizations, s.clock),
+			Timeout:  time.Minute,
+			CatchUp:  true,
+			Run:      s.ship,
+		},
+		{
+			Name:     "unsentShipments",
+			Schedule: scheduler.MustParseCron("* * * * *"),
+			Timeout:  time.Minute,
+			Run: func(ctx context.Context, _ time.Time) error {
+				return s.sendUnsent(ctx)
+			},
+		},
+	}
+}
 
-			countOfDishes := make(map[string]int)
-			generalMsg := fmt.Sprintf("Заказы к %d:%d\n\n", hour, minute)
-			var generalSum float32
-			for _, data := range dataByOrganizationID {
-				orgMsg := fmt.Sprintf("%s\n%s\n", data.OrganizationName, data.OrganizationAddress)
-				var sumByOrg float32
-				for _, dishes := range data.DishesByCategories {
-					for _, dish := range dishes {
-						orgMsg = fmt.Sprintf("%s%s - %d\n", orgMsg, dish.Dish.Name, dish.Count)
-						sumByOrg += dish.Dish.Price * float32(dish.Count)
-						countOfDishes[dish.Name] += dish.Count
-					}
-				}
-				orgMsg = fmt.Sprintf("%sСумма заказ по организации: %.2f\n\n", orgMsg, sumByOrg)
-				generalMsg = fmt.Sprintf("%s%s", generalMsg, orgMsg)
-				generalSum += sumByOrg
-			}
+func (s *OrderSender) ship(ctx context.Context, at time.Time) error {
+	err := s.createShipments(ctx, at)
+	if err != nil {
+		return err
+	}
+	return s.sendUnsent(ctx)
+}
 
-			tgMsg := tgbotapi.NewMessage(s.adminChatID, generalMsg)
-			_, err = s.bot.Send(tgMsg)
-			if err != nil {
-				logrus.Errorf("orderSender: %s", err.Error())
-			}
+// sendUnsent sends to the admin all shipments which aren't sent yet and whose next attempt is due. Batches which
+// fail are postponed
+func (s *OrderSender) sendUnsent(ctx context.Context) error {
+	s.sending.Lock()
+	defer s.sending.Unlock()
 
-			msg := fmt.Sprintf("Общий заказ по всем организациям\n")
-			for dish, count := range countOfDishes {
-				msg = fmt.Sprintf("%s%s - %d\n", msg, dish, count)
-			}
-			msg = fmt.Sprintf("%sОбщая сумма заказов: %.2f", msg, generalSum)
+	now := s.clock.Now().Truncate(time.Minute)
+	shipments, err := s.shipments.GetUnsent(ctx, now)
+	if err != nil {
+		return fmt.Errorf("getUnsent: %w", err)
+	}
+	for _, batch := range batchesByShipAt(shipments) {
+		err = s.sendBatch(ctx, batch, now)
+		if err == nil {
+			continue
+		}
+		logrus.Errorf("orderSender: %s", err.Error())
+		err = s.shipments.Postpone(ctx, batch, err, now)
+		if err != nil {
+			logrus.Errorf("orderSender: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// createShipments records shipments whose orders are shipped at the minute. It's safe to call it twice for
+// the same minute, the shipment is recorded once
+func (s *OrderSender) createShipments(ctx context.Context, at time.Time) error {
+	logrus.Debugf("orderSender: lunch time: %s", at.Add(s.periodOfTimeBeforeLunchToShipOrder).String())
+	dayOff, err := s.shipments.Create(ctx, at)
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	for _, data := range dayOff {
+		logrus.Warnf("orderSender: organization %s doesn't work today, its orders aren't shipped", data.OrganizationName)
+	}
+	return nil
+}
+
+// sendBatch sends orders of organizations and then the total. The status of shipments is saved after every message,
+// so the message which has been sent isn't sent again on retry
+func (s *OrderSender) sendBatch(ctx context.Context, batch []*model.Shipment, now time.Time) error {
+	lunchTime := batch[0].ShipAt.In(s.clock.Location()).Add(s.periodOfTimeBeforeLunchToShipOrder)
+	hour, minute := lunchTime.Hour(), lunchTime.Minute()
 
-			tgMsg = tgbotapi.NewMessage(s.adminChatID, msg)
-			_, err = s.bot.Send(tgMsg)
-			if err != nil {
-				logrus.Errorf("orderSender: %s", err.Error())
+	countOfDishes := make(map[string]int)
+	generalMsg := fmt.Sprintf("Заказы к %d:%d\n\n", hour, minute)
+	var generalSum float32
+	for _, data := range batch {
+		orgMsg := fmt.Sprintf("%s\n%s\n", data.OrganizationName, data.OrganizationAddress)
+		if data.Timezone != "" {
+			orgMsg = fmt.Sprintf("%sОбед в %d:%02d по времени %s\n", orgMsg, int(data.LunchTime.Hours()),
+				int(data.LunchTime.Minutes())%60, data.Timezone)
+		}
+		var sumByOrg float32
+		for _, dishes := range data.DishesByCategories {
+			for _, dish := range dishes {
+				orgMsg = fmt.Sprintf("%s%s - %d\n", orgMsg, dish.Dish.Name, dish.Count)
+				sumByOrg += dish.Dish.Price * float32(dish.Count)
+				countOfDishes[dish.Name] += dish.Count
 			}
 		}
+		orgMsg = fmt.Sprintf("%sСумма заказ по организации: %.2f\n\n", orgMsg, sumByOrg)
+		generalMsg = fmt.Sprintf("%s%s", generalMsg, orgMsg)
+		generalSum += sumByOrg
+	}
+
+	if batch[0].Status == model.ShipmentPending {
+		_, err := s.bot.Send(tgbotapi.NewMessage(s.adminChatID, generalMsg))
+		if err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+		err = s.shipments.SetStatus(ctx, batch, model.ShipmentListed, now)
+		if err != nil {
+			return fmt.Errorf("setStatus: %w", err)
+		}
+	}
+
+	msg := fmt.Sprintf("Общий заказ по всем организациям\n")
+	for dish, count := range countOfDishes {
+		msg = fmt.Sprintf("%s%s - %d\n", msg, dish, count)
+	}
+	msg = fmt.Sprintf("%sОбщая сумма заказов: %.2f\n\nСтатусы доставок: /deliveries", msg, generalSum)
+
+	_, err := s.bot.Send(tgbotapi.NewMessage(s.adminChatID, msg))
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	err = s.shipments.SetStatus(ctx, batch, model.ShipmentSent, now)
+	if err != nil {
+		return fmt.Errorf("setStatus: %w", err)
+	}
+	return nil
+}
+
+// batchesByShipAt groups shipments which are shipped at the same time, shipments are sorted by the time
+func batchesByShipAt(shipments []*model.Shipment) [][]*model.Shipment {
+	var batches [][]*model.Shipment
+	for i, sh := range shipments {
+		if i == 0 || !sh.ShipAt.Equal(shipments[i-1].ShipAt) || sh.Status != shipments[i-1].Status {
+			batches = append(batches, nil)
+		}
+		batches[len(batches)-1] = append(batches[len(batches)-1], sh)
 	}
+	return batches
 }
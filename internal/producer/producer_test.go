@@ -0,0 +1,497 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/google/uuid"
+)
+
+const (
+	adminChatID  = 1
+	userChatID   = 2
+	reportChatID = 3
+	reportHour   = 10
+)
+
+var (
+	organizationID = uuid.MustParse("6f1c1b8e-3c55-4a4e-9d59-5d0a3f7c2a11")
+	lunchTime      = 13 * time.Hour
+)
+
+// Fake repositories have the organization which has lunch at 13:00 in the time zone of the bot. The customer
+// confirms the order every day, the user never does
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transact(ctx context.Context, txFn func(context.Context) error) error {
+	return txFn(ctx)
+}
+
+// fakeOrders has the confirmed order of the customer for every day, it's confirmed until it's sent to the kitchen
+type fakeOrders struct {
+	repository.Order
+	period  time.Duration
+	shipped map[time.Time]bool
+
+	mu      sync.Mutex
+	periods [][2]time.Time
+}
+
+func (f *fakeOrders) GetOrdersToShip(_ context.Context, now time.Time) (map[uuid.UUID]*model.OrderingData, error) {
+	date := clock.Date(now)
+	if sinceMidnight(now)+f.period != lunchTime || f.shipped[date] {
+		return nil, nil
+	}
+	return map[uuid.UUID]*model.OrderingData{
+		organizationID: {
+			OrganizationName:    "Рога и копыта",
+			OrganizationAddress: "Немига 5",
+			LunchTime:           lunchTime,
+			Date:                date,
+			DishesByCategories: map[string][]*model.DishWithCount{
+				"Супы": {{Dish: &model.Dish{ID: 1, Name: "Борщ", Price: 5, Category: "Супы"}, Count: 2}},
+			},
+		},
+	}, nil
+}
+
+func (f *fakeOrders) GetOrdersAmount(_ context.Context, from, to time.Time) (map[uuid.UUID]*model.Statistic, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.periods = append(f.periods, [2]time.Time{from, to})
+	return map[uuid.UUID]*model.Statistic{
+		organizationID: {
+			OrganizationName: "Рога и копыта",
+			Employees:        []*model.EmployeeDetail{{FirstName: "Иван", OrdersAmount: 10}},
+		},
+	}, nil
+}
+
+// fakeTelegram returns the user when the organization has lunch in beforeLunch after now, the customer who has
+// confirmed the order isn't reminded
+type fakeTelegram struct {
+	repository.Telegram
+	reminded map[string]bool
+}
+
+func (f *fakeTelegram) GetUsersToRemind(_ context.Context, now time.Time, beforeLunch time.Duration) ([]*model.TelegramUser, error) {
+	if sinceMidnight(now)+beforeLunch != lunchTime {
+		return nil, nil
+	}
+	return []*model.TelegramUser{{ID: userChatID, ChatID: userChatID, OrganizationID: organizationID,
+		Today: clock.Date(now)}}, nil
+}
+
+func (f *fakeTelegram) MarkReminded(_ context.Context, userTelegramID int64, remindAt time.Time,
+	beforeLunch time.Duration) (bool, error) {
+	key := fmt.Sprintf("%d %s %s", userTelegramID, remindAt.UTC(), beforeLunch)
+	if f.reminded[key] {
+		return false, nil
+	}
+	if f.reminded == nil {
+		f.reminded = make(map[string]bool)
+	}
+	f.reminded[key] = true
+	return true, nil
+}
+
+func (f *fakeTelegram) UnmarkReminded(_ context.Context, userTelegramID int64, remindAt time.Time,
+	beforeLunch time.Duration) error {
+	delete(f.reminded, fmt.Sprintf("%d %s %s", userTelegramID, remindAt.UTC(), beforeLunch))
+	return nil
+}
+
+// fakeShipments keeps shipments like the outbox table, linked orders are sent to the kitchen
+type fakeShipments struct {
+	repository.Shipment
+	orders        *fakeOrders
+	shipments     []*model.Shipment
+	nextAttemptAt map[int]time.Time
+}
+
+func (f *fakeShipments) Create(_ context.Context, shipment *model.Shipment) (bool, error) {
+	for _, sh := range f.shipments {
+		if sh.OrganizationID == shipment.OrganizationID && sh.Date.Equal(shipment.Date) {
+			return false, nil
+		}
+	}
+	shipment.ID = len(f.shipments) + 1
+	shipment.Status = model.ShipmentPending
+	sh := *shipment
+	f.shipments = append(f.shipments, &sh)
+	if f.nextAttemptAt == nil {
+		f.nextAttemptAt = make(map[int]time.Time)
+	}
+	f.nextAttemptAt[sh.ID] = sh.ShipAt
+	return true, nil
+}
+
+func (f *fakeShipments) LinkOrders(_ context.Context, shipment *model.Shipment) error {
+	if f.orders.shipped == nil {
+		f.orders.shipped = make(map[time.Time]bool)
+	}
+	f.orders.shipped[shipment.Date] = true
+	return nil
+}
+
+func (f *fakeShipments) GetUnsent(_ context.Context, now time.Time) ([]*model.Shipment, error) {
+	var unsent []*model.Shipment
+	for _, sh := range f.shipments {
+		if sh.Status != model.ShipmentSent && !f.nextAttemptAt[sh.ID].After(now) {
+			copied := *sh
+			unsent = append(unsent, &copied)
+		}
+	}
+	return unsent, nil
+}
+
+func (f *fakeShipments) SetStatus(_ context.Context, ids []int, status string, _ time.Time) error {
+	for _, id := range ids {
+		f.shipments[id-1].Status = status
+	}
+	return nil
+}
+
+func (f *fakeShipments) Postpone(_ context.Context, ids []int, _ string, nextAttemptAt time.Time) error {
+	for _, id := range ids {
+		f.shipments[id-1].Attempts++
+		f.nextAttemptAt[id] = nextAttemptAt
+	}
+	return nil
+}
+
+type fakeCalendar struct {
+	repository.Calendar
+	holidays map[time.Time]bool
+	err      error
+}
+
+func (f *fakeCalendar) GetDays(_ context.Context, from, to time.Time) ([]*model.CalendarDay, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var days []*model.CalendarDay
+	for date := range f.holidays {
+		if !date.Before(from) && !date.After(to) {
+			days = append(days, &model.CalendarDay{Date: date, Working: false})
+		}
+	}
+	return days, nil
+}
+
+// fakeOrganizations has the only organization which has lunch in the time zone of the bot
+type fakeOrganizations struct {
+	service.Organization
+}
+
+func (f *fakeOrganizations) GetLunchTimes(context.Context) ([]model.LunchTime, error) {
+	return []model.LunchTime{{Time: lunchTime}}, nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// repositories are fakes behind real services of producers
+type repositories struct {
+	telegram  *fakeTelegram
+	orders    *fakeOrders
+	shipments *fakeShipments
+	calendar  *fakeCalendar
+}
+
+// newRepositories returns fake repositories, orders are shipped an hour before lunch
+func newRepositories(holidays map[time.Time]bool) *repositories {
+	orders := &fakeOrders{period: time.Hour}
+	return &repositories{
+		telegram:  &fakeTelegram{},
+		orders:    orders,
+		shipments: &fakeShipments{orders: orders},
+		calendar:  &fakeCalendar{holidays: holidays},
+	}
+}
+
+func (r *repositories) shipmentService() service.Shipment {
+	return service.NewShipment(r.shipments, r.orders, service.NewCalendar(r.calendar, nil), fakeTransactor{})
+}
+
+type day struct {
+	clock  *clock.Fake
+	sender *messenger.Fake
+	repos  *repositories
+}
+
+// newJobs schedules jobs of all producers with real services, orders are shipped an hour before lunch
+func newJobs(clk clock.Clock, checkpoints service.Checkpoint, sender messenger.Messenger,
+	repos *repositories) *scheduler.Scheduler {
+	period := time.Hour
+	organizations := &fakeOrganizations{}
+	calendar := service.NewCalendar(repos.calendar, nil)
+	reminder := NewUsersReminder(sender, service.NewTelegram(repos.telegram), nil, calendar, organizations, clk, period,
+		30*time.Minute, 15*time.Minute)
+	orderSender := NewOrderSender(sender, repos.shipmentService(), organizations, clk, period, adminChatID)
+	statisticsSender := NewStatisticsSender(sender, service.NewStatistics(repos.orders, nil),
+		scheduler.MustParseCron(fmt.Sprintf("0 %d * * *", reportHour)), []int64{reportChatID})
+
+	jobs := scheduler.New(clk, checkpoints, 24*time.Hour)
+	jobs.Add(reminder.Jobs()...)
+	jobs.Add(orderSender.Jobs()...)
+	jobs.Add(statisticsSender.Jobs()...)
+	return jobs
+}
+
+func newClock(t *testing.T, date time.Time, hour, minute int) *clock.Fake {
+	t.Helper()
+	location, err := clock.LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clock.NewFake(time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, location))
+}
+
+// simulateDay moves the clock minute by minute through the day and ticks the scheduler the way it runs jobs
+func simulateDay(t *testing.T, date time.Time, holidays map[time.Time]bool) *day {
+	t.Helper()
+	clk := newClock(t, date, 0, 0)
+	sender := messenger.NewFake(clk)
+	repos := newRepositories(holidays)
+	jobs := newJobs(clk, nil, sender, repos)
+
+	ctx := context.Background()
+	for i := 0; i < 24*60; i++ {
+		jobs.Tick(ctx)
+		clk.Add(time.Minute)
+	}
+	return &day{clock: clk, sender: sender, repos: repos}
+}
+
+func assertSentAt(t *testing.T, sent []messenger.Message, times ...string) {
+	t.Helper()
+	if len(sent) != len(times) {
+		t.Fatalf("sent %d messages, want %d: %v", len(sent), len(times), sent)
+	}
+	for i, msg := range sent {
+		if got := msg.SentAt.Format("15:04"); got != times[i] {
+			t.Errorf("message %d sent at %s, want %s", i, got, times[i])
+		}
+	}
+}
+
+func TestWorkingDay(t *testing.T) {
+	d := simulateDay(t, time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC), nil)
+
+	reminders := d.sender.Messages(userChatID)
+	assertSentAt(t, reminders, "11:30", "11:45")
+	if !strings.Contains(reminders[0].Text, "30 минут") || !strings.Contains(reminders[1].Text, "15 минут") {
+		t.Errorf("unexpected reminders: %q, %q", reminders[0].Text, reminders[1].Text)
+	}
+
+	orders := d.sender.Messages(adminChatID)
+	assertSentAt(t, orders, "12:00", "12:00")
+	if !strings.Contains(orders[0].Text, "Борщ - 2") || !strings.Contains(orders[1].Text, "Общая сумма заказов: 10.00") {
+		t.Errorf("unexpected orders: %q, %q", orders[0].Text, orders[1].Text)
+	}
+
+	// the first day of the month gets the report for yesterday and for the previous month
+	reports := d.sender.Messages(reportChatID)
+	assertSentAt(t, reports, "10:00", "10:00")
+	if !strings.HasPrefix(reports[0].Text, "Отчёт за 31 Мая") || !strings.HasPrefix(reports[1].Text, "Отчёт за Май") {
+		t.Errorf("unexpected reports: %q, %q", reports[0].Text, reports[1].Text)
+	}
+	wantPeriods := [][2]time.Time{
+		{time.Date(2026, time.May, 31, 0, 0, 0, 0, time.UTC), time.Date(2026, time.May, 31, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, time.May, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(d.repos.orders.periods) != len(wantPeriods) {
+		t.Fatalf("statistics requested %d times, want %d", len(d.repos.orders.periods), len(wantPeriods))
+	}
+	for i, p := range d.repos.orders.periods {
+		if !p[0].Equal(wantPeriods[i][0]) || !p[1].Equal(wantPeriods[i][1]) {
+			t.Errorf("statistics period %d is %v, want %v", i, p, wantPeriods[i])
+		}
+	}
+}
+
+func TestHoliday(t *testing.T) {
+	date := time.Date(2026, time.June, 2, 0, 0, 0, 0, time.UTC)
+	d := simulateDay(t, date, map[time.Time]bool{date: true})
+
+	assertSentAt(t, d.sender.Messages(userChatID))
+	assertSentAt(t, d.sender.Messages(adminChatID))
+	// reports are sent on holidays too
+	assertSentAt(t, d.sender.Messages(reportChatID), "10:00")
+}
+
+func TestWeekend(t *testing.T) {
+	d := simulateDay(t, time.Date(2026, time.June, 6, 0, 0, 0, 0, time.UTC), nil)
+
+	assertSentAt(t, d.sender.Messages(userChatID))
+	assertSentAt(t, d.sender.Messages(adminChatID))
+}
+
+func TestShipmentIsRetried(t *testing.T) {
+	clk := newClock(t, time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC), 12, 0)
+	sender := messenger.NewFake(clk)
+	repos := newRepositories(nil)
+	jobs := scheduler.New(clk, nil, 0)
+	jobs.Add(NewOrderSender(sender, repos.shipmentService(), &fakeOrganizations{}, clk, time.Hour, adminChatID).Jobs()...)
+	ctx := context.Background()
+
+	// telegram is down when orders must be shipped
+	sender.Fail(errors.New("telegram is down"))
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(adminChatID))
+
+	// the shipment waits for the next attempt
+	sender.Fail(nil)
+	clk.Add(30 * time.Second)
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(adminChatID))
+
+	clk.Add(30 * time.Second)
+	jobs.Tick(ctx)
+	orders := sender.Messages(adminChatID)
+	assertSentAt(t, orders, "12:01", "12:01")
+	if !strings.HasPrefix(orders[0].Text, "Заказы к 13:0") || !strings.Contains(orders[0].Text, "Борщ - 2") {
+		t.Errorf("unexpected orders: %q", orders[0].Text)
+	}
+
+	// the sent shipment isn't sent again
+	for i := 0; i < 5; i++ {
+		clk.Add(time.Minute)
+		jobs.Tick(ctx)
+	}
+	assertSentAt(t, sender.Messages(adminChatID), "12:01", "12:01")
+}
+
+func TestReminderIsRetried(t *testing.T) {
+	clk := newClock(t, time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC), 11, 30)
+	sender := messenger.NewFake(clk)
+	repos := newRepositories(nil)
+	jobs := scheduler.New(clk, nil, 0)
+	jobs.Add(NewUsersReminder(sender, service.NewTelegram(repos.telegram), nil, service.NewCalendar(repos.calendar, nil),
+		&fakeOrganizations{}, clk, time.Hour, 30*time.Minute, 15*time.Minute).Jobs()...)
+	ctx := context.Background()
+
+	// telegram is down when the first reminder must be sent
+	sender.Fail(errors.New("telegram is down"))
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(userChatID))
+
+	sender.Fail(nil)
+	for i := 0; i < 5; i++ {
+		clk.Add(time.Minute)
+		jobs.Tick(ctx)
+	}
+	assertSentAt(t, sender.Messages(userChatID), "11:31")
+}
+
+func TestReminderIsRetriedAfterCalendarFailure(t *testing.T) {
+	clk := newClock(t, time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC), 11, 30)
+	sender := messenger.NewFake(clk)
+	repos := newRepositories(nil)
+	jobs := scheduler.New(clk, nil, 0)
+	jobs.Add(NewUsersReminder(sender, service.NewTelegram(repos.telegram), nil, service.NewCalendar(repos.calendar, nil),
+		&fakeOrganizations{}, clk, time.Hour, 30*time.Minute, 15*time.Minute).Jobs()...)
+	ctx := context.Background()
+
+	// the calendar can't be read when the first reminder must be sent, the organization isn't skipped for good
+	repos.calendar.err = errors.New("postgres is down")
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(userChatID))
+
+	repos.calendar.err = nil
+	clk.Add(time.Minute)
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(userChatID), "11:31")
+}
+
+func TestReportIsRetried(t *testing.T) {
+	clk := newClock(t, time.Date(2026, time.June, 2, 0, 0, 0, 0, time.UTC), reportHour, 0)
+	sender := messenger.NewFake(clk)
+	repos := newRepositories(nil)
+	jobs := scheduler.New(clk, nil, 0)
+	jobs.Add(NewStatisticsSender(sender, service.NewStatistics(repos.orders, nil),
+		scheduler.MustParseCron(fmt.Sprintf("0 %d * * *", reportHour)), []int64{reportChatID}).Jobs()...)
+	ctx := context.Background()
+
+	sender.Fail(errors.New("telegram is down"))
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(reportChatID))
+
+	sender.Fail(nil)
+	for i := 0; i < 5; i++ {
+		clk.Add(time.Minute)
+		jobs.Tick(ctx)
+	}
+	assertSentAt(t, sender.Messages(reportChatID), "10:01")
+}
+
+type fakeCheckpoints struct {
+	service.Checkpoint
+	processedAt map[string]time.Time
+}
+
+func (f *fakeCheckpoints) Get(_ context.Context, producer string) (time.Time, error) {
+	return f.processedAt[producer], nil
+}
+
+func (f *fakeCheckpoints) Save(_ context.Context, producer string, processedAt time.Time) error {
+	f.processedAt[producer] = processedAt
+	return nil
+}
+
+// TestCatchUp starts jobs after the bot has been down from 9:50 to 11:35 and then from 11:50 to 12:10
+func TestCatchUp(t *testing.T) {
+	location, err := clock.LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := time.Date(2026, time.June, 1, 9, 50, 0, 0, location)
+	clk := clock.NewFake(time.Date(2026, time.June, 1, 11, 35, 0, 0, location))
+	sender := messenger.NewFake(clk)
+	checkpoints := &fakeCheckpoints{processedAt: map[string]time.Time{
+		"firstReminder":    down,
+		"secondReminder":   down,
+		"orderSender":      down,
+		"statisticsSender": down,
+	}}
+	jobs := newJobs(clk, checkpoints, sender, newRepositories(nil))
+	ctx := context.Background()
+
+	// the report hour and the first reminder at 11:30 are processed on start, twice starting doesn't repeat them
+	jobs.Tick(ctx)
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(reportChatID), "11:35", "11:35")
+	assertSentAt(t, sender.Messages(userChatID), "11:35")
+
+	for clk.Now().Before(time.Date(2026, time.June, 1, 11, 50, 0, 0, location)) {
+		clk.Add(time.Minute)
+		jobs.Tick(ctx)
+	}
+	assertSentAt(t, sender.Messages(userChatID), "11:35", "11:45")
+	assertSentAt(t, sender.Messages(adminChatID))
+
+	// orders had to be shipped at 12:00
+	clk.Set(time.Date(2026, time.June, 1, 12, 10, 0, 0, location))
+	jobs.Tick(ctx)
+	jobs.Tick(ctx)
+	assertSentAt(t, sender.Messages(adminChatID), "12:10", "12:10")
+	assertSentAt(t, sender.Messages(reportChatID), "11:35", "11:35")
+	shippedAt := time.Date(2026, time.June, 1, 12, 0, 0, 0, location)
+	if got := checkpoints.processedAt["orderSender"]; !got.Equal(shippedAt) {
+		t.Errorf("orderSender has processed %s, want %s", got, shippedAt)
+	}
+}
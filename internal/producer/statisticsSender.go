@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
 	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
 	"github.com/chucky-1/food-delivery-bot/internal/service"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sirupsen/logrus"
@@ -17,55 +20,52 @@ const (
 )
 
 type StatisticsSender struct {
-	bot             *tgbotapi.BotAPI
+	bot             messenger.Messenger
 	statistics      service.Statistics
-	timezone        time.Duration
-	reportHour      int
+	schedule        scheduler.Schedule
 	reportReceivers []int64
 }
 
-func NewStatisticsSender(bot *tgbotapi.BotAPI, statistics service.Statistics, timezone time.Duration, reportHour int,
+func NewStatisticsSender(bot messenger.Messenger, statistics service.Statistics, schedule scheduler.Schedule,
 	reportReceivers []int64) *StatisticsSender {
 	return &StatisticsSender{
 		bot:             bot,
 		statistics:      statistics,
-		timezone:        timezone,
-		reportHour:      reportHour,
+		schedule:        schedule,
 		reportReceivers: reportReceivers,
 	}
 }
 
-func (s *StatisticsSender) StatisticsSend(ctx context.Context) {
-	logrus.Info("statisticSender producer started")
-	waitTimeToCreateTickerForStatisticsSender(ctx)
-	logrus.Info("statisticSender producer is ready to create ticker: %s", time.Now().UTC())
-	t := time.NewTicker(time.Hour)
-	for {
-		select {
-		case <-ctx.Done():
-			logrus.Infof("statisticSender producer stopped: %s", ctx.Err().Error())
-			t.Stop()
-			return
-		case <-t.C:
-			if time.Now().UTC().Add(s.timezone).Hour() == s.reportHour {
-				err := s.sendReportsForYesterday(ctx)
-				if err != nil {
-					logrus.Errorf("statisticsSend: %s", err.Error())
-				}
-			}
-			if time.Now().UTC().Add(s.timezone).Hour() == s.reportHour && time.Now().UTC().Add(s.timezone).Day() == 1 {
-				err := s.sendReportsForMonth(ctx)
-				if err != nil {
-					logrus.Errorf("statisticsSend: %s", err.Error())
-				}
-			}
-		}
+// Jobs send the report for yesterday and the report for the previous month on the first day. The report is sent
+// later if the bot hasn't been working at the instant of the schedule
+func (s *StatisticsSender) Jobs() []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:     "statisticsSender",
+			Schedule: s.schedule,
+			Timeout:  5 * time.Minute,
+			CatchUp:  true,
+			Run:      s.report,
+		},
+	}
+}
+
+// report sends reports for the instant. If sending fails, the instant is run again and reports which have been sent
+// before the failure are sent again
+func (s *StatisticsSender) report(ctx context.Context, at time.Time) error {
+	err := s.sendReportsForYesterday(ctx, at)
+	if err != nil {
+		return err
 	}
+	if at.Day() == 1 {
+		return s.sendReportsForMonth(ctx, at)
+	}
+	return nil
 }
 
-func (s *StatisticsSender) sendReportsForYesterday(ctx context.Context) error {
+func (s *StatisticsSender) sendReportsForYesterday(ctx context.Context, now time.Time) error {
 	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-	yesterday := time.Now().UTC().Add(s.timezone).Add(-24 * time.Hour)
+	yesterday := clock.Date(now).AddDate(0, 0, -1)
 	stats, err := s.statistics.Get(newCtx, yesterday, yesterday)
 	if err != nil {
 		cancel()
@@ -74,7 +74,7 @@ func (s *StatisticsSender) sendReportsForYesterday(ctx context.Context) error {
 	cancel()
 
 	for _, st := range stats {
-		msg := s.createReportMessage(st, dayPeriod)
+		msg := s.createReportMessage(st, dayPeriod, yesterday)
 		for _, chatID := range s.reportReceivers {
 			tgMsg := tgbotapi.NewMessage(chatID, msg)
 			_, err = s.bot.Send(tgMsg)
@@ -86,8 +86,8 @@ func (s *StatisticsSender) sendReportsForYesterday(ctx context.Context) error {
 	return nil
 }
 
-func (s *StatisticsSender) sendReportsForMonth(ctx context.Context) error {
-	from, to := s.firstAndLastDaysOfPreviousMonth()
+func (s *StatisticsSender) sendReportsForMonth(ctx context.Context, now time.Time) error {
+	from, to := firstAndLastDaysOfPreviousMonth(now)
 	logrus.Debugf("sendReportsForMonth: from: %s, to: %s", from.String(), to.String())
 
 	newCtx, cancel := context.WithTimeout(ctx, time.Minute)
@@ -99,7 +99,7 @@ func (s *StatisticsSender) sendReportsForMonth(ctx context.Context) error {
 	cancel()
 
 	for _, st := range stats {
-		msg := s.createReportMessage(st, monthPeriod)
+		msg := s.createReportMessage(st, monthPeriod, to)
 		for _, chatID := range s.reportReceivers {
 			tgMsg := tgbotapi.NewMessage(chatID, msg)
 			_, err = s.bot.Send(tgMsg)
@@ -111,17 +111,17 @@ func (s *StatisticsSender) sendReportsForMonth(ctx context.Context) error {
 	return nil
 }
 
-func (s *StatisticsSender) createReportMessage(stats *model.Statistic, period string) string {
-	yesterday := time.Now().UTC().Add(s.timezone).Add(-24 * time.Hour).Truncate(24 * time.Hour)
+// createReportMessage returns the report for the day or for the month of the day
+func (s *StatisticsSender) createReportMessage(stats *model.Statistic, period string, day time.Time) string {
 	var (
 		msg         string
 		totalAmount float32
 	)
 	switch period {
 	case dayPeriod:
-		msg = fmt.Sprintf("Отчёт за %d %s\n%s\n", yesterday.Day(), translateMonthWithDeclination(yesterday.Month()), stats.OrganizationName)
+		msg = fmt.Sprintf("Отчёт за %d %s\n%s\n", day.Day(), translateMonthWithDeclination(day.Month()), stats.OrganizationName)
 	case monthPeriod:
-		msg = fmt.Sprintf("Отчёт за %s\n%s\n", translateMonthWithoutDeclination(yesterday.Month()), stats.OrganizationName)
+		msg = fmt.Sprintf("Отчёт за %s\n%s\n", translateMonthWithoutDeclination(day.Month()), stats.OrganizationName)
 	}
 
 	for _, st := range stats.Employees {
@@ -132,30 +132,13 @@ func (s *StatisticsSender) createReportMessage(stats *model.Statistic, period st
 	return msg
 }
 
-func (s *StatisticsSender) firstAndLastDaysOfPreviousMonth() (time.Time, time.Time) {
-	currentTime := time.Now().UTC().Add(s.timezone)
+func firstAndLastDaysOfPreviousMonth(currentTime time.Time) (time.Time, time.Time) {
 	firstDayOfCurrentMonth := time.Date(currentTime.Year(), currentTime.Month(), 1, 0, 0, 0, 0, time.UTC)
 	lastDayOfPreviousMonth := firstDayOfCurrentMonth.Add(-time.Hour * 24)
 	firstDayOfPreviousMonth := time.Date(lastDayOfPreviousMonth.Year(), lastDayOfPreviousMonth.Month(), 1, 0, 0, 0, 0, time.UTC)
 	return firstDayOfPreviousMonth, lastDayOfPreviousMonth
 }
 
-func waitTimeToCreateTickerForStatisticsSender(ctx context.Context) {
-	t := time.NewTicker(time.Second)
-	for {
-		select {
-		case <-ctx.Done():
-			t.Stop()
-			return
-		case <-t.C:
-			if time.Now().Minute() == 0 {
-				t.Stop()
-				return
-			}
-		}
-	}
-}
-
 func translateMonthWithDeclination(month time.Month) string {
 	switch month {
 	case time.January:
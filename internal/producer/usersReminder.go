@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
 	"github.com/chucky-1/food-delivery-bot/internal/service"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,103 +24,115 @@ var (
 )
 
 type UsersReminder struct {
-	bot                                *tgbotapi.BotAPI
+	bot                                messenger.Messenger
 	telegram                           service.Telegram
 	order                              service.Order
-	timezone                           time.Duration
-	startingMinutes                    []int
-	tickInterval                       time.Duration
+	calendar                           service.Calendar
+	organizations                      service.Organization
+	clock                              clock.Clock
 	periodOfTimeBeforeLunchToShipOrder time.Duration
 	firstReminder                      time.Duration
 	secondReminder                     time.Duration
 }
 
-func NewUsersReminder(bot *tgbotapi.BotAPI, telegram service.Telegram, order service.Order, timezone time.Duration, startingMinutes []int,
-	tickInterval time.Duration, periodOfTimeBeforeLunchToShipOrder time.Duration, firstReminder time.Duration, secondReminder time.Duration) *UsersReminder {
+func NewUsersReminder(bot messenger.Messenger, telegram service.Telegram, order service.Order, calendar service.Calendar,
+	organizations service.Organization, clock clock.Clock, periodOfTimeBeforeLunchToShipOrder time.Duration, firstReminder time.Duration,
+	secondReminder time.Duration) *UsersReminder {
 	return &UsersReminder{
 		bot:                                bot,
 		telegram:                           telegram,
 		order:                              order,
-		timezone:                           timezone,
-		startingMinutes:                    startingMinutes,
-		tickInterval:                       tickInterval,
+		calendar:                           calendar,
+		organizations:                      organizations,
+		clock:                              clock,
 		periodOfTimeBeforeLunchToShipOrder: periodOfTimeBeforeLunchToShipOrder,
 		firstReminder:                      firstReminder,
 		secondReminder:                     secondReminder,
 	}
 }
 
-func (u *UsersReminder) Remind(ctx context.Context) {
-	logrus.Info("usersReminder producer started")
-	waitTimeToCreateTicker(ctx, u.startingMinutes)
-	logrus.Infof("userReminder is ready to create ticker: %s", time.Now().UTC().String())
-	t := time.NewTicker(u.tickInterval)
-	for {
-		select {
-		case <-ctx.Done():
-			logrus.Infof("usersReminder producer stopped: %s", ctx.Err().Error())
-			t.Stop()
-			return
-		case <-t.C:
-			truncatedNowWithTimezone := time.Now().UTC().Add(u.timezone).Truncate(time.Minute)
-			firstLunchTime := time.Duration(truncatedNowWithTimezone.Add(u.periodOfTimeBeforeLunchToShipOrder).Add(u.firstReminder).Hour())*time.Hour +
-				time.Duration(truncatedNowWithTimezone.Add(u.periodOfTimeBeforeLunchToShipOrder).Add(u.firstReminder).Minute())*time.Minute
-			secondLunchTime := time.Duration(truncatedNowWithTimezone.Add(u.periodOfTimeBeforeLunchToShipOrder).Add(u.secondReminder).Hour())*time.Hour +
-				time.Duration(truncatedNowWithTimezone.Add(u.periodOfTimeBeforeLunchToShipOrder).Add(u.secondReminder).Minute())*time.Minute
-			logrus.Debugf("remind: first lunch time: %s, second lunch time: %s",
-				firstLunchTime.String(), secondLunchTime.String())
-			lunchTimes := make([]string, 0)
-			lunchTimes = append(lunchTimes, firstLunchTime.String(), secondLunchTime.String())
+// Jobs remind users without confirmed order that their organizations' orders will be shipped soon. Minutes which
+// are missed are caught up, their reminders are sent only if orders aren't shipped yet
+func (u *UsersReminder) Jobs() []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name: "firstReminder",
+			Schedule: scheduler.BeforeLunch(u.periodOfTimeBeforeLunchToShipOrder+u.firstReminder, u.organizations,
+				u.clock),
+			Timeout: time.Minute,
+			CatchUp: true,
+			Run: func(ctx context.Context, at time.Time) error {
+				return u.remind(ctx, at, u.firstReminder, firstOrderReminderMessage)
+			},
+		},
+		{
+			Name: "secondReminder",
+			Schedule: scheduler.BeforeLunch(u.periodOfTimeBeforeLunchToShipOrder+u.secondReminder, u.organizations,
+				u.clock),
+			Timeout: time.Minute,
+			CatchUp: true,
+			Run: func(ctx context.Context, at time.Time) error {
+				return u.remind(ctx, at, u.secondReminder, secondOrderReminderMessage)
+			},
+		},
+	}
+}
+
+// remind sends the message to users whose organizations have lunch in the period and the reminder after the minute.
+// Every user gets the reminder once even if the minute is processed twice. If some reminders can't be sent, they
+// are unmarked and the error is returned, so the minute is processed again
+func (u *UsersReminder) remind(ctx context.Context, at time.Time, reminder time.Duration, message string) error {
+	if !at.Add(reminder).After(u.clock.Now()) {
+		logrus.Warnf("remind: orders for the reminder at %s have already been shipped", at)
+		return nil
+	}
+	beforeLunch := u.periodOfTimeBeforeLunchToShipOrder + reminder
+	telegramUsers, err := u.telegram.GetUsersToRemind(ctx, at, beforeLunch)
+	if err != nil {
+		return fmt.Errorf("getUsersToRemind: %w", err)
+	}
 
-			newCtx, cancel := context.WithTimeout(ctx, time.Minute)
-			telegramUsersByLunchTime, err := u.telegram.GetUsersByLunchTimes(newCtx, lunchTimes)
+	// organizations which don't work on their local date aren't reminded
+	workingByOrganizationID := make(map[uuid.UUID]bool)
+	var failed int
+	for _, tgUser := range telegramUsers {
+		working, ok := workingByOrganizationID[tgUser.OrganizationID]
+		if !ok {
+			working, err = u.calendar.IsWorkingDay(ctx, tgUser.Today, tgUser.OrganizationID)
 			if err != nil {
+				// the day of the organization is checked again for the next user and when the minute is retried
 				logrus.Errorf("remind: %s", err.Error())
-				cancel()
+				failed++
 				continue
 			}
-			cancel()
-
-			for lunchTime, telegramUsers := range telegramUsersByLunchTime {
-				for _, tgUser := range telegramUsers {
-					switch lunchTime {
-					case firstLunchTime:
-						msg := tgbotapi.NewMessage(tgUser.ChatID, fmt.Sprintf(firstOrderReminderMessage, u.firstReminder.Minutes()))
-						_, err = u.bot.Send(msg)
-						if err != nil {
-							logrus.Errorf("remind: %s", err.Error())
-							continue
-						}
-					case secondLunchTime:
-						msg := tgbotapi.NewMessage(tgUser.ChatID, fmt.Sprintf(secondOrderReminderMessage, u.secondReminder.Minutes()))
-						_, err = u.bot.Send(msg)
-						if err != nil {
-							logrus.Errorf("remind: %s", err.Error())
-							continue
-						}
-					}
-				}
-			}
+			workingByOrganizationID[tgUser.OrganizationID] = working
 		}
-	}
-}
-
-func waitTimeToCreateTicker(ctx context.Context, startingMinutes []int) {
-	t := time.NewTicker(time.Second)
-	var currentMinute int
-	for {
-		select {
-		case <-ctx.Done():
-			t.Stop()
-			return
-		case <-t.C:
-			currentMinute = time.Now().Minute()
-			for _, suitableMinute := range startingMinutes {
-				if currentMinute == suitableMinute {
-					t.Stop()
-					return
-				}
-			}
+		if !working {
+			continue
+		}
+		marked, err := u.telegram.MarkReminded(ctx, tgUser.ID, at, beforeLunch)
+		if err != nil {
+			logrus.Errorf("remind: %s", err.Error())
+			failed++
+			continue
 		}
+		if !marked {
+			continue
+		}
+		msg := tgbotapi.NewMessage(tgUser.ChatID, fmt.Sprintf(message, reminder.Minutes()))
+		_, err = u.bot.Send(msg)
+		if err == nil {
+			continue
+		}
+		logrus.Errorf("remind: %s", err.Error())
+		failed++
+		err = u.telegram.UnmarkReminded(ctx, tgUser.ID, at, beforeLunch)
+		if err != nil {
+			logrus.Errorf("remind: %s", err.Error())
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d reminders aren't sent", failed)
 	}
+	return nil
 }
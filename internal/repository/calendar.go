@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/google/uuid"
+)
+
+var ErrOrganizationNotFound = errors.New("organization not found")
+
+type Calendar interface {
+	GetDays(ctx context.Context, from, to time.Time) ([]*model.CalendarDay, error)
+	SetDay(ctx context.Context, day *model.CalendarDay) error
+	DeleteDay(ctx context.Context, date time.Time, organizationID uuid.UUID) error
+}
+
+type calendar struct {
+	tr *transactor
+}
+
+func NewCalendar(tr *transactor) *calendar {
+	return &calendar{
+		tr: tr,
+	}
+}
+
+// GetDays returns days of all organizations between from and to inclusive sorted by date
+func (c *calendar) GetDays(ctx context.Context, from, to time.Time) ([]*model.CalendarDay, error) {
+	query := `
+		SELECT date, organization_id, working
+		FROM internal.calendar
+		WHERE date BETWEEN $1 AND $2
+		ORDER BY date, organization_id NULLS FIRST`
+	rows, err := c.tr.extractTx(ctx).Query(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var days []*model.CalendarDay
+	for rows.Next() {
+		var (
+			day   model.CalendarDay
+			orgID uuid.NullUUID
+		)
+		err = rows.Scan(&day.Date, &orgID, &day.Working)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		day.OrganizationID = orgID.UUID
+		days = append(days, &day)
+	}
+	return days, nil
+}
+
+// SetDay replaces the day of the organization or of all organizations
+func (c *calendar) SetDay(ctx context.Context, day *model.CalendarDay) error {
+	err := c.DeleteDay(ctx, day.Date, day.OrganizationID)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO internal.calendar (date, organization_id, working) VALUES ($1, $2, $3)`
+	_, err = c.tr.extractTx(ctx).Exec(ctx, query, day.Date, nullOrganizationID(day.OrganizationID), day.Working)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrOrganizationNotFound
+		}
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+func (c *calendar) DeleteDay(ctx context.Context, date time.Time, organizationID uuid.UUID) error {
+	query := `DELETE FROM internal.calendar WHERE date = $1 AND organization_id IS NOT DISTINCT FROM $2::uuid`
+	_, err := c.tr.extractTx(ctx).Exec(ctx, query, date, nullOrganizationID(organizationID))
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+func nullOrganizationID(id uuid.UUID) sql.NullString {
+	if id == uuid.Nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: id.String(), Valid: true}
+}
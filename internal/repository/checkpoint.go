@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type Checkpoint interface {
+	Get(ctx context.Context, producer string) (time.Time, error)
+	Save(ctx context.Context, producer string, processedAt time.Time) error
+}
+
+type checkpoint struct {
+	tr *transactor
+}
+
+func NewCheckpoint(tr *transactor) *checkpoint {
+	return &checkpoint{
+		tr: tr,
+	}
+}
+
+// Get returns the last instant the producer has processed, zero time if it hasn't processed anything yet
+func (c *checkpoint) Get(ctx context.Context, producer string) (time.Time, error) {
+	query := `SELECT processed_at FROM internal.checkpoints WHERE producer = $1`
+	var processedAt time.Time
+	err := c.tr.extractTx(ctx).QueryRow(ctx, query, producer).Scan(&processedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("queryRow: %w", err)
+	}
+	return processedAt, nil
+}
+
+// Save saves the processed instant, the checkpoint never moves back
+func (c *checkpoint) Save(ctx context.Context, producer string, processedAt time.Time) error {
+	query := `
+		INSERT INTO internal.checkpoints (producer, processed_at)
+		VALUES ($1, $2)
+		ON CONFLICT (producer) DO UPDATE
+		SET processed_at = greatest(internal.checkpoints.processed_at, excluded.processed_at)`
+	_, err := c.tr.extractTx(ctx).Exec(ctx, query, producer, processedAt)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
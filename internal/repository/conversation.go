@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/jackc/pgx/v4"
+)
+
+type Conversation interface {
+	Save(ctx context.Context, userTelegramID int64, conversation *model.Conversation, expiresAt time.Time) error
+	Take(ctx context.Context, userTelegramID int64, now time.Time) (*model.Conversation, error)
+	Delete(ctx context.Context, userTelegramID int64, now time.Time) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type conversation struct {
+	tr *transactor
+}
+
+func NewConversation(tr *transactor) *conversation {
+	return &conversation{
+		tr: tr,
+	}
+}
+
+// Save replaces the conversation of user, user can wait only one message
+func (c *conversation) Save(ctx context.Context, userTelegramID int64, conversation *model.Conversation, expiresAt time.Time) error {
+	var dish []byte
+	if conversation.Dish != nil {
+		var err error
+		dish, err = json.Marshal(conversation.Dish)
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+	}
+	query := `
+		INSERT INTO internal.conversations (user_telegram_id, action, data, dish, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_telegram_id) DO UPDATE
+		SET action = excluded.action, data = excluded.data, dish = excluded.dish, expires_at = excluded.expires_at`
+	_, err := c.tr.extractTx(ctx).Exec(ctx, query, userTelegramID, conversation.Action, conversation.DataOnFirstStep,
+		dish, expiresAt)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// Take removes the conversation of user and returns it. It returns nil if there is no conversation or it's expired
+func (c *conversation) Take(ctx context.Context, userTelegramID int64, now time.Time) (*model.Conversation, error) {
+	query := `
+		DELETE FROM internal.conversations
+		WHERE user_telegram_id = $1
+		RETURNING action, data, dish, expires_at`
+	var (
+		conversation model.Conversation
+		dish         []byte
+		expiresAt    time.Time
+	)
+	err := c.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID).Scan(&conversation.Action,
+		&conversation.DataOnFirstStep, &dish, &expiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("queryRow: %w", err)
+	}
+	if !expiresAt.After(now) {
+		return nil, nil
+	}
+	if dish != nil {
+		conversation.Dish = &model.Dish{}
+		err = json.Unmarshal(dish, conversation.Dish)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal: %w", err)
+		}
+	}
+	return &conversation, nil
+}
+
+// Delete returns false if user doesn't have the conversation which isn't expired
+func (c *conversation) Delete(ctx context.Context, userTelegramID int64, now time.Time) (bool, error) {
+	query := `DELETE FROM internal.conversations WHERE user_telegram_id = $1 AND expires_at > $2`
+	tag, err := c.tr.extractTx(ctx).Exec(ctx, query, userTelegramID, now)
+	if err != nil {
+		return false, fmt.Errorf("exec: %w", err)
+	}
+	return tag.RowsAffected() != 0, nil
+}
+
+// DeleteExpired removes conversations which are expired by now and returns their number. Users who don't answer
+// leave their conversations behind, Take removes only the ones of users who write again
+func (c *conversation) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	query := `DELETE FROM internal.conversations WHERE expires_at <= $1`
+	tag, err := c.tr.extractTx(ctx).Exec(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("exec: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
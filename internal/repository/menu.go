@@ -2,125 +2,483 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/jackc/pgx/v4"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrCategoryNotFound      = errors.New("category not found")
+	ErrCategoryAlreadyExists = errors.New("category already exists")
+	ErrDishNotFound          = errors.New("dish not found")
+	ErrDishAlreadyExists     = errors.New("dish already exists")
 )
 
 type Menu interface {
-	GetAllCategories(ctx context.Context) ([]string, error)
-	GetActiveDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error)
+	GetAllCategories(ctx context.Context) ([]*model.Category, error)
+	GetCategory(ctx context.Context, category string) (*model.Category, error)
+	AddCategory(ctx context.Context, category *model.Category) error
+	SetCategoryVisibility(ctx context.Context, category string, visible bool) error
+	GetActiveDishesByCategory(ctx context.Context, category string, date time.Time) ([]*model.Dish, error)
 	GetStoppedDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error)
-	GetDish(ctx context.Context, dish string) (*model.Dish, error)
-	StopDish(ctx context.Context, dish string) error
-	ActivateDish(ctx context.Context, dish string) error
+	GetDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error)
+	GetDish(ctx context.Context, id int) (*model.Dish, error)
+	AddDish(ctx context.Context, dish *model.Dish) error
+	UpdateDish(ctx context.Context, dish *model.Dish) error
+	DeleteDish(ctx context.Context, id int) error
+	StopDish(ctx context.Context, id int) error
+	ActivateDish(ctx context.Context, id int) error
+	IsDishOnMenu(ctx context.Context, id int, date time.Time) (bool, error)
+	GetMenuDay(ctx context.Context, day *model.MenuDay) (map[int]bool, error)
+	AddDishToMenuDay(ctx context.Context, day *model.MenuDay, id int) error
+	RemoveDishFromMenuDay(ctx context.Context, day *model.MenuDay, id int) error
+	ClearMenuDay(ctx context.Context, day *model.MenuDay) error
 }
 
+// menu reads dishes from postgres and keeps them in memory until the next change
 type menu struct {
-	categories                []string
+	tr *transactor
+
+	mu                        sync.RWMutex
+	loaded                    bool
+	categories                []*model.Category
+	categoriesByButton        map[string]*model.Category
 	allDishesByCategories     map[string][]*model.Dish
 	activeDishesByCategories  map[string][]*model.Dish
 	stoppedDishesByCategories map[string][]*model.Dish
-	allDishes                 map[string]*model.Dish
+	allDishes                 map[int]*model.Dish
+
+	// dish ids planned for a date (2006-01-02) or a weekday
+	datedMenus  map[string]map[int]bool
+	weeklyMenus map[time.Weekday]map[int]bool
 }
 
-func NewMenu(categories []string, allDishesByCategories, activeDishesByCategories, stoppedDishesByCategories map[string][]*model.Dish,
-	allDishes map[string]*model.Dish) *menu {
+func NewMenu(tr *transactor) *menu {
 	return &menu{
-		categories:                categories,
-		allDishesByCategories:     allDishesByCategories,
-		activeDishesByCategories:  activeDishesByCategories,
-		stoppedDishesByCategories: stoppedDishesByCategories,
-		allDishes:                 allDishes,
+		tr: tr,
 	}
 }
 
-func (m *menu) GetAllCategories(_ context.Context) ([]string, error) {
+func (m *menu) GetAllCategories(ctx context.Context) ([]*model.Category, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.categories, nil
 }
 
-func (m *menu) GetActiveDishesByCategory(_ context.Context, category string) ([]*model.Dish, error) {
-	return m.activeDishesByCategories[category], nil
+// GetCategory finds category by its name or by the text of its button
+func (m *menu) GetCategory(ctx context.Context, category string) (*model.Category, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.categoriesByButton[category], nil
+}
+
+func (m *menu) AddCategory(ctx context.Context, category *model.Category) error {
+	query := `
+		INSERT INTO internal.categories (name, emoji, visible, sort_order)
+		SELECT $1, $2, $3, coalesce(max(sort_order), 0) + 1
+		FROM internal.categories
+		RETURNING id, sort_order`
+	err := m.tr.extractTx(ctx).QueryRow(ctx, query, category.Name, category.Emoji, category.Visible).
+		Scan(&category.ID, &category.SortOrder)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrCategoryAlreadyExists
+		}
+		return fmt.Errorf("queryRow: %w", err)
+	}
+	m.invalidate()
+	return nil
+}
+
+func (m *menu) SetCategoryVisibility(ctx context.Context, category string, visible bool) error {
+	query := `UPDATE internal.categories SET visible = $1 WHERE name = $2`
+	tag, err := m.tr.extractTx(ctx).Exec(ctx, query, visible, category)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCategoryNotFound
+	}
+	m.invalidate()
+	return nil
+}
+
+// GetActiveDishesByCategory returns not stopped dishes which are on the menu of the date
+func (m *menu) GetActiveDishesByCategory(ctx context.Context, category string, date time.Time) ([]*model.Dish, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	planned := m.plannedDishes(date)
+	if planned == nil {
+		return m.activeDishesByCategories[category], nil
+	}
+	var dishes []*model.Dish
+	for _, dish := range m.activeDishesByCategories[category] {
+		if planned[dish.ID] {
+			dishes = append(dishes, dish)
+		}
+	}
+	return dishes, nil
 }
 
 func (m *menu) GetStoppedDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.stoppedDishesByCategories[category], nil
 }
 
-func (m *menu) GetDish(_ context.Context, dish string) (*model.Dish, error) {
-	return m.getDish(dish)
+func (m *menu) GetDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.allDishesByCategories[category], nil
+}
+
+func (m *menu) GetDish(ctx context.Context, id int) (*model.Dish, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.allDishes[id], nil
+}
+
+func (m *menu) AddDish(ctx context.Context, dish *model.Dish) error {
+	query := `
+		INSERT INTO internal.dishes (name, price, description, photo, weight, calories, allergens, category_id)
+		SELECT $1, $2, $3, $4, $5, $6, $7, id
+		FROM internal.categories
+		WHERE name = $8
+		RETURNING id`
+	err := m.tr.extractTx(ctx).QueryRow(ctx, query, dish.Name, dish.Price, dish.Description, dish.Photo, dish.Weight,
+		dish.Calories, allergens(dish), dish.Category).Scan(&dish.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCategoryNotFound
+		}
+		if isUniqueViolation(err) {
+			return ErrDishAlreadyExists
+		}
+		return fmt.Errorf("queryRow: %w", err)
+	}
+	m.invalidate()
+	return nil
+}
+
+func (m *menu) UpdateDish(ctx context.Context, dish *model.Dish) error {
+	query := `
+		UPDATE internal.dishes AS d
+		SET name = $1, price = $2, description = $3, photo = $4, weight = $5, calories = $6, allergens = $7,
+		    category_id = c.id
+		FROM internal.categories AS c
+		WHERE c.name = $8
+		AND d.id = $9`
+	tag, err := m.tr.extractTx(ctx).Exec(ctx, query, dish.Name, dish.Price, dish.Description, dish.Photo, dish.Weight,
+		dish.Calories, allergens(dish), dish.Category, dish.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDishAlreadyExists
+		}
+		return fmt.Errorf("exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDishNotFound
+	}
+	m.invalidate()
+	return nil
+}
+
+func (m *menu) DeleteDish(ctx context.Context, id int) error {
+	query := `DELETE FROM internal.dishes WHERE id = $1`
+	tag, err := m.tr.extractTx(ctx).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDishNotFound
+	}
+	m.invalidate()
+	return nil
+}
+
+func (m *menu) StopDish(ctx context.Context, id int) error {
+	return m.changeDishState(ctx, id, true)
 }
 
-func (m *menu) StopDish(_ context.Context, dish string) error {
-	myDish, err := m.getDish(dish)
+func (m *menu) ActivateDish(ctx context.Context, id int) error {
+	return m.changeDishState(ctx, id, false)
+}
+
+func (m *menu) changeDishState(ctx context.Context, id int, stop bool) error {
+	query := `UPDATE internal.dishes SET stop = $1 WHERE id = $2`
+	_, err := m.tr.extractTx(ctx).Exec(ctx, query, stop, id)
 	if err != nil {
-		return err
+		return fmt.Errorf("exec: %w", err)
+	}
+	m.invalidate()
+	return nil
+}
+
+func (m *menu) IsDishOnMenu(ctx context.Context, id int, date time.Time) (bool, error) {
+	if err := m.load(ctx); err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.allDishes[id]; !ok {
+		return false, nil
+	}
+	planned := m.plannedDishes(date)
+	return planned == nil || planned[id], nil
+}
+
+// GetMenuDay returns ids of the dishes planned exactly for the day
+func (m *menu) GetMenuDay(ctx context.Context, day *model.MenuDay) (map[int]bool, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
 	}
-	m.changeDishState(myDish, true)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	activeDishes := m.updateActiveDishes()
-	m.activeDishesByCategories = activeDishes
+	var planned map[int]bool
+	if day.Weekly {
+		planned = m.weeklyMenus[day.Weekday]
+	} else {
+		planned = m.datedMenus[day.Date.Format(dateLayout)]
+	}
+	res := make(map[int]bool, len(planned))
+	for id := range planned {
+		res[id] = true
+	}
+	return res, nil
+}
 
-	stoppedDishes := m.updateStoppedDishes()
-	m.stoppedDishesByCategories = stoppedDishes
+func (m *menu) AddDishToMenuDay(ctx context.Context, day *model.MenuDay, id int) error {
+	query := `INSERT INTO internal.menu_days (dish_id, date, weekday) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	date, weekday := menuDayArgs(day)
+	_, err := m.tr.extractTx(ctx).Exec(ctx, query, id, date, weekday)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	m.invalidate()
+	return nil
+}
 
+func (m *menu) RemoveDishFromMenuDay(ctx context.Context, day *model.MenuDay, id int) error {
+	query := `
+		DELETE FROM internal.menu_days
+		WHERE dish_id = $1
+		AND date IS NOT DISTINCT FROM $2
+		AND weekday IS NOT DISTINCT FROM $3`
+	date, weekday := menuDayArgs(day)
+	_, err := m.tr.extractTx(ctx).Exec(ctx, query, id, date, weekday)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	m.invalidate()
 	return nil
 }
 
-func (m *menu) ActivateDish(_ context.Context, dish string) error {
-	myDish, err := m.getDish(dish)
+func (m *menu) ClearMenuDay(ctx context.Context, day *model.MenuDay) error {
+	query := `
+		DELETE FROM internal.menu_days
+		WHERE date IS NOT DISTINCT FROM $1
+		AND weekday IS NOT DISTINCT FROM $2`
+	date, weekday := menuDayArgs(day)
+	_, err := m.tr.extractTx(ctx).Exec(ctx, query, date, weekday)
 	if err != nil {
-		return err
+		return fmt.Errorf("exec: %w", err)
+	}
+	m.invalidate()
+	return nil
+}
+
+// plannedDishes returns ids of the dishes planned for the date or nil if the whole menu is available
+func (m *menu) plannedDishes(date time.Time) map[int]bool {
+	if planned := m.datedMenus[date.Format(dateLayout)]; len(planned) > 0 {
+		return planned
+	}
+	if planned := m.weeklyMenus[date.Weekday()]; len(planned) > 0 {
+		return planned
+	}
+	return nil
+}
+
+func (m *menu) load(ctx context.Context) error {
+	m.mu.RLock()
+	loaded := m.loaded
+	m.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.loaded {
+		return nil
 	}
-	m.changeDishState(myDish, false)
 
-	activeDishes := m.updateActiveDishes()
-	m.activeDishesByCategories = activeDishes
+	categories, err := m.getCategories(ctx)
+	if err != nil {
+		return fmt.Errorf("getCategories: %w", err)
+	}
+	dishes, err := m.getDishes(ctx)
+	if err != nil {
+		return fmt.Errorf("getDishes: %w", err)
+	}
+	datedMenus, weeklyMenus, err := m.getMenuDays(ctx)
+	if err != nil {
+		return fmt.Errorf("getMenuDays: %w", err)
+	}
+	m.datedMenus = datedMenus
+	m.weeklyMenus = weeklyMenus
 
-	stoppedDishes := m.updateStoppedDishes()
-	m.stoppedDishesByCategories = stoppedDishes
+	m.categories = categories
+	m.categoriesByButton = make(map[string]*model.Category)
+	for _, category := range categories {
+		m.categoriesByButton[category.Name] = category
+		m.categoriesByButton[category.String()] = category
+	}
+	m.allDishesByCategories = make(map[string][]*model.Dish)
+	m.activeDishesByCategories = make(map[string][]*model.Dish)
+	m.stoppedDishesByCategories = make(map[string][]*model.Dish)
+	m.allDishes = make(map[int]*model.Dish)
+	for _, dish := range dishes {
+		m.allDishesByCategories[dish.Category] = append(m.allDishesByCategories[dish.Category], dish)
+		if dish.Stop {
+			m.stoppedDishesByCategories[dish.Category] = append(m.stoppedDishesByCategories[dish.Category], dish)
+		} else {
+			m.activeDishesByCategories[dish.Category] = append(m.activeDishesByCategories[dish.Category], dish)
+		}
+		m.allDishes[dish.ID] = dish
+	}
+	m.loaded = true
 	return nil
 }
 
-func (m *menu) getDish(dish string) (*model.Dish, error) {
-	d, ok := m.allDishes[dish]
-	if !ok {
-		return nil, nil
+func (m *menu) invalidate() {
+	m.mu.Lock()
+	m.loaded = false
+	m.mu.Unlock()
+}
+
+func (m *menu) getCategories(ctx context.Context) ([]*model.Category, error) {
+	query := `SELECT id, name, sort_order, emoji, visible FROM internal.categories ORDER BY sort_order, name`
+	rows, err := m.tr.extractTx(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*model.Category
+	for rows.Next() {
+		var category model.Category
+		err = rows.Scan(&category.ID, &category.Name, &category.SortOrder, &category.Emoji, &category.Visible)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		categories = append(categories, &category)
 	}
-	return d, nil
+	return categories, nil
 }
 
-func (m *menu) updateActiveDishes() map[string][]*model.Dish {
-	dishes := make(map[string][]*model.Dish)
-	for category, ds := range m.allDishesByCategories {
-		for _, dish := range ds {
-			if dish.Stop {
-				continue
-			}
-			dishes[category] = append(dishes[category], dish)
+func (m *menu) getDishes(ctx context.Context) ([]*model.Dish, error) {
+	query := `
+		SELECT d.id, d.name, d.price, c.name, d.stop, d.description, d.photo, d.weight, d.calories, d.allergens
+		FROM internal.dishes AS d
+		JOIN internal.categories AS c ON c.id = d.category_id
+		ORDER BY c.sort_order, d.name`
+	rows, err := m.tr.extractTx(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var dishes []*model.Dish
+	for rows.Next() {
+		var dish model.Dish
+		err = rows.Scan(&dish.ID, &dish.Name, &dish.Price, &dish.Category, &dish.Stop, &dish.Description, &dish.Photo,
+			&dish.Weight, &dish.Calories, &dish.Allergens)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
 		}
+		dishes = append(dishes, &dish)
 	}
-	return dishes
+	return dishes, nil
 }
 
-func (m *menu) updateStoppedDishes() map[string][]*model.Dish {
-	dishes := make(map[string][]*model.Dish)
-	for category, ds := range m.allDishesByCategories {
-		for _, dish := range ds {
-			if !dish.Stop {
-				continue
+func (m *menu) getMenuDays(ctx context.Context) (map[string]map[int]bool, map[time.Weekday]map[int]bool, error) {
+	query := `SELECT dish_id, date, weekday FROM internal.menu_days`
+	rows, err := m.tr.extractTx(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	datedMenus := make(map[string]map[int]bool)
+	weeklyMenus := make(map[time.Weekday]map[int]bool)
+	for rows.Next() {
+		var (
+			dishID  int
+			date    *time.Time
+			weekday *int16
+		)
+		err = rows.Scan(&dishID, &date, &weekday)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scan: %w", err)
+		}
+		switch {
+		case date != nil:
+			key := date.Format(dateLayout)
+			if datedMenus[key] == nil {
+				datedMenus[key] = make(map[int]bool)
 			}
-			dishes[category] = append(dishes[category], dish)
+			datedMenus[key][dishID] = true
+		case weekday != nil:
+			day := time.Weekday(*weekday)
+			if weeklyMenus[day] == nil {
+				weeklyMenus[day] = make(map[int]bool)
+			}
+			weeklyMenus[day][dishID] = true
 		}
 	}
-	return dishes
+	return datedMenus, weeklyMenus, nil
 }
 
-func (m *menu) changeDishState(dish *model.Dish, state bool) {
-	dishes := m.allDishesByCategories[dish.Category]
-	for idx, resDish := range dishes {
-		if resDish.Name == dish.Name {
-			dishes[idx].Stop = state
-			break
-		}
+func menuDayArgs(day *model.MenuDay) (*time.Time, *int16) {
+	if day.Weekly {
+		weekday := int16(day.Weekday)
+		return nil, &weekday
+	}
+	date := time.Date(day.Date.Year(), day.Date.Month(), day.Date.Day(), 0, 0, 0, 0, time.UTC)
+	return &date, nil
+}
+
+// allergens never returns nil, because the column is not null
+func allergens(dish *model.Dish) []string {
+	if dish.Allergens == nil {
+		return []string{}
 	}
+	return dish.Allergens
 }
@@ -6,72 +6,225 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
 	"github.com/chucky-1/food-delivery-bot/internal/model"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 )
 
-var ErrLunchTimePassed = errors.New("lunch time has already passed")
+var (
+	ErrLunchTimePassed = errors.New("lunch time has already passed")
+	ErrDishNotInOrder  = errors.New("dish isn't in order")
+	ErrOrderConfirmed  = errors.New("order is already confirmed")
+	ErrOrderNotFound   = errors.New("order isn't found")
+)
 
 type Order interface {
-	AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64) error
-	GetAllDishesByCategory(ctx context.Context, userTelegramID int64) (map[string][]*model.Dish, error)
-	GetUserOrdersByOrganizationLunchTime(ctx context.Context, lunchTime string) (map[uuid.UUID]*model.OrderingData, error)
+	AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64, date time.Time, quantity int) error
+	SetDishQuantity(ctx context.Context, dishID int, userTelegramID int64, date time.Time, quantity int) error
+	GetAllDishesByCategory(ctx context.Context, userTelegramID int64, date time.Time) (map[string][]*model.DishWithCount, error)
+	GetConfirmedDishes(ctx context.Context, userTelegramID int64, date time.Time) ([]*model.DishWithCount, error)
+	GetHistory(ctx context.Context, userTelegramID int64, days int) ([]*model.DayOrder, error)
+	GetOrdersToShip(ctx context.Context, now time.Time) (map[uuid.UUID]*model.OrderingData, error)
 	GetOrdersAmount(ctx context.Context, from, to time.Time) (map[uuid.UUID]*model.Statistic, error)
-	IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64) (bool, error)
-	IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64) (bool, error)
-	ConfirmOrderByUser(ctx context.Context, userTelegramID int64) error
-	ClearOrdersByUser(ctx context.Context, userTelegramID int64, date time.Time) error
-	ClearOrdersByUserWithCheckLunchTime(ctx context.Context, userTelegramID int64, date time.Time) error
+	IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64, date time.Time) (bool, error)
+	IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64, date time.Time) (bool, error)
+	ConfirmOrderByUser(ctx context.Context, userTelegramID int64, date time.Time) (int, bool, error)
+	ClearOrdersByUser(ctx context.Context, userTelegramID int64, date time.Time) (bool, error)
+	ClearOrdersByUserWithCheckLunchTime(ctx context.Context, userTelegramID int64, date time.Time) (bool, error)
 }
 
 type order struct {
 	tr                                 *transactor
-	timezone                           time.Duration
+	clock                              clock.Clock
 	periodOfTimeBeforeLunchToShipOrder time.Duration
 }
 
-func NewOrder(tr *transactor, timezone time.Duration, periodOfTimeBeforeLunchToShipOrder time.Duration) *order {
+func NewOrder(tr *transactor, clock clock.Clock, periodOfTimeBeforeLunchToShipOrder time.Duration) *order {
 	return &order{
 		tr:                                 tr,
-		timezone:                           timezone,
+		clock:                              clock,
 		periodOfTimeBeforeLunchToShipOrder: periodOfTimeBeforeLunchToShipOrder,
 	}
 }
 
-func (o *order) AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64) error {
+// localTime returns the sql expression of the time $now in the time zone of the organization "alias",
+// $timezone is the time zone of the bot for organizations without their own one. It's truncated to minutes
+func localTime(alias string, now, timezone int) string {
+	return fmt.Sprintf("date_trunc('minute', $%d::timestamptz AT TIME ZONE coalesce(%s.timezone, $%d::text))",
+		now, alias, timezone)
+}
+
+// orderIsOpen returns the sql condition which is true while the order of the organization "alias" for $date
+// can be changed: $date is after the organization's today or there is more than $period before lunch
+func orderIsOpen(alias string, date, now, timezone, period int) string {
+	local := localTime(alias, now, timezone)
+	return fmt.Sprintf("($%d::date > (%s)::date OR %s.lunch_time > (%s)::time - time '00:00' + $%d::interval)",
+		date, local, alias, local, period)
+}
+
+// placed returns the sql condition which is true for orders of the header "alias" which are confirmed by users,
+// including the ones which are shipped
+func placed(alias string) string {
+	return fmt.Sprintf("%s.status NOT IN ('%s', '%s')", alias, model.OrderDraft, model.OrderCancelled)
+}
+
+// AddDish adds more portions of the dish if it's already in order for the date, otherwise adds the dish.
+// Orders for the next days can be changed any time, today's order - until the organization's orders are shipped
+func (o *order) AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64, date time.Time, quantity int) error {
+	id, err := o.openDraft(ctx, userTelegramID, date)
+	if err != nil {
+		return err
+	}
 	query := `
-		INSERT INTO internal.orders (date, user_telegram_id, dish_name, dish_price, category)
-			SELECT $1, $2, $3, $4, $5
-			WHERE EXISTS (
-				SELECT 1
-				FROM internal.users AS u
-				JOIN internal.organizations AS o ON u.organization_id = o.id
-				WHERE u.telegram_id = $2
-				AND o.lunch_time > $6)`
-	date := time.Now().UTC().Add(o.timezone)
-	tag, err := o.tr.extractTx(ctx).Exec(ctx, query, date, userTelegramID, dish.Name, dish.Price, dish.Category,
-		o.convertTimeToDurationMinusPeriodOfTimeBeforeLunchToShipOrder(time.Now().UTC().Add(o.timezone)))
+		INSERT INTO internal.order_items (order_id, dish_id, dish_name, dish_price, category, quantity)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (order_id, dish_id) DO UPDATE
+		SET quantity = order_items.quantity + excluded.quantity`
+	_, err = o.tr.extractTx(ctx).Exec(ctx, query, id, dish.ID, dish.Name, dish.Price, dish.Category, quantity)
 	if err != nil {
 		return fmt.Errorf("exec: %w", err)
 	}
-	if tag.RowsAffected() == 0 {
-		return ErrLunchTimePassed
+	return o.updateTotals(ctx, id)
+}
+
+// openDraft returns ID of the draft order of user for the date, the header is created for the first dish and
+// the cancelled order becomes the draft again
+func (o *order) openDraft(ctx context.Context, userTelegramID int64, date time.Time) (int, error) {
+	query := `
+		INSERT INTO internal.orders AS o (user_telegram_id, date, status, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $4
+		FROM internal.users AS u
+		JOIN internal.organizations AS org ON u.organization_id = org.id
+		WHERE u.telegram_id = $1
+		  AND ` + orderIsOpen("org", 2, 4, 5, 6) + `
+		ON CONFLICT (user_telegram_id, date) DO UPDATE
+		SET status = excluded.status, updated_at = excluded.updated_at
+		WHERE o.status IN ($3, $7)
+		RETURNING o.id`
+	var id int
+	err := o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date, model.OrderDraft, o.clock.Now(),
+		o.clock.Location().String(), o.periodOfTimeBeforeLunchToShipOrder, model.OrderCancelled).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("queryRow: %w", err)
+	}
+
+	query = `SELECT EXISTS (
+    SELECT 1
+    FROM internal.orders AS o
+    WHERE o.user_telegram_id = $1
+    AND o.date = $2
+    AND ` + placed("o") + `)`
+	var confirmed bool
+	err = o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date).Scan(&confirmed)
+	if err != nil {
+		return 0, fmt.Errorf("queryRow: %w", err)
+	}
+	if confirmed {
+		return 0, ErrOrderConfirmed
+	}
+	return 0, ErrLunchTimePassed
+}
+
+// updateTotals recounts the sum and the number of dishes of the order after its dishes are changed
+func (o *order) updateTotals(ctx context.Context, id int) error {
+	query := `
+		UPDATE internal.orders AS o
+		SET total = coalesce(i.total, 0), dishes_count = coalesce(i.count, 0), updated_at = $2
+		FROM (SELECT sum(dish_price * quantity) AS total, sum(quantity) AS count
+		      FROM internal.order_items
+		      WHERE order_id = $1) AS i
+		WHERE o.id = $1`
+	_, err := o.tr.extractTx(ctx).Exec(ctx, query, id, o.clock.Now())
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
 	}
 	return nil
 }
 
-func (o *order) GetAllDishesByCategory(ctx context.Context, userTelegramID int64) (map[string][]*model.Dish, error) {
-	query := `SELECT dish_name, dish_price, category FROM internal.orders WHERE user_telegram_id = $1`
-	rows, err := o.tr.extractTx(ctx).Query(ctx, query, userTelegramID)
+// SetDishQuantity changes the quantity of the dish in the draft order for the date. Zero quantity removes the dish
+func (o *order) SetDishQuantity(ctx context.Context, dishID int, userTelegramID int64, date time.Time, quantity int) error {
+	query := `
+		UPDATE internal.order_items AS i
+		SET quantity = $8
+		FROM internal.orders AS o
+		JOIN internal.users AS u ON u.telegram_id = o.user_telegram_id
+		JOIN internal.organizations AS org ON u.organization_id = org.id
+		WHERE i.order_id = o.id
+		  AND o.user_telegram_id = $1
+		  AND o.date = $2
+		  AND i.dish_id = $3
+		  AND o.status = $7
+		  AND ` + orderIsOpen("org", 2, 5, 6, 4) + `
+		RETURNING o.id`
+	if quantity <= 0 {
+		query = `
+		DELETE FROM internal.order_items AS i
+		USING internal.orders AS o
+		JOIN internal.users AS u ON u.telegram_id = o.user_telegram_id
+		JOIN internal.organizations AS org ON u.organization_id = org.id
+		WHERE i.order_id = o.id
+		  AND o.user_telegram_id = $1
+		  AND o.date = $2
+		  AND i.dish_id = $3
+		  AND o.status = $7
+		  AND ` + orderIsOpen("org", 2, 5, 6, 4) + `
+		RETURNING o.id`
+	}
+	args := []interface{}{userTelegramID, date, dishID, o.periodOfTimeBeforeLunchToShipOrder, o.clock.Now(),
+		o.clock.Location().String(), model.OrderDraft}
+	if quantity > 0 {
+		args = append(args, quantity)
+	}
+	var id int
+	err := o.tr.extractTx(ctx).QueryRow(ctx, query, args...).Scan(&id)
+	if err == nil {
+		return o.updateTotals(ctx, id)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("queryRow: %w", err)
+	}
+
+	query = `SELECT EXISTS (
+    SELECT 1
+    FROM internal.order_items AS i
+    JOIN internal.orders AS o ON o.id = i.order_id
+    WHERE o.user_telegram_id = $1
+    AND o.date = $2
+    AND i.dish_id = $3
+    AND o.status = $4)`
+	var exist bool
+	err = o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date, dishID, model.OrderDraft).Scan(&exist)
+	if err != nil {
+		return fmt.Errorf("queryRow: %w", err)
+	}
+	if exist {
+		return ErrLunchTimePassed
+	}
+	return ErrDishNotInOrder
+}
+
+func (o *order) GetAllDishesByCategory(ctx context.Context, userTelegramID int64, date time.Time) (map[string][]*model.DishWithCount, error) {
+	query := `
+		SELECT coalesce(i.dish_id, 0), i.dish_name, i.dish_price, i.category, i.quantity
+		FROM internal.order_items AS i
+		JOIN internal.orders AS o ON o.id = i.order_id
+		WHERE o.user_telegram_id = $1 AND o.date = $2
+		ORDER BY i.dish_name`
+	rows, err := o.tr.extractTx(ctx).Query(ctx, query, userTelegramID, date)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
 	defer rows.Close()
 
-	dishes := make(map[string][]*model.Dish)
+	dishes := make(map[string][]*model.DishWithCount)
 	for rows.Next() {
-		var dish model.Dish
-		err = rows.Scan(&dish.Name, &dish.Price, &dish.Category)
+		var dish model.DishWithCount
+		dish.Dish = &model.Dish{}
+		err = rows.Scan(&dish.ID, &dish.Name, &dish.Price, &dish.Category, &dish.Count)
 		if err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
 		}
@@ -80,17 +233,89 @@ func (o *order) GetAllDishesByCategory(ctx context.Context, userTelegramID int64
 	return dishes, nil
 }
 
-func (o *order) GetUserOrdersByOrganizationLunchTime(ctx context.Context, lunchTime string) (map[uuid.UUID]*model.OrderingData, error) {
+// GetConfirmedDishes returns dishes of the confirmed order of user for the date
+func (o *order) GetConfirmedDishes(ctx context.Context, userTelegramID int64, date time.Time) ([]*model.DishWithCount, error) {
 	query := `
-		SELECT org.id, org.name, org.address, o.dish_name, o.dish_price, o.category, count(1)
+		SELECT coalesce(i.dish_id, 0), i.dish_name, i.dish_price, i.category, i.quantity
+		FROM internal.order_items AS i
+		JOIN internal.orders AS o ON o.id = i.order_id
+		WHERE o.user_telegram_id = $1 AND o.date = $2 AND ` + placed("o") + `
+		ORDER BY i.dish_name`
+	rows, err := o.tr.extractTx(ctx).Query(ctx, query, userTelegramID, date)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var dishes []*model.DishWithCount
+	for rows.Next() {
+		dish := model.DishWithCount{Dish: &model.Dish{}}
+		err = rows.Scan(&dish.ID, &dish.Name, &dish.Price, &dish.Category, &dish.Count)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		dishes = append(dishes, &dish)
+	}
+	return dishes, nil
+}
+
+// GetHistory returns confirmed orders of user for the last days when user has ordered something, the newest first
+func (o *order) GetHistory(ctx context.Context, userTelegramID int64, days int) ([]*model.DayOrder, error) {
+	query := `
+		SELECT o.date, o.number, o.status, coalesce(i.dish_id, 0), i.dish_name, i.dish_price, i.category, i.quantity
+		FROM internal.orders AS o
+		JOIN internal.order_items AS i ON i.order_id = o.id
+		WHERE o.id IN (
+		    SELECT h.id
+		    FROM internal.orders AS h
+		    WHERE h.user_telegram_id = $1 AND ` + placed("h") + `
+		    ORDER BY h.date DESC
+		    LIMIT $2)
+		ORDER BY o.date DESC, i.dish_name`
+	rows, err := o.tr.extractTx(ctx).Query(ctx, query, userTelegramID, days)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*model.DayOrder
+	for rows.Next() {
+		var (
+			day  model.DayOrder
+			dish = model.DishWithCount{Dish: &model.Dish{}}
+		)
+		err = rows.Scan(&day.Date, &day.Number, &day.Status, &dish.ID, &dish.Name, &dish.Price, &dish.Category,
+			&dish.Count)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		if len(history) == 0 || history[len(history)-1].Number != day.Number {
+			history = append(history, &day)
+		}
+		last := history[len(history)-1]
+		last.Dishes = append(last.Dishes, &dish)
+	}
+	return history, nil
+}
+
+// GetOrdersToShip returns today's confirmed orders of organizations which have lunch in the period after now.
+// Today and lunch time are local for every organization
+func (o *order) GetOrdersToShip(ctx context.Context, now time.Time) (map[uuid.UUID]*model.OrderingData, error) {
+	local := localTime("org", 1, 2)
+	query := `
+		SELECT org.id, org.name, org.address, org.lunch_time, coalesce(org.timezone, ''), o.date,
+		       coalesce(i.dish_id, 0), i.dish_name, i.dish_price, i.category, sum(i.quantity)
 		FROM internal.orders o
-		LEFT JOIN internal.users u ON u.telegram_id = o.user_telegram_id
-		LEFT JOIN internal.organizations org ON org.id = u.organization_id
-		WHERE o.confirmed = true AND org.lunch_time = $1 AND date = $2
-		GROUP BY org.id, o.dish_name, o.dish_price, o.category`
-	date := time.Now().UTC().Add(o.timezone)
+		JOIN internal.order_items i ON i.order_id = o.id
+		JOIN internal.users u ON u.telegram_id = o.user_telegram_id
+		JOIN internal.organizations org ON org.id = u.organization_id
+		WHERE o.status = $4
+		  AND org.lunch_time = (` + local + `)::time - time '00:00' + $3::interval
+		  AND o.date = (` + local + `)::date
+		GROUP BY org.id, o.date, i.dish_id, i.dish_name, i.dish_price, i.category`
 
-	rows, err := o.tr.extractTx(ctx).Query(ctx, query, lunchTime, date)
+	rows, err := o.tr.extractTx(ctx).Query(ctx, query, now, o.clock.Location().String(), o.periodOfTimeBeforeLunchToShipOrder,
+		model.OrderConfirmed)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
@@ -102,12 +327,16 @@ func (o *order) GetUserOrdersByOrganizationLunchTime(ctx context.Context, lunchT
 			orgID      uuid.UUID
 			orgName    string
 			orgAddress string
+			lunchTime  time.Duration
+			timezone   string
+			date       time.Time
+			dishID     int
 			dishName   string
 			dishPrice  float32
 			category   string
 			count      int
 		)
-		err = rows.Scan(&orgID, &orgName, &orgAddress, &dishName, &dishPrice, &category, &count)
+		err = rows.Scan(&orgID, &orgName, &orgAddress, &lunchTime, &timezone, &date, &dishID, &dishName, &dishPrice, &category, &count)
 		if err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
 		}
@@ -116,14 +345,19 @@ func (o *order) GetUserOrdersByOrganizationLunchTime(ctx context.Context, lunchT
 			data = &model.OrderingData{
 				OrganizationName:    orgName,
 				OrganizationAddress: orgAddress,
+				LunchTime:           lunchTime,
+				Timezone:            timezone,
+				Date:                date,
 				DishesByCategories:  make(map[string][]*model.DishWithCount),
 			}
 			res[orgID] = data
 		}
 		data.DishesByCategories[category] = append(data.DishesByCategories[category], &model.DishWithCount{
 			Dish: &model.Dish{
-				Name:  dishName,
-				Price: dishPrice,
+				ID:       dishID,
+				Name:     dishName,
+				Price:    dishPrice,
+				Category: category,
 			},
 			Count: count,
 		})
@@ -139,12 +373,12 @@ func (o *order) GetOrdersAmount(ctx context.Context, from, to time.Time) (map[uu
 		    max(tg.first_name),
     		max(tg.last_name),
     		max(tg.username), 
-    		sum(o.dish_price)
+    		sum(o.total)::float
 		FROM internal.orders o
 		LEFT JOIN internal.users u ON u.telegram_id = o.user_telegram_id
 		LEFT JOIN internal.organizations org ON org.id = u.organization_id
 		LEFT JOIN telegram.users tg ON u.telegram_id = tg.id
-		WHERE o.confirmed = true AND o.date >= $1 AND o.date <= $2
+		WHERE ` + placed("o") + ` AND o.date >= $1 AND o.date <= $2
 		GROUP BY org.id, u.id`
 
 	rows, err := o.tr.extractTx(ctx).Query(ctx, query, from, to)
@@ -180,73 +414,143 @@ func (o *order) GetOrdersAmount(ctx context.Context, from, to time.Time) (map[uu
 	return res, nil
 }
 
-func (o *order) IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64) (bool, error) {
+func (o *order) IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64, date time.Time) (bool, error) {
 	query := `SELECT EXISTS (
     SELECT 1
-    FROM internal.orders
-    WHERE user_telegram_id = $1)`
+    FROM internal.order_items AS i
+    JOIN internal.orders AS o ON o.id = i.order_id
+    WHERE o.user_telegram_id = $1
+    AND o.date = $2)`
 	var exist bool
-	err := o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID).Scan(&exist)
+	err := o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date).Scan(&exist)
 	if err != nil {
 		return false, fmt.Errorf("queryRow: %w", err)
 	}
 	return exist, nil
 }
 
-func (o *order) IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64) (bool, error) {
+func (o *order) IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64, date time.Time) (bool, error) {
 	query := `SELECT EXISTS (
     SELECT 1
-    FROM internal.orders
-    WHERE user_telegram_id = $1
-    AND confirmed = true)`
+    FROM internal.orders AS o
+    WHERE o.user_telegram_id = $1
+    AND o.date = $2
+    AND ` + placed("o") + `)`
 	var exist bool
-	err := o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID).Scan(&exist)
+	err := o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date).Scan(&exist)
 	if err != nil {
 		return false, fmt.Errorf("queryRow: %w", err)
 	}
 	return exist, nil
 }
 
-func (o *order) ConfirmOrderByUser(ctx context.Context, userTelegramID int64) error {
-	query := `UPDATE internal.orders SET confirmed = true WHERE user_telegram_id = $1`
-	_, err := o.tr.extractTx(ctx).Exec(ctx, query, userTelegramID)
+// ConfirmOrderByUser confirms the draft order of user for the date and returns its number and true. Confirming
+// the confirmed order again returns its number and false. The draft can be confirmed only until the organization's
+// orders are shipped, otherwise it would never be shipped
+func (o *order) ConfirmOrderByUser(ctx context.Context, userTelegramID int64, date time.Time) (int, bool, error) {
+	query := `
+		UPDATE internal.orders AS o
+		SET status = $3, updated_at = $4
+		FROM internal.users AS u
+		JOIN internal.organizations AS org ON u.organization_id = org.id
+		WHERE o.user_telegram_id = u.telegram_id
+		  AND o.user_telegram_id = $1
+		  AND o.date = $2
+		  AND o.status = $5
+		  AND o.dishes_count > 0
+		  AND ` + orderIsOpen("org", 2, 4, 6, 7) + `
+		RETURNING o.number`
+	var number int
+	err := o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date, model.OrderConfirmed, o.clock.Now(),
+		model.OrderDraft, o.clock.Location().String(), o.periodOfTimeBeforeLunchToShipOrder).Scan(&number)
+	if err == nil {
+		return number, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, fmt.Errorf("queryRow: %w", err)
+	}
+
+	query = `SELECT o.status, o.number, o.dishes_count FROM internal.orders AS o WHERE o.user_telegram_id = $1 AND o.date = $2`
+	var (
+		status string
+		count  int
+	)
+	err = o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date).Scan(&status, &number, &count)
 	if err != nil {
-		return fmt.Errorf("exec: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, ErrOrderNotFound
+		}
+		return 0, false, fmt.Errorf("queryRow: %w", err)
 	}
-	return nil
+	switch {
+	case status != model.OrderDraft && status != model.OrderCancelled:
+		return number, false, nil
+	case status == model.OrderDraft && count > 0:
+		return 0, false, ErrLunchTimePassed
+	}
+	return 0, false, ErrOrderNotFound
 }
 
-func (o *order) ClearOrdersByUser(ctx context.Context, userTelegramID int64, date time.Time) error {
-	query := `DELETE FROM internal.orders WHERE user_telegram_id = $1 AND date = $2`
-	_, err := o.tr.extractTx(ctx).Exec(ctx, query, userTelegramID, date)
+// ClearOrdersByUser cancels the order of user for the date and removes its dishes unless it's shipped.
+// It returns true if the cancelled order was confirmed, clearing the draft doesn't cancel anything
+func (o *order) ClearOrdersByUser(ctx context.Context, userTelegramID int64, date time.Time) (bool, error) {
+	query := `
+		UPDATE internal.orders AS o
+		SET status = $3, total = 0, dishes_count = 0, updated_at = $4
+		FROM internal.orders AS old
+		WHERE old.id = o.id AND o.user_telegram_id = $1 AND o.date = $2 AND o.status IN ($5, $6)
+		RETURNING o.id, old.status`
+	var (
+		id     int
+		status string
+	)
+	err := o.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, date, model.OrderCancelled, o.clock.Now(),
+		model.OrderDraft, model.OrderConfirmed).Scan(&id, &status)
 	if err != nil {
-		return fmt.Errorf("exec: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("queryRow: %w", err)
 	}
-	return nil
+	return status == model.OrderConfirmed, o.removeItems(ctx, id)
 }
 
-func (o *order) ClearOrdersByUserWithCheckLunchTime(ctx context.Context, userTelegramID int64, date time.Time) error {
+// ClearOrdersByUserWithCheckLunchTime cancels the order like ClearOrdersByUser, but only until the organization's
+// orders are shipped
+func (o *order) ClearOrdersByUserWithCheckLunchTime(ctx context.Context, userTelegramID int64, date time.Time) (bool, error) {
 	query := `
-	DELETE FROM internal.orders AS o
-	USING internal.users AS u
-	LEFT JOIN internal.organizations AS org ON u.organization_id = org.id
-	WHERE o.user_telegram_id = u.telegram_id
+	UPDATE internal.orders AS o
+	SET status = $6, total = 0, dishes_count = 0, updated_at = $4
+	FROM internal.orders AS old, internal.users AS u
+	JOIN internal.organizations AS org ON u.organization_id = org.id
+	WHERE old.id = o.id
+	  AND o.user_telegram_id = u.telegram_id
 	  AND o.date = $1
-	  AND org.lunch_time > $2
-	  AND u.telegram_id = $3;`
-	tag, err := o.tr.extractTx(ctx).Exec(ctx, query, date,
-		o.convertTimeToDurationMinusPeriodOfTimeBeforeLunchToShipOrder(date), userTelegramID)
+	  AND o.status IN ($7, $8)
+	  AND ` + orderIsOpen("org", 1, 4, 5, 2) + `
+	  AND u.telegram_id = $3
+	RETURNING o.id, old.status`
+	var (
+		id     int
+		status string
+	)
+	err := o.tr.extractTx(ctx).QueryRow(ctx, query, date, o.periodOfTimeBeforeLunchToShipOrder, userTelegramID,
+		o.clock.Now(), o.clock.Location().String(), model.OrderCancelled, model.OrderDraft,
+		model.OrderConfirmed).Scan(&id, &status)
 	if err != nil {
-		return fmt.Errorf("exec: %w", err)
-	}
-	if tag.RowsAffected() == 0 {
-		return ErrLunchTimePassed
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrLunchTimePassed
+		}
+		return false, fmt.Errorf("queryRow: %w", err)
 	}
-	return nil
+	return status == model.OrderConfirmed, o.removeItems(ctx, id)
 }
 
-func (o *order) convertTimeToDurationMinusPeriodOfTimeBeforeLunchToShipOrder(t time.Time) time.Duration {
-	hour := t.Hour()
-	minute := t.Minute()
-	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + o.periodOfTimeBeforeLunchToShipOrder
+func (o *order) removeItems(ctx context.Context, id int) error {
+	query := `DELETE FROM internal.order_items WHERE order_id = $1`
+	_, err := o.tr.extractTx(ctx).Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
 }
@@ -12,6 +12,8 @@ type Organization interface {
 	Add(ctx context.Context, org *model.Organization) error
 	Join(ctx context.Context, organizationID uuid.UUID, userTelegramID int64) error
 	UpdateAddress(ctx context.Context, id uuid.UUID, address string) error
+	UpdateTimezone(ctx context.Context, id uuid.UUID, timezone string) error
+	GetLunchTimes(ctx context.Context) ([]model.LunchTime, error)
 }
 
 type organization struct {
@@ -53,3 +55,40 @@ func (o *organization) UpdateAddress(ctx context.Context, id uuid.UUID, address
 	}
 	return nil
 }
+
+// UpdateTimezone sets the time zone of the organization, empty timezone means the time zone of the bot
+func (o *organization) UpdateTimezone(ctx context.Context, id uuid.UUID, timezone string) error {
+	query := `UPDATE internal.organizations SET timezone = nullif($1, '') WHERE id = $2`
+	tag, err := o.tr.extractTx(ctx).Exec(ctx, query, timezone, id)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+// GetLunchTimes returns distinct lunch times of organizations with their time zones
+func (o *organization) GetLunchTimes(ctx context.Context) ([]model.LunchTime, error) {
+	query := `
+		SELECT DISTINCT lunch_time, coalesce(timezone, '')
+		FROM internal.organizations
+		WHERE lunch_time IS NOT NULL`
+	rows, err := o.tr.extractTx(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var lunchTimes []model.LunchTime
+	for rows.Next() {
+		var lunchTime model.LunchTime
+		err = rows.Scan(&lunchTime.Time, &lunchTime.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		lunchTimes = append(lunchTimes, lunchTime)
+	}
+	return lunchTimes, nil
+}
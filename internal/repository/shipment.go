@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/jackc/pgx/v4"
+)
+
+type Shipment interface {
+	Create(ctx context.Context, shipment *model.Shipment) (bool, error)
+	LinkOrders(ctx context.Context, shipment *model.Shipment) error
+	GetUnsent(ctx context.Context, now time.Time) ([]*model.Shipment, error)
+	SetStatus(ctx context.Context, ids []int, status string, now time.Time) error
+	Postpone(ctx context.Context, ids []int, reason string, nextAttemptAt time.Time) error
+	GetToday(ctx context.Context, now time.Time) ([]*model.Shipment, error)
+	SetOrderStatus(ctx context.Context, id int, from, to string, now time.Time) ([]*model.TelegramUser, error)
+}
+
+type shipment struct {
+	tr    *transactor
+	clock clock.Clock
+}
+
+func NewShipment(tr *transactor, clock clock.Clock) *shipment {
+	return &shipment{
+		tr:    tr,
+		clock: clock,
+	}
+}
+
+// Create sets ID of the shipment. It returns false if the shipment of the organization for the lunch on the date
+// already exists, so it isn't created twice
+func (s *shipment) Create(ctx context.Context, shipment *model.Shipment) (bool, error) {
+	query := `
+		INSERT INTO internal.shipments (organization_id, date, lunch_time, ship_at, status, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $4)
+		ON CONFLICT (organization_id, date, lunch_time) DO NOTHING
+		RETURNING id`
+	err := s.tr.extractTx(ctx).QueryRow(ctx, query, shipment.OrganizationID, shipment.Date, shipment.LunchTime,
+		shipment.ShipAt, model.ShipmentPending).Scan(&shipment.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("queryRow: %w", err)
+	}
+	return true, nil
+}
+
+// LinkOrders marks confirmed orders of the organization for the date as shipped by the shipment,
+// their status becomes sent to the kitchen
+func (s *shipment) LinkOrders(ctx context.Context, shipment *model.Shipment) error {
+	query := `
+		UPDATE internal.orders AS o
+		SET shipment_id = $1, status = $5, updated_at = $6
+		FROM internal.users AS u
+		WHERE o.user_telegram_id = u.telegram_id
+		  AND u.organization_id = $2
+		  AND o.date = $3
+		  AND o.status = $4
+		  AND o.shipment_id IS NULL`
+	_, err := s.tr.extractTx(ctx).Exec(ctx, query, shipment.ID, shipment.OrganizationID, shipment.Date,
+		model.OrderConfirmed, model.OrderSentToKitchen, shipment.ShipAt)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// GetUnsent returns shipments which aren't sent yet and whose next attempt is due, the oldest first
+func (s *shipment) GetUnsent(ctx context.Context, now time.Time) ([]*model.Shipment, error) {
+	query := `
+		SELECT s.id, s.organization_id, s.date, s.ship_at, s.status, s.attempts,
+		       org.name, org.address, s.lunch_time, coalesce(org.timezone, ''),
+		       coalesce(i.dish_id, 0), i.dish_name, i.dish_price, i.category, sum(i.quantity)
+		FROM internal.shipments AS s
+		JOIN internal.organizations AS org ON org.id = s.organization_id
+		JOIN internal.orders AS o ON o.shipment_id = s.id
+		JOIN internal.order_items AS i ON i.order_id = o.id
+		WHERE s.status <> $2 AND s.next_attempt_at <= $1
+		GROUP BY s.id, org.id, i.dish_id, i.dish_name, i.dish_price, i.category
+		ORDER BY s.ship_at, s.id, i.dish_name`
+	rows, err := s.tr.extractTx(ctx).Query(ctx, query, now, model.ShipmentSent)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var shipments []*model.Shipment
+	for rows.Next() {
+		var (
+			sh   = model.Shipment{OrderingData: &model.OrderingData{}}
+			dish = model.DishWithCount{Dish: &model.Dish{}}
+		)
+		err = rows.Scan(&sh.ID, &sh.OrganizationID, &sh.Date, &sh.ShipAt, &sh.Status, &sh.Attempts,
+			&sh.OrganizationName, &sh.OrganizationAddress, &sh.LunchTime, &sh.Timezone,
+			&dish.ID, &dish.Name, &dish.Price, &dish.Category, &dish.Count)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		if len(shipments) == 0 || shipments[len(shipments)-1].ID != sh.ID {
+			sh.DishesByCategories = make(map[string][]*model.DishWithCount)
+			shipments = append(shipments, &sh)
+		}
+		last := shipments[len(shipments)-1]
+		last.DishesByCategories[dish.Category] = append(last.DishesByCategories[dish.Category], &dish)
+	}
+	return shipments, nil
+}
+
+// SetStatus sets the status of shipments, sent shipments get the time they are sent
+func (s *shipment) SetStatus(ctx context.Context, ids []int, status string, now time.Time) error {
+	query := `
+		UPDATE internal.shipments
+		SET status = $2,
+		    sent_at = CASE WHEN $2 = $3 THEN $4::timestamptz END
+		WHERE id = ANY($1)`
+	_, err := s.tr.extractTx(ctx).Exec(ctx, query, ids, status, model.ShipmentSent, now)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// Postpone records the failed attempt to send shipments and when they are sent again
+func (s *shipment) Postpone(ctx context.Context, ids []int, reason string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE internal.shipments
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = ANY($1)`
+	_, err := s.tr.extractTx(ctx).Exec(ctx, query, ids, reason, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// shippedOrderStatuses are statuses of shipped orders from the least advanced one
+var shippedOrderStatuses = []string{model.OrderSentToKitchen, model.OrderCooking, model.OrderOutForDelivery,
+	model.OrderDelivered}
+
+// GetToday returns today's shipments with names of organizations and statuses of their orders, sorted by lunch time.
+// Today is local for every organization. Orders of the shipment are moved together, if they disagree the least
+// advanced status is taken
+func (s *shipment) GetToday(ctx context.Context, now time.Time) ([]*model.Shipment, error) {
+	query := `
+		SELECT s.id, s.organization_id, s.date, s.ship_at, s.status, s.attempts,
+		       org.name, s.lunch_time, coalesce(org.timezone, ''),
+		       coalesce(($2::text[])[min(array_position($2::text[], o.status::text))], '')
+		FROM internal.shipments AS s
+		JOIN internal.organizations AS org ON org.id = s.organization_id
+		JOIN internal.orders AS o ON o.shipment_id = s.id
+		WHERE s.date = (` + localTime("org", 1, 3) + `)::date
+		GROUP BY s.id, org.id
+		ORDER BY s.lunch_time, org.name`
+	rows, err := s.tr.extractTx(ctx).Query(ctx, query, now, shippedOrderStatuses,
+		s.clock.Location().String())
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var shipments []*model.Shipment
+	for rows.Next() {
+		sh := model.Shipment{OrderingData: &model.OrderingData{}}
+		err = rows.Scan(&sh.ID, &sh.OrganizationID, &sh.Date, &sh.ShipAt, &sh.Status, &sh.Attempts,
+			&sh.OrganizationName, &sh.LunchTime, &sh.Timezone, &sh.OrderStatus)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		shipments = append(shipments, &sh)
+	}
+	return shipments, nil
+}
+
+// SetOrderStatus moves orders of the shipment which have the status "from" to the status "to".
+// It returns customers whose orders are moved
+func (s *shipment) SetOrderStatus(ctx context.Context, id int, from, to string, now time.Time) ([]*model.TelegramUser, error) {
+	query := `
+		UPDATE internal.orders AS o
+		SET status = $3, updated_at = $4
+		FROM telegram.users AS t
+		WHERE t.id = o.user_telegram_id
+		  AND o.shipment_id = $1
+		  AND o.status = $2
+		RETURNING t.id, t.chat_id`
+	rows, err := s.tr.extractTx(ctx).Query(ctx, query, id, from, to, now)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []*model.TelegramUser
+	for rows.Next() {
+		var customer model.TelegramUser
+		err = rows.Scan(&customer.ID, &customer.ChatID)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		customers = append(customers, &customer)
+	}
+	return customers, nil
+}
@@ -5,21 +5,26 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
 	"github.com/chucky-1/food-delivery-bot/internal/model"
 )
 
 type Telegram interface {
 	AddUser(ctx context.Context, u *model.TelegramUser) error
-	GetUsersByLunchTimes(ctx context.Context, lunchTimes []string) (map[time.Duration][]*model.TelegramUser, error)
+	GetUsersToRemind(ctx context.Context, now time.Time, beforeLunch time.Duration) ([]*model.TelegramUser, error)
+	MarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time, beforeLunch time.Duration) (bool, error)
+	UnmarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time, beforeLunch time.Duration) error
 }
 
 type telegram struct {
-	tr *transactor
+	tr    *transactor
+	clock clock.Clock
 }
 
-func NewTelegram(tr *transactor) *telegram {
+func NewTelegram(tr *transactor, clock clock.Clock) *telegram {
 	return &telegram{
-		tr: tr,
+		tr:    tr,
+		clock: clock,
 	}
 }
 
@@ -32,35 +37,63 @@ func (t *telegram) AddUser(ctx context.Context, u *model.TelegramUser) error {
 	return nil
 }
 
-func (t *telegram) GetUsersByLunchTimes(ctx context.Context, lunchTimes []string) (map[time.Duration][]*model.TelegramUser, error) {
-	query := `SELECT t.id AS telegram_user_id, t.chat_id, io.lunch_time
+// GetUsersToRemind returns users without confirmed order whose organizations have lunch in beforeLunch after now.
+// Today and lunch time are local for every organization
+func (t *telegram) GetUsersToRemind(ctx context.Context, now time.Time, beforeLunch time.Duration) ([]*model.TelegramUser, error) {
+	local := localTime("io", 1, 2)
+	query := `SELECT t.id AS telegram_user_id, t.chat_id, io.id, (` + local + `)::date
 	FROM telegram.users AS t
 	JOIN internal.users AS iu ON t.id = iu.telegram_id
 	JOIN internal.organizations AS io ON iu.organization_id = io.id
-	WHERE io.lunch_time = ANY($1::interval[])
+	WHERE io.lunch_time = (` + local + `)::time - time '00:00' + $3::interval
 	AND NOT EXISTS (
     	SELECT 1
     	FROM internal.orders AS o
     	WHERE o.user_telegram_id = t.id
-    	AND o.confirmed = true
+    	AND o.date = (` + local + `)::date
+    	AND ` + placed("o") + `
     	)`
-	rows, err := t.tr.extractTx(ctx).Query(ctx, query, lunchTimes)
+	rows, err := t.tr.extractTx(ctx).Query(ctx, query, now, t.clock.Location().String(), beforeLunch)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
 	}
 	defer rows.Close()
 
-	telegramUsers := make(map[time.Duration][]*model.TelegramUser)
+	var telegramUsers []*model.TelegramUser
 	for rows.Next() {
-		var (
-			telegramUser model.TelegramUser
-			lunchTime    time.Duration
-		)
-		err = rows.Scan(&telegramUser.ID, &telegramUser.ChatID, &lunchTime)
+		var telegramUser model.TelegramUser
+		err = rows.Scan(&telegramUser.ID, &telegramUser.ChatID, &telegramUser.OrganizationID, &telegramUser.Today)
 		if err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
 		}
-		telegramUsers[lunchTime] = append(telegramUsers[lunchTime], &telegramUser)
+		telegramUsers = append(telegramUsers, &telegramUser)
 	}
 	return telegramUsers, nil
 }
+
+// MarkReminded records the reminder of user. It returns false if the reminder has already been recorded
+func (t *telegram) MarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time,
+	beforeLunch time.Duration) (bool, error) {
+	query := `
+		INSERT INTO internal.reminders (user_telegram_id, remind_at, before_lunch)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`
+	tag, err := t.tr.extractTx(ctx).Exec(ctx, query, userTelegramID, remindAt, beforeLunch)
+	if err != nil {
+		return false, fmt.Errorf("exec: %w", err)
+	}
+	return tag.RowsAffected() != 0, nil
+}
+
+// UnmarkReminded removes the record of the reminder which hasn't been sent
+func (t *telegram) UnmarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time,
+	beforeLunch time.Duration) error {
+	query := `
+		DELETE FROM internal.reminders
+		WHERE user_telegram_id = $1 AND remind_at = $2 AND before_lunch = $3`
+	_, err := t.tr.extractTx(ctx).Exec(ctx, query, userTelegramID, remindAt, beforeLunch)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	ErrTemplateNotFound      = errors.New("template not found")
+	ErrTemplateAlreadyExists = errors.New("template already exists")
+)
+
+type Template interface {
+	Add(ctx context.Context, userTelegramID int64, template *model.Template) error
+	GetAll(ctx context.Context, userTelegramID int64) ([]*model.Template, error)
+	Get(ctx context.Context, userTelegramID int64, id int) (*model.Template, error)
+	Delete(ctx context.Context, userTelegramID int64, id int) error
+}
+
+type template struct {
+	tr *transactor
+}
+
+func NewTemplate(tr *transactor) *template {
+	return &template{
+		tr: tr,
+	}
+}
+
+func (t *template) Add(ctx context.Context, userTelegramID int64, template *model.Template) error {
+	query := `INSERT INTO internal.templates (user_telegram_id, name) VALUES ($1, $2) RETURNING id`
+	err := t.tr.extractTx(ctx).QueryRow(ctx, query, userTelegramID, template.Name).Scan(&template.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrTemplateAlreadyExists
+		}
+		return fmt.Errorf("queryRow: %w", err)
+	}
+
+	batch := &pgx.Batch{}
+	for _, dish := range template.Dishes {
+		batch.Queue(`INSERT INTO internal.template_items (template_id, dish_id, dish_name, quantity) VALUES ($1, $2, $3, $4)`,
+			template.ID, dish.ID, dish.Name, dish.Count)
+	}
+	results := t.tr.extractTx(ctx).SendBatch(ctx, batch)
+	defer results.Close()
+	for range template.Dishes {
+		_, err = results.Exec()
+		if err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetAll returns templates of user sorted by name
+func (t *template) GetAll(ctx context.Context, userTelegramID int64) ([]*model.Template, error) {
+	query := `
+		SELECT t.id, t.name, coalesce(i.dish_id, 0), i.dish_name, i.quantity
+		FROM internal.templates AS t
+		JOIN internal.template_items AS i ON i.template_id = t.id
+		WHERE t.user_telegram_id = $1
+		ORDER BY t.name, i.dish_name`
+	rows, err := t.tr.extractTx(ctx).Query(ctx, query, userTelegramID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*model.Template
+	for rows.Next() {
+		var (
+			id   int
+			name string
+			dish = model.DishWithCount{Dish: &model.Dish{}}
+		)
+		err = rows.Scan(&id, &name, &dish.ID, &dish.Name, &dish.Count)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		if len(templates) == 0 || templates[len(templates)-1].ID != id {
+			templates = append(templates, &model.Template{ID: id, Name: name})
+		}
+		last := templates[len(templates)-1]
+		last.Dishes = append(last.Dishes, &dish)
+	}
+	return templates, nil
+}
+
+func (t *template) Get(ctx context.Context, userTelegramID int64, id int) (*model.Template, error) {
+	templates, err := t.GetAll(ctx, userTelegramID)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates {
+		if template.ID == id {
+			return template, nil
+		}
+	}
+	return nil, ErrTemplateNotFound
+}
+
+func (t *template) Delete(ctx context.Context, userTelegramID int64, id int) error {
+	query := `DELETE FROM internal.templates WHERE id = $1 AND user_telegram_id = $2`
+	tag, err := t.tr.extractTx(ctx).Exec(ctx, query, id, userTelegramID)
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
@@ -2,14 +2,24 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/chucky-1/food-delivery-bot/internal/metrics"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-const pgxKey = "pgxKey"
+const (
+	pgxKey = "pgxKey"
+
+	uniqueViolationCode     = "23505"
+	foreignKeyViolationCode = "23503"
+)
+
+var queryDuration = metrics.NewSummary("bot_db_query_duration_seconds", "Time of database queries")
 
 type Transactor interface {
 	Transact(ctx context.Context, txFn func(context.Context) error) error
@@ -58,7 +68,56 @@ func injectTx(ctx context.Context, tx pgx.Tx) context.Context {
 
 func (t *transactor) extractTx(ctx context.Context) commonPgx {
 	if tx, ok := ctx.Value(pgxKey).(pgx.Tx); ok {
-		return tx
+		return timed{tx}
 	}
-	return t.pool
+	return timed{t.pool}
+}
+
+// Ping checks that the database is reachable
+func (t *transactor) Ping(ctx context.Context) error {
+	defer queryDuration.Since(time.Now())
+	return t.pool.Ping(ctx)
+}
+
+// timed observes the duration of queries. Rows of Query are read after it returns, so only the wait of the first
+// answer is observed
+type timed struct {
+	commonPgx
+}
+
+func (t timed) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	defer queryDuration.Since(time.Now())
+	return t.commonPgx.Exec(ctx, sql, arguments...)
+}
+
+func (t timed) Query(ctx context.Context, sql string, optionsAndArgs ...interface{}) (pgx.Rows, error) {
+	defer queryDuration.Since(time.Now())
+	return t.commonPgx.Query(ctx, sql, optionsAndArgs...)
+}
+
+func (t timed) QueryRow(ctx context.Context, sql string, optionsAndArgs ...interface{}) pgx.Row {
+	row := timedRow{start: time.Now()}
+	row.Row = t.commonPgx.QueryRow(ctx, sql, optionsAndArgs...)
+	return row
+}
+
+// timedRow observes the duration when the row is scanned because the answer may be read only in Scan
+type timedRow struct {
+	pgx.Row
+	start time.Time
+}
+
+func (r timedRow) Scan(dest ...interface{}) error {
+	defer queryDuration.Since(r.start)
+	return r.Row.Scan(dest...)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode
 }
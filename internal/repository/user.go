@@ -3,10 +3,12 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/chucky-1/food-delivery-bot/internal/model"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 )
 
 type User interface {
@@ -14,6 +16,8 @@ type User interface {
 	UpdateFirstName(ctx context.Context, telegramUserID int, firstName string) error
 	UpdateLastName(ctx context.Context, telegramUserID int, lastName string) error
 	UpdateMiddleName(ctx context.Context, telegramUserID int, middleName string) error
+	GetOrganizationID(ctx context.Context, telegramUserID int64) (uuid.UUID, error)
+	GetTimezone(ctx context.Context, telegramUserID int64) (string, error)
 }
 
 type user struct {
@@ -66,3 +70,36 @@ func (u *user) UpdateMiddleName(ctx context.Context, telegramUserID int, middleN
 	}
 	return nil
 }
+
+// GetOrganizationID returns uuid.Nil if user hasn't joined any organization
+func (u *user) GetOrganizationID(ctx context.Context, telegramUserID int64) (uuid.UUID, error) {
+	query := `SELECT organization_id FROM internal.users WHERE telegram_id=$1`
+	var orgID uuid.NullUUID
+	err := u.tr.extractTx(ctx).QueryRow(ctx, query, telegramUserID).Scan(&orgID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, nil
+		}
+		return uuid.Nil, fmt.Errorf("queryRow: %w", err)
+	}
+	return orgID.UUID, nil
+}
+
+// GetTimezone returns the time zone of user's organization, empty if the organization has the time zone of the bot
+// or user hasn't joined any organization
+func (u *user) GetTimezone(ctx context.Context, telegramUserID int64) (string, error) {
+	query := `
+		SELECT coalesce(org.timezone, '')
+		FROM internal.users AS u
+		LEFT JOIN internal.organizations AS org ON org.id = u.organization_id
+		WHERE u.telegram_id = $1`
+	var timezone string
+	err := u.tr.extractTx(ctx).QueryRow(ctx, query, telegramUserID).Scan(&timezone)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("queryRow: %w", err)
+	}
+	return timezone, nil
+}
@@ -0,0 +1,273 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// searchYears is how far ahead the instant of the cron expression is looked for, e.g. "0 0 30 2 *" never comes
+const searchYears = 5
+
+// Schedule gives instants the job runs at. Instants are whole minutes
+type Schedule interface {
+	// Next returns the first instant after t in the location of t, zero time if there is none
+	Next(t time.Time) time.Time
+
+	String() string
+}
+
+// field is the set of allowed values of the cron field, the bit i is set if the value i is allowed
+type field uint64
+
+func (f field) has(value int) bool {
+	return f&(1<<uint(value)) != 0
+}
+
+type cron struct {
+	expr    string
+	minutes field
+	hours   field
+	days    field
+	months  field
+	weekday field
+
+	// if both the day of month and the day of week are restricted, the day matching either of them is allowed
+	daysRestricted    bool
+	weekdayRestricted bool
+}
+
+var shortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseCron parses the cron expression of 5 fields: minute, hour, day of month, month and day of week.
+// Fields are lists of values, ranges and steps, e.g. "*/15 9-18 * * 1-5", sunday is 0 or 7.
+// Shortcuts @hourly, @daily, @weekly and @monthly are supported too. Expressions which never come, e.g. "0 0 30 2 *",
+// are invalid
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	fields := strings.Fields(expr)
+	if shortcut, ok := shortcuts[expr]; ok {
+		fields = strings.Fields(shortcut)
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var parsed [5]field
+	for i, f := range fields {
+		var err error
+		parsed[i], err = parseField(f, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+	// 7 is sunday too
+	if parsed[4].has(7) {
+		parsed[4] |= 1
+	}
+	c := &cron{
+		expr:              expr,
+		minutes:           parsed[0],
+		hours:             parsed[1],
+		days:              parsed[2],
+		months:            parsed[3],
+		weekday:           parsed[4],
+		daysRestricted:    fields[2] != "*",
+		weekdayRestricted: fields[4] != "*",
+	}
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never comes", expr)
+	}
+	return c, nil
+}
+
+// MustParseCron is ParseCron which panics on the invalid expression, it's for expressions in the code
+func MustParseCron(expr string) Schedule {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
+// parseField parses the comma separated list of "*", "value", "from-to", each of them with the optional "/step"
+func parseField(f string, min, max int) (field, error) {
+	var result field
+	for _, part := range strings.Split(f, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rng = part[:i]
+		}
+		from, to := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var errFrom, errTo error
+			from, errFrom = strconv.Atoi(bounds[0])
+			to, errTo = strconv.Atoi(bounds[1])
+			if errFrom != nil || errTo != nil || from > to {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			value, err := strconv.Atoi(rng)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", rng)
+			}
+			from, to = value, value
+			// "5/10" means from 5 to the max every 10
+			if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			result |= 1 << uint(v)
+		}
+	}
+	return result, nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(searchYears, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.months.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !c.hours.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !c.minutes.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	day, weekday := c.days.has(t.Day()), c.weekday.has(int(t.Weekday()))
+	if c.daysRestricted && c.weekdayRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+func (c *cron) String() string {
+	return c.expr
+}
+
+const (
+	// lunchTimesTTL is how long lunch times of organizations are cached by BeforeLunch
+	lunchTimesTTL = time.Minute
+
+	// lunchTimesTimeout bounds loading of lunch times, instants of jobs aren't computed while they are loaded
+	lunchTimesTimeout = 10 * time.Second
+)
+
+type beforeLunch struct {
+	period        time.Duration
+	organizations service.Organization
+	clock         clock.Clock
+
+	mu         sync.Mutex
+	lunchTimes []model.LunchTime
+	loadedAt   time.Time
+}
+
+// BeforeLunch is the schedule of the job which handles organizations having lunch in the period after the instant.
+// Instants are the local lunch times of organizations minus the period. Lunch times are reloaded every minute, so
+// the job catches up instants of the changed lunch time since its last instant. If lunch times can't be loaded or
+// there are no organizations, the job runs every minute
+func BeforeLunch(period time.Duration, organizations service.Organization, clock clock.Clock) Schedule {
+	return &beforeLunch{period: period, organizations: organizations, clock: clock}
+}
+
+func (b *beforeLunch) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, lunchTime := range b.getLunchTimes() {
+		at := b.next(t, lunchTime)
+		if !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	if next.IsZero() {
+		return t.Truncate(time.Minute).Add(time.Minute)
+	}
+	return next
+}
+
+// next returns the first instant after t when organizations with the lunch time have lunch in the period, zero if
+// lunch is earlier than the period after midnight
+func (b *beforeLunch) next(t time.Time, lunchTime model.LunchTime) time.Time {
+	at := lunchTime.Time - b.period
+	if at < 0 {
+		return time.Time{}
+	}
+	location := t.Location()
+	if lunchTime.Timezone != "" {
+		var err error
+		location, err = clock.LoadLocation(lunchTime.Timezone)
+		if err != nil {
+			logrus.Errorf("scheduler: before lunch: %s", err.Error())
+			return time.Time{}
+		}
+	}
+	local := t.In(location)
+	for day := 0; day < 2; day++ {
+		next := time.Date(local.Year(), local.Month(), local.Day()+day, 0, int(at/time.Minute), 0, 0, location)
+		if next.After(t) {
+			return next.In(t.Location())
+		}
+	}
+	return time.Time{}
+}
+
+// getLunchTimes returns cached lunch times of organizations, they are reloaded after lunchTimesTTL. If they can't be
+// reloaded, the previous ones are returned
+func (b *beforeLunch) getLunchTimes() []model.LunchTime {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	if !b.loadedAt.IsZero() && now.Sub(b.loadedAt) < lunchTimesTTL {
+		return b.lunchTimes
+	}
+	b.loadedAt = now
+	ctx, cancel := context.WithTimeout(context.Background(), lunchTimesTimeout)
+	defer cancel()
+	lunchTimes, err := b.organizations.GetLunchTimes(ctx)
+	if err != nil {
+		logrus.Errorf("scheduler: before lunch: %s", err.Error())
+		return b.lunchTimes
+	}
+	b.lunchTimes = lunchTimes
+	return b.lunchTimes
+}
+
+func (b *beforeLunch) String() string {
+	return fmt.Sprintf("%s before lunch", b.period)
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+)
+
+func TestCronNext(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// monday
+	from := time.Date(2026, time.June, 1, 10, 7, 30, 0, location)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "* * * * *", want: time.Date(2026, time.June, 1, 10, 8, 0, 0, location)},
+		{expr: "*/15 * * * *", want: time.Date(2026, time.June, 1, 10, 15, 0, 0, location)},
+		{expr: "0 10 * * *", want: time.Date(2026, time.June, 2, 10, 0, 0, 0, location)},
+		{expr: "30 9-18 * * 1-5", want: time.Date(2026, time.June, 1, 10, 30, 0, 0, location)},
+		{expr: "0 9 * * 6,7", want: time.Date(2026, time.June, 6, 9, 0, 0, 0, location)},
+		{expr: "0 0 1 * *", want: time.Date(2026, time.July, 1, 0, 0, 0, 0, location)},
+		{expr: "@monthly", want: time.Date(2026, time.July, 1, 0, 0, 0, 0, location)},
+		// either the day of month or the day of week
+		{expr: "0 8 15 * 3", want: time.Date(2026, time.June, 3, 8, 0, 0, 0, location)},
+		{expr: "5/20 * * * *", want: time.Date(2026, time.June, 1, 10, 25, 0, 0, location)},
+		{expr: "0 0 29 2 *", want: time.Date(2028, time.February, 29, 0, 0, 0, 0, location)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%s: next is %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestCronNextInHalfHourZone checks that hours are local in time zones with the offset of half an hour
+func TestCronNextInHalfHourZone(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := MustParseCron("@hourly").Next(time.Date(2026, time.June, 1, 10, 45, 30, 0, location))
+	if want := time.Date(2026, time.June, 1, 11, 0, 0, 0, location); !got.Equal(want) {
+		t.Errorf("next is %s, want %s", got, want)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *",
+		"5-1 * * * *", "a * * * *", "@yearly", "0 0 30 2 *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q is parsed", expr)
+		}
+	}
+}
+
+type fakeOrganizations struct {
+	service.Organization
+	lunchTimes []model.LunchTime
+	err        error
+	loads      int
+}
+
+func (f *fakeOrganizations) GetLunchTimes(ctx context.Context) ([]model.LunchTime, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, errors.New("lunch times are loaded without the deadline")
+	}
+	f.loads++
+	return f.lunchTimes, f.err
+}
+
+func TestBeforeLunchNext(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	organizations := &fakeOrganizations{lunchTimes: []model.LunchTime{
+		{Time: 13 * time.Hour},
+		// 14:00 in Kolkata
+		{Time: 12*time.Hour + 30*time.Minute, Timezone: "Europe/Minsk"},
+		// orders must be shipped the day before, the repository never finds such organizations
+		{Time: 30 * time.Minute},
+	}}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.June, day, hour, minute, 0, 0, location)
+	}
+
+	tests := []struct {
+		from time.Time
+		want time.Time
+	}{
+		{from: at(1, 10, 45).Add(30 * time.Second), want: at(1, 12, 0)},
+		{from: at(1, 12, 0), want: at(1, 14, 0)},
+		{from: at(1, 14, 0), want: at(2, 12, 0)},
+	}
+	schedule := BeforeLunch(time.Hour, organizations, clock.NewFake(at(1, 10, 45)))
+	for _, tt := range tests {
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) || got.Location() != location {
+			t.Errorf("next after %s is %s, want %s", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestBeforeLunchWithoutLunchTimes(t *testing.T) {
+	from := time.Date(2026, time.June, 1, 10, 45, 30, 0, time.UTC)
+	want := time.Date(2026, time.June, 1, 10, 46, 0, 0, time.UTC)
+	for _, organizations := range []*fakeOrganizations{{}, {err: errors.New("postgres is down")}} {
+		if got := BeforeLunch(time.Hour, organizations, clock.NewFake(from)).Next(from); !got.Equal(want) {
+			t.Errorf("next is %s, want every minute: %s", got, want)
+		}
+	}
+}
+
+func TestBeforeLunchReloadsLunchTimes(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, time.June, 1, 10, 0, 0, 0, time.UTC))
+	organizations := &fakeOrganizations{lunchTimes: []model.LunchTime{{Time: 13 * time.Hour}}}
+	schedule := BeforeLunch(time.Hour, organizations, clk)
+	at := func(hour int) time.Time {
+		return time.Date(2026, time.June, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	if got := schedule.Next(clk.Now()); !got.Equal(at(12)) {
+		t.Errorf("next is %s, want %s", got, at(12))
+	}
+	// the lunch time is changed, the cached one is used until the fake clock moves
+	organizations.lunchTimes = []model.LunchTime{{Time: 14 * time.Hour}}
+	clk.Add(lunchTimesTTL - time.Second)
+	if got := schedule.Next(clk.Now()); !got.Equal(at(12)) {
+		t.Errorf("next is %s before reloading, want %s", got, at(12))
+	}
+	clk.Add(time.Second)
+	if got := schedule.Next(clk.Now()); !got.Equal(at(13)) {
+		t.Errorf("next is %s after reloading, want %s", got, at(13))
+	}
+	if organizations.loads != 2 {
+		t.Errorf("lunch times are loaded %d times, want 2", organizations.loads)
+	}
+}
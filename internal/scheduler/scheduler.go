@@ -0,0 +1,283 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/metrics"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// retryInterval is how long the job waits after the failed run to run the instant again
+	retryInterval = time.Minute
+
+	// maxWait is how long the job sleeps at most, so the job doesn't oversleep when the clock jumps
+	maxWait = time.Minute
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrJobRunning = errors.New("job is running")
+)
+
+// Job is the work which runs at instants of the schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+
+	// Timeout bounds one run, zero is no timeout
+	Timeout time.Duration
+
+	// CatchUp is whether instants which are missed while the bot isn't working are run later. Otherwise only
+	// the last instant which is due is run
+	CatchUp bool
+
+	Run func(ctx context.Context, at time.Time) error
+}
+
+// Info describes the job for the admin
+type Info struct {
+	Name     string
+	Schedule string
+
+	// LastRunAt is the instant of the last run, zero if the job hasn't run since the start
+	LastRunAt time.Time
+	LastError error
+	NextRunAt time.Time
+}
+
+type job struct {
+	Job
+
+	// mu is held while the job runs, so the job never runs twice at the same time
+	mu sync.Mutex
+
+	// last is the last instant which is processed, it's loaded from checkpoints on the first run
+	loaded  bool
+	last    time.Time
+	retryAt time.Time
+
+	infoMu    sync.Mutex
+	lastRunAt time.Time
+	lastErr   error
+
+	runs     *metrics.Counter
+	failures *metrics.Counter
+	duration *metrics.Summary
+}
+
+// Scheduler runs jobs at instants of their schedules in the location of the clock. Instants of jobs which
+// catch up are saved to checkpoints, so they are processed after the restart
+type Scheduler struct {
+	clock        clock.Clock
+	checkpoints  service.Checkpoint
+	catchUpLimit time.Duration
+	jobs         []*job
+}
+
+// New returns the scheduler, instants older than catchUpLimit aren't caught up. Without checkpoints jobs catch up
+// only while the bot is working
+func New(clock clock.Clock, checkpoints service.Checkpoint, catchUpLimit time.Duration) *Scheduler {
+	return &Scheduler{
+		clock:        clock,
+		checkpoints:  checkpoints,
+		catchUpLimit: catchUpLimit,
+	}
+}
+
+// Add registers jobs, it must be called before Run
+func (s *Scheduler) Add(jobs ...Job) {
+	for _, j := range jobs {
+		s.jobs = append(s.jobs, &job{
+			Job:      j,
+			runs:     metrics.NewCounter("bot_producer_runs_total", "Ticks of producers", "producer", j.Name),
+			failures: metrics.NewCounter("bot_producer_failures_total", "Failed ticks of producers", "producer", j.Name),
+			duration: metrics.NewSummary("bot_producer_duration_seconds", "Duration of ticks of producers",
+				"producer", j.Name),
+		})
+	}
+}
+
+// Run runs every job in its own goroutine until the context is done or schedules of all jobs have no more instants
+func (s *Scheduler) Run(ctx context.Context) {
+	logrus.Infof("scheduler started with %d jobs", len(s.jobs))
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+	wg.Wait()
+	logrus.Infof("scheduler stopped: %v", ctx.Err())
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := s.tick(ctx, j)
+		if next.IsZero() {
+			logrus.Warnf("scheduler: %s: the schedule %q has no more instants, the job is stopped", j.Name,
+				j.Schedule.String())
+			return
+		}
+		wait := min(next.Sub(s.clock.Now()), maxWait)
+		t := time.NewTimer(max(wait, 0))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Tick runs jobs which are due one after another in the order they are added
+func (s *Scheduler) Tick(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.tick(ctx, j)
+	}
+}
+
+// tick runs instants of the job which are due and returns when the job is due next time. If the run fails,
+// the instant is run again after retryInterval
+func (s *Scheduler) tick(ctx context.Context, j *job) time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := s.clock.Now()
+	if now.Before(j.retryAt) {
+		return j.retryAt
+	}
+	err := s.load(ctx, j, now)
+	if err != nil {
+		logrus.Errorf("scheduler: %s: %s", j.Name, err.Error())
+		j.retryAt = now.Add(retryInterval)
+		return j.retryAt
+	}
+
+	var due []time.Time
+	for at := j.Schedule.Next(j.last); !at.IsZero() && !at.After(now); at = j.Schedule.Next(at) {
+		due = append(due, at)
+	}
+	if !j.CatchUp && len(due) > 1 {
+		due = due[len(due)-1:]
+	}
+	if len(due) > 1 {
+		logrus.Infof("scheduler: %s: catching up %d instants from %s", j.Name, len(due), due[0])
+	}
+	for _, at := range due {
+		err = s.run(ctx, j, at)
+		if err == nil {
+			err = s.save(ctx, j, at)
+		}
+		if err != nil {
+			logrus.Errorf("scheduler: %s: %s", j.Name, err.Error())
+			j.retryAt = s.clock.Now().Add(retryInterval)
+			return j.retryAt
+		}
+	}
+	return j.Schedule.Next(j.last)
+}
+
+// load sets the last processed instant of the job on the first run. Without the checkpoint the instant before
+// now is the last one, so the current minute is run if it's due
+func (s *Scheduler) load(ctx context.Context, j *job, now time.Time) error {
+	if j.loaded {
+		return nil
+	}
+	j.last = now.Truncate(time.Minute).Add(-time.Nanosecond)
+	if j.CatchUp && s.checkpoints != nil {
+		last, err := s.checkpoints.Get(ctx, j.Name)
+		if err != nil {
+			return fmt.Errorf("load checkpoint: %w", err)
+		}
+		if !last.IsZero() {
+			j.last = last.In(now.Location())
+		}
+	}
+	if s.catchUpLimit > 0 && j.last.Before(now.Add(-s.catchUpLimit)) {
+		oldest := now.Add(-s.catchUpLimit).Truncate(time.Minute)
+		logrus.Warnf("scheduler: %s: instants from %s to %s are too old to be run", j.Name, j.last, oldest)
+		j.last = oldest.Add(-time.Nanosecond)
+	}
+	j.loaded = true
+	return nil
+}
+
+func (s *Scheduler) save(ctx context.Context, j *job, at time.Time) error {
+	j.last = at
+	if !j.CatchUp || s.checkpoints == nil {
+		return nil
+	}
+	err := s.checkpoints.Save(ctx, j.Name, at)
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// run runs the job for the instant with the timeout, the run is logged and counted
+func (s *Scheduler) run(ctx context.Context, j *job, at time.Time) error {
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	logrus.Debugf("scheduler: %s: running for %s", j.Name, at)
+	start := time.Now()
+	err := j.Run(ctx, at)
+	j.duration.Since(start)
+	j.runs.Inc()
+	if err != nil {
+		j.failures.Inc()
+		err = fmt.Errorf("run for %s: %w", at, err)
+	}
+
+	j.infoMu.Lock()
+	j.lastRunAt, j.lastErr = at, err
+	j.infoMu.Unlock()
+	return err
+}
+
+// Trigger runs the job for the current minute out of its schedule. Checkpoints aren't changed, so the instant
+// is run by the schedule too if it's due
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	for _, j := range s.jobs {
+		if j.Name != name {
+			continue
+		}
+		if !j.mu.TryLock() {
+			return ErrJobRunning
+		}
+		defer j.mu.Unlock()
+		logrus.Infof("scheduler: %s: triggered manually", j.Name)
+		return s.run(ctx, j, s.clock.Now().Truncate(time.Minute))
+	}
+	return ErrUnknownJob
+}
+
+// Jobs returns jobs in the order they are added
+func (s *Scheduler) Jobs() []Info {
+	infos := make([]Info, 0, len(s.jobs))
+	now := s.clock.Now()
+	for _, j := range s.jobs {
+		j.infoMu.Lock()
+		info := Info{
+			Name:      j.Name,
+			Schedule:  j.Schedule.String(),
+			LastRunAt: j.lastRunAt,
+			LastError: j.lastErr,
+			NextRunAt: j.Schedule.Next(now),
+		}
+		j.infoMu.Unlock()
+		infos = append(infos, info)
+	}
+	return infos
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/service"
+)
+
+type fakeCheckpoints struct {
+	service.Checkpoint
+	processedAt map[string]time.Time
+}
+
+func (f *fakeCheckpoints) Get(_ context.Context, job string) (time.Time, error) {
+	return f.processedAt[job], nil
+}
+
+func (f *fakeCheckpoints) Save(_ context.Context, job string, processedAt time.Time) error {
+	f.processedAt[job] = processedAt
+	return nil
+}
+
+// recorder is the job which records instants it has run, it fails while err is set
+type recorder struct {
+	runs []time.Time
+	err  error
+}
+
+func (r *recorder) run(_ context.Context, at time.Time) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.runs = append(r.runs, at)
+	return nil
+}
+
+func newClock(t *testing.T, hour, minute int) *clock.Fake {
+	t.Helper()
+	location, err := clock.LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clock.NewFake(time.Date(2026, time.June, 3, hour, minute, 0, 0, location))
+}
+
+func TestCatchUp(t *testing.T) {
+	clk := newClock(t, 9, 0)
+	checkpoints := &fakeCheckpoints{processedAt: map[string]time.Time{
+		"hourly": clk.Now().Add(-5 * time.Hour),
+		"outbox": clk.Now().Add(-5 * time.Hour),
+	}}
+	hourly, outbox := &recorder{}, &recorder{}
+	s := New(clk, checkpoints, 24*time.Hour)
+	s.Add(
+		Job{Name: "hourly", Schedule: MustParseCron("@hourly"), CatchUp: true, Run: hourly.run},
+		Job{Name: "outbox", Schedule: MustParseCron("* * * * *"), Run: outbox.run},
+	)
+
+	s.Tick(context.Background())
+	s.Tick(context.Background())
+	if len(hourly.runs) != 5 || !hourly.runs[4].Equal(clk.Now()) {
+		t.Errorf("hourly has run at %v, want 5 hours up to %s", hourly.runs, clk.Now())
+	}
+	if got := checkpoints.processedAt["hourly"]; !got.Equal(clk.Now()) {
+		t.Errorf("hourly has processed %s, want %s", got, clk.Now())
+	}
+	// the job which doesn't catch up runs only the current instant and doesn't save it
+	if len(outbox.runs) != 1 || !outbox.runs[0].Equal(clk.Now()) {
+		t.Errorf("outbox has run at %v, want %s", outbox.runs, clk.Now())
+	}
+	if got := checkpoints.processedAt["outbox"]; !got.Equal(clk.Now().Add(-5 * time.Hour)) {
+		t.Errorf("outbox checkpoint is changed to %s", got)
+	}
+}
+
+func TestCatchUpLimit(t *testing.T) {
+	clk := newClock(t, 9, 0)
+	checkpoints := &fakeCheckpoints{processedAt: map[string]time.Time{"hourly": clk.Now().AddDate(0, 0, -2)}}
+	hourly := &recorder{}
+	s := New(clk, checkpoints, 3*time.Hour)
+	s.Add(Job{Name: "hourly", Schedule: MustParseCron("@hourly"), CatchUp: true, Run: hourly.run})
+
+	s.Tick(context.Background())
+	if len(hourly.runs) != 4 || !hourly.runs[0].Equal(clk.Now().Add(-3*time.Hour)) {
+		t.Errorf("hourly has run at %v, want 4 hours up to %s", hourly.runs, clk.Now())
+	}
+}
+
+func TestFailedRunIsRetried(t *testing.T) {
+	clk := newClock(t, 10, 0)
+	checkpoints := &fakeCheckpoints{processedAt: map[string]time.Time{}}
+	daily := &recorder{err: errors.New("database is down")}
+	s := New(clk, checkpoints, 24*time.Hour)
+	s.Add(Job{Name: "daily", Schedule: MustParseCron("0 10 * * *"), CatchUp: true, Run: daily.run})
+	ctx := context.Background()
+
+	s.Tick(ctx)
+	if _, ok := checkpoints.processedAt["daily"]; ok {
+		t.Fatal("the failed instant is saved")
+	}
+	info := s.Jobs()[0]
+	if info.LastError == nil || !info.NextRunAt.Equal(clk.Now().AddDate(0, 0, 1)) {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	// the instant waits for the retry
+	daily.err = nil
+	clk.Add(30 * time.Second)
+	s.Tick(ctx)
+	if len(daily.runs) != 0 {
+		t.Fatalf("daily has run at %v before the retry", daily.runs)
+	}
+
+	clk.Add(30 * time.Second)
+	s.Tick(ctx)
+	if len(daily.runs) != 1 || daily.runs[0].Hour() != 10 || daily.runs[0].Minute() != 0 {
+		t.Errorf("daily has run at %v, want 10:00", daily.runs)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	clk := newClock(t, 10, 0)
+	s := New(clk, nil, 0)
+	s.Add(Job{Name: "slow", Schedule: MustParseCron("* * * * *"), Timeout: 10 * time.Millisecond,
+		Run: func(ctx context.Context, _ time.Time) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+
+	s.Tick(context.Background())
+	if err := s.Jobs()[0].LastError; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("last error is %v, want deadline exceeded", err)
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	clk := newClock(t, 10, 5)
+	checkpoints := &fakeCheckpoints{processedAt: map[string]time.Time{}}
+	daily := &recorder{}
+	s := New(clk, checkpoints, 24*time.Hour)
+	s.Add(Job{Name: "daily", Schedule: MustParseCron("0 10 * * *"), CatchUp: true, Run: daily.run})
+	ctx := context.Background()
+
+	if err := s.Trigger(ctx, "weekly"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("trigger of unknown job: %v", err)
+	}
+	if err := s.Trigger(ctx, "daily"); err != nil {
+		t.Fatal(err)
+	}
+	if len(daily.runs) != 1 || !daily.runs[0].Equal(clk.Now()) {
+		t.Errorf("daily has run at %v, want %s", daily.runs, clk.Now())
+	}
+	if len(checkpoints.processedAt) != 0 {
+		t.Errorf("checkpoints are changed: %v", checkpoints.processedAt)
+	}
+	if info := s.Jobs()[0]; !info.LastRunAt.Equal(clk.Now()) || info.Schedule != "0 10 * * *" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+// never is the schedule which has no instants
+type never struct{}
+
+func (never) Next(time.Time) time.Time { return time.Time{} }
+
+func (never) String() string { return "never" }
+
+func TestRunStopsJobWithoutInstants(t *testing.T) {
+	s := New(newClock(t, 10, 0), nil, 0)
+	job := &recorder{}
+	s.Add(Job{Name: "never", Schedule: never{}, Run: job.run})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the job without instants isn't stopped")
+	}
+	if len(job.runs) != 0 {
+		t.Errorf("job has run at %v", job.runs)
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/google/uuid"
+)
+
+type Calendar interface {
+	IsWorkingDay(ctx context.Context, date time.Time, organizationID uuid.UUID) (bool, error)
+	GetWorkingDays(ctx context.Context, from, to time.Time, organizationID uuid.UUID) ([]time.Time, error)
+	GetDays(ctx context.Context, from, to time.Time) ([]*model.CalendarDay, error)
+	SetDay(ctx context.Context, day *model.CalendarDay) error
+	DeleteDay(ctx context.Context, date time.Time, organizationID uuid.UUID) error
+}
+
+type calendar struct {
+	repo       repository.Calendar
+	transactor repository.Transactor
+}
+
+func NewCalendar(repo repository.Calendar, transactor repository.Transactor) *calendar {
+	return &calendar{
+		repo:       repo,
+		transactor: transactor,
+	}
+}
+
+// IsWorkingDay returns true if the organization works on the date. Zero organizationID means days of all organizations
+func (c *calendar) IsWorkingDay(ctx context.Context, date time.Time, organizationID uuid.UUID) (bool, error) {
+	days, err := c.repo.GetDays(ctx, date, date)
+	if err != nil {
+		return false, fmt.Errorf("getDays: %w", err)
+	}
+	return isWorkingDay(days, date, organizationID), nil
+}
+
+// GetWorkingDays returns working days of the organization between from and to inclusive
+func (c *calendar) GetWorkingDays(ctx context.Context, from, to time.Time, organizationID uuid.UUID) ([]time.Time, error) {
+	days, err := c.repo.GetDays(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("getDays: %w", err)
+	}
+	var working []time.Time
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		if isWorkingDay(days, date, organizationID) {
+			working = append(working, date)
+		}
+	}
+	return working, nil
+}
+
+func (c *calendar) GetDays(ctx context.Context, from, to time.Time) ([]*model.CalendarDay, error) {
+	days, err := c.repo.GetDays(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("getDays: %w", err)
+	}
+	return days, nil
+}
+
+func (c *calendar) SetDay(ctx context.Context, day *model.CalendarDay) error {
+	err := c.transactor.Transact(ctx, func(ctx context.Context) error {
+		return c.repo.SetDay(ctx, day)
+	})
+	if err != nil {
+		return fmt.Errorf("setDay: %w", err)
+	}
+	return nil
+}
+
+func (c *calendar) DeleteDay(ctx context.Context, date time.Time, organizationID uuid.UUID) error {
+	err := c.repo.DeleteDay(ctx, date, organizationID)
+	if err != nil {
+		return fmt.Errorf("deleteDay: %w", err)
+	}
+	return nil
+}
+
+// isWorkingDay checks the day of the organization first, then the day of all organizations, then the weekday
+func isWorkingDay(days []*model.CalendarDay, date time.Time, organizationID uuid.UUID) bool {
+	var common *model.CalendarDay
+	for _, day := range days {
+		if !day.Date.Equal(date) {
+			continue
+		}
+		if day.OrganizationID == organizationID {
+			return day.Working
+		}
+		if day.OrganizationID == uuid.Nil {
+			common = day
+		}
+	}
+	if common != nil {
+		return common.Working
+	}
+	return model.UsuallyWorking(date)
+}
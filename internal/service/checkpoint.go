@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+)
+
+type Checkpoint interface {
+	Get(ctx context.Context, producer string) (time.Time, error)
+	Save(ctx context.Context, producer string, processedAt time.Time) error
+}
+
+type checkpoint struct {
+	repo repository.Checkpoint
+}
+
+func NewCheckpoint(repo repository.Checkpoint) *checkpoint {
+	return &checkpoint{
+		repo: repo,
+	}
+}
+
+func (c *checkpoint) Get(ctx context.Context, producer string) (time.Time, error) {
+	processedAt, err := c.repo.Get(ctx, producer)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get: %w", err)
+	}
+	return processedAt, nil
+}
+
+func (c *checkpoint) Save(ctx context.Context, producer string, processedAt time.Time) error {
+	err := c.repo.Save(ctx, producer, processedAt)
+	if err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	return nil
+}
@@ -2,19 +2,48 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/chucky-1/food-delivery-bot/internal/model"
 	"github.com/chucky-1/food-delivery-bot/internal/repository"
 )
 
+const maxDishPrice = 10000
+
+var (
+	ErrInvalidCategoryName = errors.New("invalid category name")
+	ErrInvalidDishName     = errors.New("invalid dish name")
+	ErrInvalidDishPrice    = errors.New("invalid dish price")
+	ErrInvalidDescription  = errors.New("invalid dish description")
+	ErrInvalidDishWeight   = errors.New("invalid dish weight")
+	ErrInvalidDishCalories = errors.New("invalid dish calories")
+	ErrInvalidAllergens    = errors.New("invalid dish allergens")
+)
+
 type Menu interface {
-	GetAllCategories(ctx context.Context) ([]string, error)
-	GetActiveDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error)
+	GetAllCategories(ctx context.Context) ([]*model.Category, error)
+	GetVisibleCategories(ctx context.Context) ([]*model.Category, error)
+	GetCategory(ctx context.Context, category string) (*model.Category, error)
+	AddCategory(ctx context.Context, category *model.Category) error
+	SetCategoryVisibility(ctx context.Context, category string, visible bool) error
+	GetActiveDishesByCategory(ctx context.Context, category string, date time.Time) ([]*model.Dish, error)
 	GetStoppedDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error)
-	GetDish(ctx context.Context, dish string) (*model.Dish, error)
-	StopDish(ctx context.Context, dish string) error
-	ActivateDish(ctx context.Context, dish string) error
+	GetDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error)
+	GetDish(ctx context.Context, id int) (*model.Dish, error)
+	AddDish(ctx context.Context, dish *model.Dish) error
+	UpdateDish(ctx context.Context, dish *model.Dish) error
+	DeleteDish(ctx context.Context, id int) error
+	GetActiveDish(ctx context.Context, id int, date time.Time) (*model.Dish, error)
+	StopDish(ctx context.Context, id int) error
+	ActivateDish(ctx context.Context, id int) error
+	GetMenuDay(ctx context.Context, day *model.MenuDay) (map[int]bool, error)
+	AddDishToMenuDay(ctx context.Context, day *model.MenuDay, id int) error
+	RemoveDishFromMenuDay(ctx context.Context, day *model.MenuDay, id int) error
+	ClearMenuDay(ctx context.Context, day *model.MenuDay) error
 }
 
 type menu struct {
@@ -27,7 +56,7 @@ func NewMenu(repo repository.Menu) *menu {
 	}
 }
 
-func (m *menu) GetAllCategories(ctx context.Context) ([]string, error) {
+func (m *menu) GetAllCategories(ctx context.Context) ([]*model.Category, error) {
 	categories, err := m.repo.GetAllCategories(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getAllCategories: %w", err)
@@ -35,8 +64,51 @@ func (m *menu) GetAllCategories(ctx context.Context) ([]string, error) {
 	return categories, nil
 }
 
-func (m *menu) GetActiveDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error) {
-	dishes, err := m.repo.GetActiveDishesByCategory(ctx, category)
+func (m *menu) GetVisibleCategories(ctx context.Context) ([]*model.Category, error) {
+	categories, err := m.repo.GetAllCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getAllCategories: %w", err)
+	}
+	visible := make([]*model.Category, 0, len(categories))
+	for _, category := range categories {
+		if category.Visible {
+			visible = append(visible, category)
+		}
+	}
+	return visible, nil
+}
+
+func (m *menu) GetCategory(ctx context.Context, category string) (*model.Category, error) {
+	c, err := m.repo.GetCategory(ctx, category)
+	if err != nil {
+		return nil, fmt.Errorf("getCategory: %w", err)
+	}
+	return c, nil
+}
+
+func (m *menu) AddCategory(ctx context.Context, category *model.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" || utf8.RuneCountInString(category.Name) > 100 {
+		return ErrInvalidCategoryName
+	}
+	err := m.repo.AddCategory(ctx, category)
+	if err != nil {
+		return fmt.Errorf("addCategory: %w", err)
+	}
+	return nil
+}
+
+func (m *menu) SetCategoryVisibility(ctx context.Context, category string, visible bool) error {
+	err := m.repo.SetCategoryVisibility(ctx, category, visible)
+	if err != nil {
+		return fmt.Errorf("setCategoryVisibility: %w", err)
+	}
+	return nil
+}
+
+// GetActiveDishesByCategory returns dishes of the category which aren't stopped and are on the menu for the date
+func (m *menu) GetActiveDishesByCategory(ctx context.Context, category string, date time.Time) ([]*model.Dish, error) {
+	dishes, err := m.repo.GetActiveDishesByCategory(ctx, category, date)
 	if err != nil {
 		return nil, fmt.Errorf("getAllActiveDishesByCategory: %w", err)
 	}
@@ -51,26 +123,151 @@ func (m *menu) GetStoppedDishesByCategory(ctx context.Context, category string)
 	return dishes, nil
 }
 
-func (m *menu) GetDish(ctx context.Context, dish string) (*model.Dish, error) {
-	d, err := m.repo.GetDish(ctx, dish)
+func (m *menu) GetDishesByCategory(ctx context.Context, category string) ([]*model.Dish, error) {
+	dishes, err := m.repo.GetDishesByCategory(ctx, category)
+	if err != nil {
+		return nil, fmt.Errorf("getDishesByCategory: %w", err)
+	}
+	return dishes, nil
+}
+
+func (m *menu) GetDish(ctx context.Context, id int) (*model.Dish, error) {
+	d, err := m.repo.GetDish(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("getDish: %w", err)
+	}
+	return d, nil
+}
+
+// GetActiveDish returns the dish if it can be ordered for the date, otherwise nil
+func (m *menu) GetActiveDish(ctx context.Context, id int, date time.Time) (*model.Dish, error) {
+	d, err := m.repo.GetDish(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("getDish: %w", err)
 	}
+	if d == nil || d.Stop {
+		return nil, nil
+	}
+	onMenu, err := m.repo.IsDishOnMenu(ctx, id, date)
+	if err != nil {
+		return nil, fmt.Errorf("isDishOnMenu: %w", err)
+	}
+	if !onMenu {
+		return nil, nil
+	}
 	return d, nil
 }
 
-func (m *menu) StopDish(ctx context.Context, dish string) error {
-	err := m.repo.StopDish(ctx, dish)
+func (m *menu) AddDish(ctx context.Context, dish *model.Dish) error {
+	if err := validateDish(dish); err != nil {
+		return err
+	}
+	err := m.repo.AddDish(ctx, dish)
+	if err != nil {
+		return fmt.Errorf("addDish: %w", err)
+	}
+	return nil
+}
+
+func (m *menu) UpdateDish(ctx context.Context, dish *model.Dish) error {
+	if err := validateDish(dish); err != nil {
+		return err
+	}
+	category, err := m.repo.GetCategory(ctx, dish.Category)
+	if err != nil {
+		return fmt.Errorf("getCategory: %w", err)
+	}
+	if category == nil {
+		return repository.ErrCategoryNotFound
+	}
+	err = m.repo.UpdateDish(ctx, dish)
+	if err != nil {
+		return fmt.Errorf("updateDish: %w", err)
+	}
+	return nil
+}
+
+func (m *menu) DeleteDish(ctx context.Context, id int) error {
+	err := m.repo.DeleteDish(ctx, id)
+	if err != nil {
+		return fmt.Errorf("deleteDish: %w", err)
+	}
+	return nil
+}
+
+func (m *menu) StopDish(ctx context.Context, id int) error {
+	err := m.repo.StopDish(ctx, id)
 	if err != nil {
 		return fmt.Errorf("stopDish: %w", err)
 	}
 	return nil
 }
 
-func (m *menu) ActivateDish(ctx context.Context, dish string) error {
-	err := m.repo.ActivateDish(ctx, dish)
+func (m *menu) ActivateDish(ctx context.Context, id int) error {
+	err := m.repo.ActivateDish(ctx, id)
 	if err != nil {
 		return fmt.Errorf("activateDish: %w", err)
 	}
 	return nil
 }
+
+func (m *menu) GetMenuDay(ctx context.Context, day *model.MenuDay) (map[int]bool, error) {
+	planned, err := m.repo.GetMenuDay(ctx, day)
+	if err != nil {
+		return nil, fmt.Errorf("getMenuDay: %w", err)
+	}
+	return planned, nil
+}
+
+func (m *menu) AddDishToMenuDay(ctx context.Context, day *model.MenuDay, id int) error {
+	err := m.repo.AddDishToMenuDay(ctx, day, id)
+	if err != nil {
+		return fmt.Errorf("addDishToMenuDay: %w", err)
+	}
+	return nil
+}
+
+func (m *menu) RemoveDishFromMenuDay(ctx context.Context, day *model.MenuDay, id int) error {
+	err := m.repo.RemoveDishFromMenuDay(ctx, day, id)
+	if err != nil {
+		return fmt.Errorf("removeDishFromMenuDay: %w", err)
+	}
+	return nil
+}
+
+func (m *menu) ClearMenuDay(ctx context.Context, day *model.MenuDay) error {
+	err := m.repo.ClearMenuDay(ctx, day)
+	if err != nil {
+		return fmt.Errorf("clearMenuDay: %w", err)
+	}
+	return nil
+}
+
+func validateDish(dish *model.Dish) error {
+	dish.Name = strings.TrimSpace(dish.Name)
+	if dish.Name == "" || utf8.RuneCountInString(dish.Name) > 100 {
+		return ErrInvalidDishName
+	}
+	if dish.Price <= 0 || dish.Price > maxDishPrice {
+		return ErrInvalidDishPrice
+	}
+	dish.Description = strings.TrimSpace(dish.Description)
+	if utf8.RuneCountInString(dish.Description) > 900 {
+		return ErrInvalidDescription
+	}
+	if dish.Weight < 0 || dish.Weight > 10000 {
+		return ErrInvalidDishWeight
+	}
+	if dish.Calories < 0 || dish.Calories > 10000 {
+		return ErrInvalidDishCalories
+	}
+	if len(dish.Allergens) > 20 {
+		return ErrInvalidAllergens
+	}
+	for _, allergen := range dish.Allergens {
+		if allergen == "" || utf8.RuneCountInString(allergen) > 50 {
+			return ErrInvalidAllergens
+		}
+	}
+	return nil
+}
@@ -4,108 +4,414 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/metrics"
 	"github.com/chucky-1/food-delivery-bot/internal/model"
 	"github.com/chucky-1/food-delivery-bot/internal/repository"
-	"github.com/google/uuid"
 )
 
-var ErrWeekend = errors.New("now is weekend")
+var (
+	ErrDayOff              = errors.New("day off")
+	ErrInvalidDate         = errors.New("invalid date")
+	ErrInvalidQuantity     = errors.New("invalid quantity")
+	ErrEmptyOrder          = errors.New("order is empty")
+	ErrInvalidTemplateName = errors.New("invalid template name")
+	ErrTooManyTemplates    = errors.New("too many templates")
+)
+
+const orderEventsHelp = "Changes of orders by customers, added counts portions of dishes, cancelled counts confirmed orders"
+
+var (
+	dishesAdded     = metrics.NewCounter("bot_order_events_total", orderEventsHelp, "event", "added")
+	ordersConfirmed = metrics.NewCounter("bot_order_events_total", orderEventsHelp, "event", "confirmed")
+	ordersCancelled = metrics.NewCounter("bot_order_events_total", orderEventsHelp, "event", "cancelled")
+)
+
+const (
+	maxDishQuantity = 20
+	historyDays     = 10
+	maxTemplates    = 10
+)
 
 type Order interface {
-	AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64) error
-	GetAllDishesByCategory(ctx context.Context, userTelegramID int64) (map[string][]*model.Dish, error)
-	GetUserOrdersByOrganizationLunchTime(ctx context.Context, lunchTime string) (map[uuid.UUID]*model.OrderingData, error)
-	IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64) (bool, error)
-	IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64) (bool, error)
-	ConfirmOrderByUser(ctx context.Context, userTelegramID int64) error
+	Today(ctx context.Context, userTelegramID int64) (time.Time, error)
+	OrderDays(ctx context.Context, userTelegramID int64) ([]time.Time, error)
+	AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64, date time.Time) error
+	RepeatOrder(ctx context.Context, userTelegramID int64, from, to time.Time) (int, []*model.Dish, error)
+	GetHistory(ctx context.Context, userTelegramID int64) ([]*model.DayOrder, error)
+	SaveTemplate(ctx context.Context, userTelegramID int64, name string, date time.Time) (*model.Template, error)
+	GetTemplates(ctx context.Context, userTelegramID int64) ([]*model.Template, error)
+	ApplyTemplate(ctx context.Context, userTelegramID int64, id int, date time.Time) (int, []*model.Dish, error)
+	DeleteTemplate(ctx context.Context, userTelegramID int64, id int) error
+	RemoveDish(ctx context.Context, dishID int, userTelegramID int64, date time.Time) error
+	SetDishQuantity(ctx context.Context, dishID int, userTelegramID int64, date time.Time, quantity int) error
+	GetAllDishesByCategory(ctx context.Context, userTelegramID int64, date time.Time) (map[string][]*model.DishWithCount, error)
+	IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64, date time.Time) (bool, error)
+	IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64, date time.Time) (bool, error)
+	ConfirmOrderByUser(ctx context.Context, userTelegramID int64, date time.Time) (int, error)
 	ClearOrdersByUser(ctx context.Context, userTelegramID int64, date time.Time) error
 	ClearOrdersByUserWithCheckLunchTime(ctx context.Context, userTelegramID int64, date time.Time) error
 }
 
 type order struct {
-	repo repository.Order
+	repo       repository.Order
+	templates  repository.Template
+	users      repository.User
+	menu       Menu
+	calendar   Calendar
+	transactor repository.Transactor
+	clock      clock.Clock
+
+	// how many days ahead users can order lunch
+	horizonDays int
 }
 
-func NewOrder(repo repository.Order) *order {
+func NewOrder(repo repository.Order, templates repository.Template, users repository.User, menu Menu, calendar Calendar,
+	transactor repository.Transactor, clock clock.Clock, horizonDays int) *order {
 	return &order{
-		repo: repo,
+		repo:        repo,
+		templates:   templates,
+		users:       users,
+		menu:        menu,
+		calendar:    calendar,
+		transactor:  transactor,
+		clock:       clock,
+		horizonDays: horizonDays,
 	}
 }
 
-func (o *order) AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64) error {
-	if weekend() {
-		return ErrWeekend
+// Today returns the current date in the time zone of user's organization. Orders, menus and shipments of
+// the organization are for its local dates, so every date of user is counted from it
+func (o *order) Today(ctx context.Context, userTelegramID int64) (time.Time, error) {
+	timezone, err := o.users.GetTimezone(ctx, userTelegramID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("getTimezone: %w", err)
 	}
+	now, err := clock.In(o.clock, timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("in: %w", err)
+	}
+	return clock.Date(now), nil
+}
 
-	err := o.repo.AddDish(ctx, dish, userTelegramID)
+// OrderDays returns working days of user's organization from today up to the horizon when user can order lunch
+func (o *order) OrderDays(ctx context.Context, userTelegramID int64) ([]time.Time, error) {
+	orgID, err := o.users.GetOrganizationID(ctx, userTelegramID)
 	if err != nil {
-		return fmt.Errorf("addDish: %w", err)
+		return nil, fmt.Errorf("getOrganizationID: %w", err)
 	}
+	today, err := o.Today(ctx, userTelegramID)
+	if err != nil {
+		return nil, err
+	}
+	days, err := o.calendar.GetWorkingDays(ctx, today, today.AddDate(0, 0, o.horizonDays), orgID)
+	if err != nil {
+		return nil, fmt.Errorf("getWorkingDays: %w", err)
+	}
+	return days, nil
+}
+
+// checkDate returns an error if user can't order lunch for the date
+func (o *order) checkDate(ctx context.Context, userTelegramID int64, date time.Time) error {
+	today, err := o.Today(ctx, userTelegramID)
+	if err != nil {
+		return err
+	}
+	if date.Before(today) || date.After(today.AddDate(0, 0, o.horizonDays)) {
+		return ErrInvalidDate
+	}
+	orgID, err := o.users.GetOrganizationID(ctx, userTelegramID)
+	if err != nil {
+		return fmt.Errorf("getOrganizationID: %w", err)
+	}
+	working, err := o.calendar.IsWorkingDay(ctx, date, orgID)
+	if err != nil {
+		return fmt.Errorf("isWorkingDay: %w", err)
+	}
+	if !working {
+		return ErrDayOff
+	}
+	return nil
+}
+
+func (o *order) AddDish(ctx context.Context, dish *model.Dish, userTelegramID int64, date time.Time) error {
+	if err := o.checkDate(ctx, userTelegramID, date); err != nil {
+		return err
+	}
+
+	dishes, err := o.repo.GetAllDishesByCategory(ctx, userTelegramID, date)
+	if err != nil {
+		return fmt.Errorf("getAllDishesByCategory: %w", err)
+	}
+	for _, d := range dishes[dish.Category] {
+		if d.ID == dish.ID && d.Count >= maxDishQuantity {
+			return ErrInvalidQuantity
+		}
+	}
+
+	err = o.transactor.Transact(ctx, func(ctx context.Context) error {
+		err := o.repo.AddDish(ctx, dish, userTelegramID, date, 1)
+		if err != nil {
+			return fmt.Errorf("addDish: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	dishesAdded.Inc()
 	return nil
 }
 
-func (o *order) GetAllDishesByCategory(ctx context.Context, userTelegramID int64) (map[string][]*model.Dish, error) {
-	dishes, err := o.repo.GetAllDishesByCategory(ctx, userTelegramID)
+// RepeatOrder adds dishes of the confirmed order for the date "from" in the order for the date "to".
+// It returns the number of added portions and dishes which can't be ordered for that date, they aren't added
+func (o *order) RepeatOrder(ctx context.Context, userTelegramID int64, from, to time.Time) (int, []*model.Dish, error) {
+	dishes, err := o.repo.GetConfirmedDishes(ctx, userTelegramID, from)
+	if err != nil {
+		return 0, nil, fmt.Errorf("getConfirmedDishes: %w", err)
+	}
+	added, unavailable, err := o.addDishes(ctx, userTelegramID, dishes, to)
+	if err != nil {
+		return 0, nil, fmt.Errorf("addDishes: %w", err)
+	}
+	return added, unavailable, nil
+}
+
+// addDishes adds dishes in the order for the date in one transaction, the confirmed order can't be changed.
+// Dishes which are stopped or aren't on the menu for the date are skipped and returned with their old names and prices.
+// Portions over the maximum quantity aren't added, so the number of added portions may be zero without skipped dishes
+func (o *order) addDishes(ctx context.Context, userTelegramID int64, dishes []*model.DishWithCount,
+	date time.Time) (int, []*model.Dish, error) {
+	if err := o.checkDate(ctx, userTelegramID, date); err != nil {
+		return 0, nil, err
+	}
+
+	var (
+		unavailable []*model.Dish
+		added       int
+	)
+	err := o.transactor.Transact(ctx, func(ctx context.Context) error {
+		confirmed, err := o.repo.IsUserHaveConfirmedOrder(ctx, userTelegramID, date)
+		if err != nil {
+			return fmt.Errorf("isUserHaveConfirmedOrder: %w", err)
+		}
+		if confirmed {
+			return repository.ErrOrderConfirmed
+		}
+		ordered, err := o.repo.GetAllDishesByCategory(ctx, userTelegramID, date)
+		if err != nil {
+			return fmt.Errorf("getAllDishesByCategory: %w", err)
+		}
+		for _, d := range dishes {
+			dish, err := o.menu.GetActiveDish(ctx, d.ID, date)
+			if err != nil {
+				return fmt.Errorf("getActiveDish: %w", err)
+			}
+			if dish == nil {
+				unavailable = append(unavailable, d.Dish)
+				continue
+			}
+			quantity := d.Count
+			for _, od := range ordered[dish.Category] {
+				if od.ID == dish.ID {
+					quantity = min(quantity, maxDishQuantity-od.Count)
+				}
+			}
+			if quantity <= 0 {
+				continue
+			}
+			err = o.repo.AddDish(ctx, dish, userTelegramID, date, quantity)
+			if err != nil {
+				return fmt.Errorf("addDish: %w", err)
+			}
+			added += quantity
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	dishesAdded.Add(int64(added))
+	return added, unavailable, nil
+}
+
+func (o *order) GetHistory(ctx context.Context, userTelegramID int64) ([]*model.DayOrder, error) {
+	history, err := o.repo.GetHistory(ctx, userTelegramID, historyDays)
+	if err != nil {
+		return nil, fmt.Errorf("getHistory: %w", err)
+	}
+	return history, nil
+}
+
+func (o *order) RemoveDish(ctx context.Context, dishID int, userTelegramID int64, date time.Time) error {
+	return o.transactor.Transact(ctx, func(ctx context.Context) error {
+		err := o.repo.SetDishQuantity(ctx, dishID, userTelegramID, date, 0)
+		if err != nil {
+			return fmt.Errorf("removeDish: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetDishQuantity sets the quantity of the dish in order. Zero quantity removes the dish
+func (o *order) SetDishQuantity(ctx context.Context, dishID int, userTelegramID int64, date time.Time, quantity int) error {
+	if quantity < 0 || quantity > maxDishQuantity {
+		return ErrInvalidQuantity
+	}
+
+	return o.transactor.Transact(ctx, func(ctx context.Context) error {
+		err := o.repo.SetDishQuantity(ctx, dishID, userTelegramID, date, quantity)
+		if err != nil {
+			return fmt.Errorf("setDishQuantity: %w", err)
+		}
+		return nil
+	})
+}
+
+func (o *order) GetAllDishesByCategory(ctx context.Context, userTelegramID int64, date time.Time) (map[string][]*model.DishWithCount, error) {
+	dishes, err := o.repo.GetAllDishesByCategory(ctx, userTelegramID, date)
 	if err != nil {
 		return nil, fmt.Errorf("getAllDishesByCategory: %w", err)
 	}
 	return dishes, nil
 }
 
-func (o *order) GetUserOrdersByOrganizationLunchTime(ctx context.Context, lunchTime string) (map[uuid.UUID]*model.OrderingData, error) {
-	orders, err := o.repo.GetUserOrdersByOrganizationLunchTime(ctx, lunchTime)
+// SaveTemplate saves the order of user for the date as the template with the name
+func (o *order) SaveTemplate(ctx context.Context, userTelegramID int64, name string, date time.Time) (*model.Template, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 50 {
+		return nil, ErrInvalidTemplateName
+	}
+
+	template := &model.Template{Name: name}
+	err := o.transactor.Transact(ctx, func(ctx context.Context) error {
+		templates, err := o.templates.GetAll(ctx, userTelegramID)
+		if err != nil {
+			return fmt.Errorf("getAll: %w", err)
+		}
+		if len(templates) >= maxTemplates {
+			return ErrTooManyTemplates
+		}
+		dishesByCategories, err := o.repo.GetAllDishesByCategory(ctx, userTelegramID, date)
+		if err != nil {
+			return fmt.Errorf("getAllDishesByCategory: %w", err)
+		}
+		for _, dishes := range dishesByCategories {
+			for _, dish := range dishes {
+				// the dish has been removed from the menu, it can't be ordered again
+				if dish.ID == 0 {
+					continue
+				}
+				template.Dishes = append(template.Dishes, dish)
+			}
+		}
+		if len(template.Dishes) == 0 {
+			return ErrEmptyOrder
+		}
+		return o.templates.Add(ctx, userTelegramID, template)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("getUserOrdersByOrganizationLunchTime: %w", err)
+		return nil, fmt.Errorf("saveTemplate: %w", err)
 	}
-	return orders, nil
+	return template, nil
 }
 
-func (o *order) IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64) (bool, error) {
-	exist, err := o.repo.IsUserHaveAnyOrders(ctx, userTelegramID)
+func (o *order) GetTemplates(ctx context.Context, userTelegramID int64) ([]*model.Template, error) {
+	templates, err := o.templates.GetAll(ctx, userTelegramID)
+	if err != nil {
+		return nil, fmt.Errorf("getTemplates: %w", err)
+	}
+	return templates, nil
+}
+
+// ApplyTemplate adds dishes of the template in the order for the date.
+// It returns the number of added portions and dishes which can't be ordered for the date, they aren't added
+func (o *order) ApplyTemplate(ctx context.Context, userTelegramID int64, id int, date time.Time) (int, []*model.Dish, error) {
+	template, err := o.templates.Get(ctx, userTelegramID, id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("get: %w", err)
+	}
+	added, unavailable, err := o.addDishes(ctx, userTelegramID, template.Dishes, date)
+	if err != nil {
+		return 0, nil, fmt.Errorf("addDishes: %w", err)
+	}
+	return added, unavailable, nil
+}
+
+func (o *order) DeleteTemplate(ctx context.Context, userTelegramID int64, id int) error {
+	err := o.templates.Delete(ctx, userTelegramID, id)
+	if err != nil {
+		return fmt.Errorf("deleteTemplate: %w", err)
+	}
+	return nil
+}
+
+func (o *order) IsUserHaveAnyOrders(ctx context.Context, userTelegramID int64, date time.Time) (bool, error) {
+	exist, err := o.repo.IsUserHaveAnyOrders(ctx, userTelegramID, date)
 	if err != nil {
 		return false, fmt.Errorf("isUserHaveAnyOrders: %w", err)
 	}
 	return exist, nil
 }
 
-func (o *order) IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64) (bool, error) {
-	confirmedOrder, err := o.repo.IsUserHaveConfirmedOrder(ctx, userTelegramID)
+func (o *order) IsUserHaveConfirmedOrder(ctx context.Context, userTelegramID int64, date time.Time) (bool, error) {
+	confirmedOrder, err := o.repo.IsUserHaveConfirmedOrder(ctx, userTelegramID, date)
 	if err != nil {
 		return false, fmt.Errorf("isUserHaveConfirmedOrder: %w", err)
 	}
 	return confirmedOrder, nil
 }
 
-func (o *order) ConfirmOrderByUser(ctx context.Context, userTelegramID int64) error {
-	err := o.repo.ConfirmOrderByUser(ctx, userTelegramID)
+// ConfirmOrderByUser confirms the order for the date and returns its number
+func (o *order) ConfirmOrderByUser(ctx context.Context, userTelegramID int64, date time.Time) (int, error) {
+	number, confirmed, err := o.repo.ConfirmOrderByUser(ctx, userTelegramID, date)
 	if err != nil {
-		return fmt.Errorf("confirmOrderByUser: %w", err)
+		return 0, fmt.Errorf("confirmOrderByUser: %w", err)
 	}
-	return nil
+	if confirmed {
+		ordersConfirmed.Inc()
+	}
+	return number, nil
 }
 
 func (o *order) ClearOrdersByUser(ctx context.Context, userTelegramID int64, date time.Time) error {
-	err := o.repo.ClearOrdersByUser(ctx, userTelegramID, date)
+	var cancelled bool
+	err := o.transactor.Transact(ctx, func(ctx context.Context) error {
+		var err error
+		cancelled, err = o.repo.ClearOrdersByUser(ctx, userTelegramID, date)
+		if err != nil {
+			return fmt.Errorf("clearOrderByUser: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("clearOrderByUser: %w", err)
+		return err
+	}
+	if cancelled {
+		ordersCancelled.Inc()
 	}
 	return nil
 }
 
 func (o *order) ClearOrdersByUserWithCheckLunchTime(ctx context.Context, userTelegramID int64, date time.Time) error {
-	err := o.repo.ClearOrdersByUserWithCheckLunchTime(ctx, userTelegramID, date)
+	var cancelled bool
+	err := o.transactor.Transact(ctx, func(ctx context.Context) error {
+		var err error
+		cancelled, err = o.repo.ClearOrdersByUserWithCheckLunchTime(ctx, userTelegramID, date)
+		if err != nil {
+			return fmt.Errorf("clearOrdersByUserWithCheckLunchTime: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("clearOrdersByUserWithCheckLunchTime: %w", err)
+		return err
 	}
-	return nil
-}
-
-func weekend() bool {
-	day := time.Now().UTC().Weekday()
-	if day == 0 || day == 6 {
-		return true
+	if cancelled {
+		ordersCancelled.Inc()
 	}
-	return false
+	return nil
 }
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/google/uuid"
+)
+
+var organizationID = uuid.MustParse("6f1c1b8e-3c55-4a4e-9d59-5d0a3f7c2a11")
+
+type fakeUsers struct {
+	repository.User
+	timezone string
+}
+
+func (f *fakeUsers) GetOrganizationID(_ context.Context, _ int64) (uuid.UUID, error) {
+	return organizationID, nil
+}
+
+func (f *fakeUsers) GetTimezone(_ context.Context, _ int64) (string, error) {
+	return f.timezone, nil
+}
+
+type fakeCalendarRepository struct {
+	repository.Calendar
+	days []*model.CalendarDay
+}
+
+func (f *fakeCalendarRepository) GetDays(_ context.Context, from, to time.Time) ([]*model.CalendarDay, error) {
+	var days []*model.CalendarDay
+	for _, day := range f.days {
+		if !day.Date.Before(from) && !day.Date.After(to) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func newTestOrder(t *testing.T, now time.Time) *order {
+	t.Helper()
+	calendarRepo := &fakeCalendarRepository{
+		days: []*model.CalendarDay{
+			// the holiday of all organizations and the working saturday of the organization
+			{Date: date(time.June, 3), Working: false},
+			{Date: date(time.June, 6), OrganizationID: organizationID, Working: true},
+		},
+	}
+	return NewOrder(nil, nil, &fakeUsers{}, nil, NewCalendar(calendarRepo, nil), nil, clock.NewFake(now), 7)
+}
+
+func TestCheckDate(t *testing.T) {
+	location, err := clock.LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monday, the horizon is the next Monday
+	o := newTestOrder(t, time.Date(2026, time.June, 1, 22, 0, 0, 0, location))
+
+	tests := []struct {
+		date time.Time
+		want error
+	}{
+		{date: date(time.May, 31), want: ErrInvalidDate},
+		{date: date(time.June, 1)},
+		{date: date(time.June, 2)},
+		{date: date(time.June, 3), want: ErrDayOff},
+		{date: date(time.June, 6)},
+		{date: date(time.June, 7), want: ErrDayOff},
+		{date: date(time.June, 8)},
+		{date: date(time.June, 9), want: ErrInvalidDate},
+	}
+	for _, tt := range tests {
+		err = o.checkDate(context.Background(), 1, tt.date)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("checkDate(%s) = %v, want %v", tt.date.Format("2006-01-02"), err, tt.want)
+		}
+	}
+}
+
+func TestCheckDateAfterMidnight(t *testing.T) {
+	location, err := clock.LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// it's 21:30 in UTC, but already Tuesday in Minsk
+	o := newTestOrder(t, time.Date(2026, time.June, 2, 0, 30, 0, 0, location))
+	err = o.checkDate(context.Background(), 1, date(time.June, 1))
+	if !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("checkDate(yesterday) = %v, want %v", err, ErrInvalidDate)
+	}
+}
+
+func TestCheckDateInTimezoneOfOrganization(t *testing.T) {
+	location, err := clock.LoadLocation("Europe/Minsk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// it's still Monday for the bot, but already Tuesday in Kolkata
+	o := newTestOrder(t, time.Date(2026, time.June, 1, 22, 0, 0, 0, location))
+	o.users = &fakeUsers{timezone: "Asia/Kolkata"}
+
+	today, err := o.Today(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !today.Equal(date(time.June, 2)) {
+		t.Errorf("Today() = %s, want 2026-06-02", today)
+	}
+	err = o.checkDate(context.Background(), 1, date(time.June, 1))
+	if !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("checkDate(yesterday of the organization) = %v, want %v", err, ErrInvalidDate)
+	}
+	err = o.checkDate(context.Background(), 1, date(time.June, 9))
+	if err != nil {
+		t.Errorf("checkDate(the horizon of the organization) = %v", err)
+	}
+}
+
+func TestOrderDays(t *testing.T) {
+	o := newTestOrder(t, time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC))
+	days, err := o.OrderDays(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{date(time.June, 1), date(time.June, 2), date(time.June, 4), date(time.June, 5),
+		date(time.June, 6), date(time.June, 8)}
+	if len(days) != len(want) {
+		t.Fatalf("OrderDays() = %v, want %v", days, want)
+	}
+	for i := range days {
+		if !days[i].Equal(want[i]) {
+			t.Errorf("OrderDays()[%d] = %s, want %s", i, days[i], want[i])
+		}
+	}
+}
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) Transact(ctx context.Context, txFn func(context.Context) error) error {
+	return txFn(ctx)
+}
+
+// fakeOrderRepository has the confirmed order of user for the days, dishes are added to the others
+type fakeOrderRepository struct {
+	repository.Order
+	confirmed map[time.Time]bool
+	dishes    []*model.DishWithCount
+	added     int
+}
+
+func (f *fakeOrderRepository) IsUserHaveConfirmedOrder(_ context.Context, _ int64, date time.Time) (bool, error) {
+	return f.confirmed[date], nil
+}
+
+func (f *fakeOrderRepository) GetConfirmedDishes(_ context.Context, _ int64, _ time.Time) ([]*model.DishWithCount, error) {
+	return f.dishes, nil
+}
+
+func (f *fakeOrderRepository) AddDish(_ context.Context, _ *model.Dish, _ int64, _ time.Time, quantity int) error {
+	f.added += quantity
+	return nil
+}
+
+func (f *fakeOrderRepository) GetAllDishesByCategory(_ context.Context, _ int64,
+	_ time.Time) (map[string][]*model.DishWithCount, error) {
+	return nil, nil
+}
+
+func (f *fakeOrderRepository) ConfirmOrderByUser(_ context.Context, _ int64, date time.Time) (int, bool, error) {
+	if f.confirmed[date] {
+		return 1000, false, nil
+	}
+	f.confirmed[date] = true
+	return 1000, true, nil
+}
+
+func (f *fakeOrderRepository) ClearOrdersByUser(_ context.Context, _ int64, date time.Time) (bool, error) {
+	cancelled := f.confirmed[date]
+	delete(f.confirmed, date)
+	return cancelled, nil
+}
+
+// fakeMenu has every dish on the menu
+type fakeMenu struct {
+	Menu
+}
+
+func (f *fakeMenu) GetActiveDish(_ context.Context, id int, _ time.Time) (*model.Dish, error) {
+	return &model.Dish{ID: id, Name: "Борщ", Price: 5, Category: "Супы"}, nil
+}
+
+type fakeTemplates struct {
+	repository.Template
+	dishes []*model.DishWithCount
+}
+
+func (f *fakeTemplates) Get(_ context.Context, _ int64, id int) (*model.Template, error) {
+	return &model.Template{ID: id, Dishes: f.dishes}, nil
+}
+
+func TestAddDishesToConfirmedDay(t *testing.T) {
+	dishes := []*model.DishWithCount{{Dish: &model.Dish{ID: 1, Name: "Борщ", Price: 5, Category: "Супы"}, Count: 2}}
+	repo := &fakeOrderRepository{confirmed: map[time.Time]bool{date(time.June, 2): true}, dishes: dishes}
+	o := newTestOrder(t, time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC))
+	o.repo, o.templates, o.transactor = repo, &fakeTemplates{dishes: dishes}, fakeTransactor{}
+	ctx := context.Background()
+
+	_, _, err := o.RepeatOrder(ctx, 1, date(time.May, 29), date(time.June, 2))
+	if !errors.Is(err, repository.ErrOrderConfirmed) {
+		t.Errorf("RepeatOrder() = %v, want %v", err, repository.ErrOrderConfirmed)
+	}
+	_, _, err = o.ApplyTemplate(ctx, 1, 1, date(time.June, 2))
+	if !errors.Is(err, repository.ErrOrderConfirmed) {
+		t.Errorf("ApplyTemplate() = %v, want %v", err, repository.ErrOrderConfirmed)
+	}
+	if repo.added != 0 {
+		t.Errorf("%d portions are added to the confirmed order", repo.added)
+	}
+}
+
+func TestOrderEvents(t *testing.T) {
+	dishes := []*model.DishWithCount{{Dish: &model.Dish{ID: 1, Name: "Борщ", Price: 5, Category: "Супы"}, Count: 2}}
+	repo := &fakeOrderRepository{confirmed: map[time.Time]bool{date(time.June, 2): true}, dishes: dishes}
+	o := newTestOrder(t, time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC))
+	o.repo, o.menu, o.transactor = repo, &fakeMenu{}, fakeTransactor{}
+	ctx := context.Background()
+	added, confirmed, cancelled := dishesAdded.Value(), ordersConfirmed.Value(), ordersCancelled.Value()
+
+	// portions are counted, not repeated orders
+	n, _, err := o.RepeatOrder(ctx, 1, date(time.June, 2), date(time.June, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("RepeatOrder() added %d portions, want 2", n)
+	}
+	if got := dishesAdded.Value() - added; got != 2 {
+		t.Errorf("%d added dishes are counted, want 2", got)
+	}
+
+	// the confirmed order is confirmed again when the button is pressed twice
+	for i := 0; i < 2; i++ {
+		if _, err := o.ConfirmOrderByUser(ctx, 1, date(time.June, 4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := ordersConfirmed.Value() - confirmed; got != 1 {
+		t.Errorf("%d confirmed orders are counted, want 1", got)
+	}
+
+	// clearing the empty day or the cancelled order isn't the cancellation
+	for _, day := range []time.Time{date(time.June, 5), date(time.June, 4), date(time.June, 4)} {
+		if err := o.ClearOrdersByUser(ctx, 1, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := ordersCancelled.Value() - cancelled; got != 1 {
+		t.Errorf("%d cancelled orders are counted, want 1", got)
+	}
+}
@@ -2,17 +2,23 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
 	"github.com/chucky-1/food-delivery-bot/internal/model"
 	"github.com/chucky-1/food-delivery-bot/internal/repository"
 	"github.com/google/uuid"
 )
 
+var ErrInvalidTimezone = errors.New("invalid time zone")
+
 type Organization interface {
 	Add(ctx context.Context, org *model.Organization) error
 	Join(ctx context.Context, organizationID uuid.UUID, userTelegramID int64) error
 	UpdateAddress(ctx context.Context, id uuid.UUID, address string) error
+	UpdateTimezone(ctx context.Context, id uuid.UUID, timezone string) error
+	GetLunchTimes(ctx context.Context) ([]model.LunchTime, error)
 }
 
 type organization struct {
@@ -46,3 +52,28 @@ func (o *organization) UpdateAddress(ctx context.Context, id uuid.UUID, address
 	}
 	return nil
 }
+
+// UpdateTimezone sets the IANA time zone of the organization, empty timezone resets it to the time zone of the bot
+func (o *organization) UpdateTimezone(ctx context.Context, id uuid.UUID, timezone string) error {
+	if timezone != "" {
+		location, err := clock.LoadLocation(timezone)
+		if err != nil {
+			return ErrInvalidTimezone
+		}
+		timezone = location.String()
+	}
+	err := o.repo.UpdateTimezone(ctx, id, timezone)
+	if err != nil {
+		return fmt.Errorf("updateTimezone: %w", err)
+	}
+	return nil
+}
+
+// GetLunchTimes returns times organizations have lunch at, every time once for the time zone
+func (o *organization) GetLunchTimes(ctx context.Context) ([]model.LunchTime, error) {
+	lunchTimes, err := o.repo.GetLunchTimes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getLunchTimes: %w", err)
+	}
+	return lunchTimes, nil
+}
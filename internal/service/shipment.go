@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+)
+
+var ErrInvalidOrderStatus = errors.New("invalid order status")
+
+const (
+	// the first retry of the failed shipment is after retryInterval, every next one waits twice as long up to the max
+	retryInterval    = time.Minute
+	maxRetryInterval = 30 * time.Minute
+)
+
+type Shipment interface {
+	Create(ctx context.Context, now time.Time) ([]*model.OrderingData, error)
+	GetUnsent(ctx context.Context, now time.Time) ([]*model.Shipment, error)
+	SetStatus(ctx context.Context, shipments []*model.Shipment, status string, now time.Time) error
+	Postpone(ctx context.Context, shipments []*model.Shipment, reason error, now time.Time) error
+	GetToday(ctx context.Context, now time.Time) ([]*model.Shipment, error)
+	Advance(ctx context.Context, id int, from string, now time.Time) ([]*model.TelegramUser, error)
+}
+
+type shipment struct {
+	repo       repository.Shipment
+	orders     repository.Order
+	calendar   Calendar
+	transactor repository.Transactor
+}
+
+func NewShipment(repo repository.Shipment, orders repository.Order, calendar Calendar,
+	transactor repository.Transactor) *shipment {
+	return &shipment{
+		repo:       repo,
+		orders:     orders,
+		calendar:   calendar,
+		transactor: transactor,
+	}
+}
+
+// Create records shipments of organizations which have lunch in the period after now and marks their orders as
+// shipped, so orders are never shipped twice. Orders of organizations which don't work on the date aren't shipped,
+// they are returned
+func (s *shipment) Create(ctx context.Context, now time.Time) ([]*model.OrderingData, error) {
+	var dayOff []*model.OrderingData
+	err := s.transactor.Transact(ctx, func(ctx context.Context) error {
+		dataByOrganizationID, err := s.orders.GetOrdersToShip(ctx, now)
+		if err != nil {
+			return fmt.Errorf("getOrdersToShip: %w", err)
+		}
+		for orgID, data := range dataByOrganizationID {
+			working, err := s.calendar.IsWorkingDay(ctx, data.Date, orgID)
+			if err != nil {
+				return fmt.Errorf("isWorkingDay: %w", err)
+			}
+			if !working {
+				dayOff = append(dayOff, data)
+				continue
+			}
+			sh := &model.Shipment{OrganizationID: orgID, Date: data.Date, ShipAt: now, OrderingData: data}
+			created, err := s.repo.Create(ctx, sh)
+			if err != nil {
+				return fmt.Errorf("create: %w", err)
+			}
+			if !created {
+				continue
+			}
+			err = s.repo.LinkOrders(ctx, sh)
+			if err != nil {
+				return fmt.Errorf("linkOrders: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dayOff, nil
+}
+
+// GetUnsent returns shipments which must be sent now, the oldest first
+func (s *shipment) GetUnsent(ctx context.Context, now time.Time) ([]*model.Shipment, error) {
+	shipments, err := s.repo.GetUnsent(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("getUnsent: %w", err)
+	}
+	return shipments, nil
+}
+
+func (s *shipment) SetStatus(ctx context.Context, shipments []*model.Shipment, status string, now time.Time) error {
+	err := s.repo.SetStatus(ctx, shipmentIDs(shipments), status, now)
+	if err != nil {
+		return fmt.Errorf("setStatus: %w", err)
+	}
+	for _, sh := range shipments {
+		sh.Status = status
+	}
+	return nil
+}
+
+// Postpone schedules the next attempt to send shipments, the more attempts have failed the later it is
+func (s *shipment) Postpone(ctx context.Context, shipments []*model.Shipment, reason error, now time.Time) error {
+	var attempts int
+	for _, sh := range shipments {
+		attempts = max(attempts, sh.Attempts)
+	}
+	err := s.repo.Postpone(ctx, shipmentIDs(shipments), reason.Error(), now.Add(retryDelay(attempts)))
+	if err != nil {
+		return fmt.Errorf("postpone: %w", err)
+	}
+	return nil
+}
+
+// GetToday returns shipments for the local today of their organizations with statuses of their orders
+func (s *shipment) GetToday(ctx context.Context, now time.Time) ([]*model.Shipment, error) {
+	shipments, err := s.repo.GetToday(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("getToday: %w", err)
+	}
+	return shipments, nil
+}
+
+// Advance moves orders of the shipment from the status to the next one, e.g. from cooking to out for delivery.
+// It returns customers whose orders are moved, there are none if orders have already been moved
+func (s *shipment) Advance(ctx context.Context, id int, from string, now time.Time) ([]*model.TelegramUser, error) {
+	to := model.NextOrderStatus(from)
+	if to == "" {
+		return nil, ErrInvalidOrderStatus
+	}
+	customers, err := s.repo.SetOrderStatus(ctx, id, from, to, now)
+	if err != nil {
+		return nil, fmt.Errorf("setOrderStatus: %w", err)
+	}
+	return customers, nil
+}
+
+// retryDelay returns how long to wait after the attempt number "attempts" failed, the first one is zero
+func retryDelay(attempts int) time.Duration {
+	delay := retryInterval
+	for i := 0; i < attempts && delay < maxRetryInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryInterval)
+}
+
+func shipmentIDs(shipments []*model.Shipment) []int {
+	ids := make([]int, 0, len(shipments))
+	for _, sh := range shipments {
+		ids = append(ids, sh.ID)
+	}
+	return ids
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: 2 * time.Minute},
+		{attempts: 3, want: 8 * time.Minute},
+		{attempts: 5, want: 30 * time.Minute},
+		{attempts: 100, want: 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+type fakeShipmentRepository struct {
+	repository.Shipment
+	moved [][2]string
+}
+
+func (f *fakeShipmentRepository) SetOrderStatus(_ context.Context, _ int, from, to string,
+	_ time.Time) ([]*model.TelegramUser, error) {
+	f.moved = append(f.moved, [2]string{from, to})
+	return []*model.TelegramUser{{ID: 1, ChatID: 1}}, nil
+}
+
+func TestAdvance(t *testing.T) {
+	tests := []struct {
+		from string
+		want string
+	}{
+		{from: model.OrderSentToKitchen, want: model.OrderCooking},
+		{from: model.OrderCooking, want: model.OrderOutForDelivery},
+		{from: model.OrderOutForDelivery, want: model.OrderDelivered},
+		{from: model.OrderDelivered},
+		{from: model.OrderConfirmed},
+		{from: model.OrderCancelled},
+	}
+	for _, tt := range tests {
+		repo := &fakeShipmentRepository{}
+		_, err := NewShipment(repo, nil, nil, nil).Advance(context.Background(), 1, tt.from, time.Now())
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidOrderStatus) || len(repo.moved) != 0 {
+				t.Errorf("%s: got %v, moved %v, want invalid status", tt.from, err, repo.moved)
+			}
+			continue
+		}
+		if err != nil || len(repo.moved) != 1 || repo.moved[0][1] != tt.want {
+			t.Errorf("%s: got %v, moved %v, want %s", tt.from, err, repo.moved, tt.want)
+		}
+	}
+}
@@ -10,7 +10,9 @@ import (
 )
 
 type Telegram interface {
-	GetUsersByLunchTimes(ctx context.Context, lunchTimes []string) (map[time.Duration][]*model.TelegramUser, error)
+	GetUsersToRemind(ctx context.Context, now time.Time, beforeLunch time.Duration) ([]*model.TelegramUser, error)
+	MarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time, beforeLunch time.Duration) (bool, error)
+	UnmarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time, beforeLunch time.Duration) error
 }
 
 type telegram struct {
@@ -23,10 +25,30 @@ func NewTelegram(repo repository.Telegram) *telegram {
 	}
 }
 
-func (t *telegram) GetUsersByLunchTimes(ctx context.Context, lunchTimes []string) (map[time.Duration][]*model.TelegramUser, error) {
-	telegramUsers, err := t.repo.GetUsersByLunchTimes(ctx, lunchTimes)
+func (t *telegram) GetUsersToRemind(ctx context.Context, now time.Time, beforeLunch time.Duration) ([]*model.TelegramUser, error) {
+	telegramUsers, err := t.repo.GetUsersToRemind(ctx, now, beforeLunch)
 	if err != nil {
-		return nil, fmt.Errorf("getUsersByLunchTimes: %w", err)
+		return nil, fmt.Errorf("getUsersToRemind: %w", err)
 	}
 	return telegramUsers, nil
 }
+
+// MarkReminded returns false if user has already got the reminder, so it isn't sent twice
+func (t *telegram) MarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time,
+	beforeLunch time.Duration) (bool, error) {
+	marked, err := t.repo.MarkReminded(ctx, userTelegramID, remindAt, beforeLunch)
+	if err != nil {
+		return false, fmt.Errorf("markReminded: %w", err)
+	}
+	return marked, nil
+}
+
+// UnmarkReminded lets the reminder which hasn't been sent be sent again
+func (t *telegram) UnmarkReminded(ctx context.Context, userTelegramID int64, remindAt time.Time,
+	beforeLunch time.Duration) error {
+	err := t.repo.UnmarkReminded(ctx, userTelegramID, remindAt, beforeLunch)
+	if err != nil {
+		return fmt.Errorf("unmarkReminded: %w", err)
+	}
+	return nil
+}
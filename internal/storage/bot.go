@@ -1,52 +1,122 @@
 package storage
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"time"
 
-const (
-	CreateOrganization = "create_organization"
-	JoinToOrganization = "join"
-	AddAddress         = "add_address"
-	AddFirstName       = "first_name"
-	AddLastName        = "last_name"
-	AddMiddleName      = "middle_name"
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
+	"github.com/sirupsen/logrus"
 )
 
-var (
-	ErrDoesntExistMessage   = fmt.Errorf("doesn't exist message by user")
-	ErrUserWaitOtherMessage = fmt.Errorf("user waits other message")
+const (
+	AddDish             = "add_dish"
+	AddDishName         = "add_dish_name"
+	AddDishPrice        = "add_dish_price"
+	AddDishDescription  = "add_dish_description"
+	AddDishPhoto        = "add_dish_photo"
+	AddDishWeight       = "add_dish_weight"
+	AddDishCalories     = "add_dish_calories"
+	AddDishAllergens    = "add_dish_allergens"
+	EditDish            = "edit_dish"
+	EditDishSelect      = "edit_dish_select"
+	EditDishName        = "edit_dish_name"
+	EditDishPrice       = "edit_dish_price"
+	EditDishCategory    = "edit_dish_category"
+	EditDishDescription = "edit_dish_description"
+	EditDishPhoto       = "edit_dish_photo"
+	EditDishWeight      = "edit_dish_weight"
+	EditDishCalories    = "edit_dish_calories"
+	EditDishAllergens   = "edit_dish_allergens"
+	DeleteDish          = "delete_dish"
+	DeleteDishSelect    = "delete_dish_select"
+	DeleteDishConfirm   = "delete_dish_confirm"
+	PlanMenu            = "plan_menu"
+	SaveTemplate        = "save_template"
 )
 
-type MessageType struct {
-	Action    string
-	MessageID int
-
-	// Data if action has 2 step
-	DataOnFirstStep string
-}
+// DefaultTTL is how long the bot waits for the message of user. Steps which need another time pass their own TTL
+const DefaultTTL = 30 * time.Minute
 
+// Messages keeps the messages which the bot waits from users. Only the last waited message of user is kept
 type Messages struct {
-	storeByUserID map[int64]*MessageType
+	conversations repository.Conversation
+	clock         clock.Clock
 }
 
-func NewMessage() *Messages {
+func NewMessage(conversations repository.Conversation, clock clock.Clock) *Messages {
 	return &Messages{
-		storeByUserID: make(map[int64]*MessageType),
+		conversations: conversations,
+		clock:         clock,
 	}
 }
 
-func (m *Messages) WaitMessage(userID int64, action string, messageID int, data string) {
-	m.storeByUserID[userID] = &MessageType{
+func (m *Messages) WaitMessage(ctx context.Context, userID int64, action string, data string) error {
+	return m.Wait(ctx, userID, &model.Conversation{
 		Action:          action,
-		MessageID:       messageID,
 		DataOnFirstStep: data,
+	}, DefaultTTL)
+}
+
+func (m *Messages) WaitDishMessage(ctx context.Context, userID int64, action string, dish *model.Dish) error {
+	return m.Wait(ctx, userID, &model.Conversation{
+		Action: action,
+		Dish:   dish,
+	}, DefaultTTL)
+}
+
+// Wait waits for the message of user for the ttl, zero ttl is DefaultTTL
+func (m *Messages) Wait(ctx context.Context, userID int64, conversation *model.Conversation, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = DefaultTTL
 	}
+	err := m.conversations.Save(ctx, userID, conversation, m.clock.Now().Add(ttl))
+	if err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+	return nil
 }
 
-func (m *Messages) Extract(userID int64) (*MessageType, bool) {
-	mt, ok := m.storeByUserID[userID]
-	if !ok {
-		return nil, false
+// Extract returns the waited message and forgets it. It returns nil if user isn't waited for or the wait is expired
+func (m *Messages) Extract(ctx context.Context, userID int64) (*model.Conversation, error) {
+	conversation, err := m.conversations.Take(ctx, userID, m.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("take: %w", err)
+	}
+	return conversation, nil
+}
+
+// Cancel forgets the waited message. It returns false if user isn't waited for
+func (m *Messages) Cancel(ctx context.Context, userID int64) (bool, error) {
+	ok, err := m.conversations.Delete(ctx, userID, m.clock.Now())
+	if err != nil {
+		return false, fmt.Errorf("delete: %w", err)
+	}
+	return ok, nil
+}
+
+// Jobs returns the job which removes expired messages of users who haven't answered
+func (m *Messages) Jobs() []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:     "expiredConversations",
+			Schedule: scheduler.MustParseCron("0 * * * *"),
+			Timeout:  time.Minute,
+			Run:      m.deleteExpired,
+		},
+	}
+}
+
+func (m *Messages) deleteExpired(ctx context.Context, at time.Time) error {
+	deleted, err := m.conversations.DeleteExpired(ctx, at)
+	if err != nil {
+		return fmt.Errorf("deleteExpired: %w", err)
+	}
+	if deleted > 0 {
+		logrus.Infof("%d expired conversations are deleted", deleted)
 	}
-	delete(m.storeByUserID, userID)
-	return mt, true
+	return nil
 }
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
+)
+
+func TestMessages(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC))
+	messages := NewMessage(NewMemory(), clk)
+
+	err := messages.WaitDishMessage(ctx, 1, AddDishPrice, &model.Dish{Name: "Борщ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversation, err := messages.Extract(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conversation == nil || conversation.Action != AddDishPrice || conversation.Dish.Name != "Борщ" {
+		t.Fatalf("Extract() = %+v", conversation)
+	}
+	if conversation, _ = messages.Extract(ctx, 1); conversation != nil {
+		t.Errorf("message is extracted twice: %+v", conversation)
+	}
+
+	// the step with its own TTL is waited for less than other steps
+	if err = messages.WaitMessage(ctx, 1, PlanMenu, ""); err != nil {
+		t.Fatal(err)
+	}
+	err = messages.Wait(ctx, 2, &model.Conversation{Action: SaveTemplate, DataOnFirstStep: "2026-06-01"}, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk.Add(15 * time.Minute)
+	if conversation, _ = messages.Extract(ctx, 1); conversation == nil {
+		t.Error("choosing of the menu day is expired in 15 minutes")
+	}
+	if conversation, _ = messages.Extract(ctx, 2); conversation != nil {
+		t.Errorf("saving of the template isn't expired in 15 minutes: %+v", conversation)
+	}
+}
+
+func TestMessagesCancel(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC))
+	messages := NewMessage(NewMemory(), clk)
+
+	if ok, _ := messages.Cancel(ctx, 1); ok {
+		t.Error("Cancel() = true without waited message")
+	}
+	if err := messages.WaitMessage(ctx, 1, PlanMenu, ""); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := messages.Cancel(ctx, 1); !ok {
+		t.Error("Cancel() = false with waited message")
+	}
+	if conversation, _ := messages.Extract(ctx, 1); conversation != nil {
+		t.Errorf("canceled message is extracted: %+v", conversation)
+	}
+
+	if err := messages.WaitMessage(ctx, 1, PlanMenu, ""); err != nil {
+		t.Fatal(err)
+	}
+	clk.Add(DefaultTTL)
+	if ok, _ := messages.Cancel(ctx, 1); ok {
+		t.Error("Cancel() = true with expired message")
+	}
+}
+
+func TestMessagesDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, time.June, 1, 9, 0, 0, 0, time.UTC))
+	memory := NewMemory()
+	messages := NewMessage(memory, clk)
+
+	if err := messages.WaitMessage(ctx, 1, SaveTemplate, "2026-06-01"); err != nil {
+		t.Fatal(err)
+	}
+	jobs := scheduler.New(clk, nil, 0)
+	jobs.Add(messages.Jobs()...)
+	clk.Add(45 * time.Minute)
+	if err := messages.WaitMessage(ctx, 2, PlanMenu, ""); err != nil {
+		t.Fatal(err)
+	}
+	clk.Add(15 * time.Minute)
+	jobs.Tick(ctx)
+
+	// the user who hasn't answered in time is forgotten without writing again
+	if _, ok := memory.waitByUserID[1]; ok {
+		t.Error("expired message isn't deleted")
+	}
+	if conversation, _ := messages.Extract(ctx, 2); conversation == nil {
+		t.Error("message which isn't expired is deleted")
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chucky-1/food-delivery-bot/internal/model"
+)
+
+type waited struct {
+	conversation *model.Conversation
+	expiresAt    time.Time
+}
+
+// Memory keeps conversations in memory, they are lost on restart. It's the alternative to repository.Conversation
+type Memory struct {
+	mu           sync.Mutex
+	waitByUserID map[int64]waited
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		waitByUserID: make(map[int64]waited),
+	}
+}
+
+func (m *Memory) Save(_ context.Context, userTelegramID int64, conversation *model.Conversation, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waitByUserID[userTelegramID] = waited{conversation: conversation, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *Memory) Take(_ context.Context, userTelegramID int64, now time.Time) (*model.Conversation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.waitByUserID[userTelegramID]
+	if !ok {
+		return nil, nil
+	}
+	delete(m.waitByUserID, userTelegramID)
+	if !w.expiresAt.After(now) {
+		return nil, nil
+	}
+	return w.conversation, nil
+}
+
+func (m *Memory) Delete(_ context.Context, userTelegramID int64, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.waitByUserID[userTelegramID]
+	if !ok {
+		return false, nil
+	}
+	delete(m.waitByUserID, userTelegramID)
+	return w.expiresAt.After(now), nil
+}
+
+func (m *Memory) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for userTelegramID, w := range m.waitByUserID {
+		if !w.expiresAt.After(now) {
+			delete(m.waitByUserID, userTelegramID)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
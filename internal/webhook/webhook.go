@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// secretHeader is the header where telegram puts the secret token which was passed to setWebhook
+const secretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// Receiver receives updates which telegram sends to the webhook and passes them to the updates channel.
+// It's the alternative to long polling, so consumers don't know how updates are received
+type Receiver struct {
+	secret  string
+	updates chan tgbotapi.Update
+}
+
+func NewReceiver(secret string, bufferSize int) *Receiver {
+	return &Receiver{
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, bufferSize),
+	}
+}
+
+// Updates returns the channel of received updates, it's never closed
+func (r *Receiver) Updates() tgbotapi.UpdatesChannel {
+	return r.updates
+}
+
+// ServeHTTP accepts the update only with the right secret token. If the update can't be queued till the request is
+// done, telegram gets the error and sends the update again later
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Header.Get(secretHeader)), []byte(r.secret)) != 1 {
+		logrus.Warnf("webhook: request from %s with wrong secret token", req.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var update tgbotapi.Update
+	err := json.NewDecoder(req.Body).Decode(&update)
+	if err != nil {
+		logrus.Errorf("webhook: decode: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	select {
+	case <-req.Context().Done():
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case r.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Register tells telegram to send updates to the url with the secret token
+func Register(bot *tgbotapi.BotAPI, url, secret string) error {
+	params := make(tgbotapi.Params)
+	params["url"] = url
+	params["secret_token"] = secret
+	_, err := bot.MakeRequest("setWebhook", params)
+	if err != nil {
+		return fmt.Errorf("setWebhook: %w", err)
+	}
+	return nil
+}
+
+// Unregister removes the webhook, telegram doesn't give updates by long polling while the webhook is set
+func Unregister(bot *tgbotapi.BotAPI) error {
+	_, err := bot.Request(tgbotapi.DeleteWebhookConfig{})
+	if err != nil {
+		return fmt.Errorf("deleteWebhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const secret = "secret"
+
+func post(r *Receiver, token, body string) int {
+	req := httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set(secretHeader, token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestReceiver(t *testing.T) {
+	r := NewReceiver(secret, 1)
+
+	if code := post(r, "", `{"update_id":1}`); code != http.StatusUnauthorized {
+		t.Errorf("without token: got %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := post(r, "wrong", `{"update_id":1}`); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: got %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := post(r, secret, `{"update_id":`); code != http.StatusBadRequest {
+		t.Errorf("broken body: got %d, want %d", code, http.StatusBadRequest)
+	}
+	if len(r.Updates()) != 0 {
+		t.Fatalf("rejected requests are queued")
+	}
+
+	body := `{"update_id":2,"message":{"message_id":5,"chat":{"id":10},"from":{"id":10},"text":"/start"}}`
+	if code := post(r, secret, body); code != http.StatusOK {
+		t.Fatalf("right token: got %d, want %d", code, http.StatusOK)
+	}
+	update := <-r.Updates()
+	if update.UpdateID != 2 || update.Message == nil || update.Message.Text != "/start" {
+		t.Fatalf("got update %+v", update)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/telegram", nil)
+	req.Header.Set(secretHeader, secret)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("get: got %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestReceiverQueueIsFull(t *testing.T) {
+	r := NewReceiver(secret, 1)
+	if code := post(r, secret, `{"update_id":1}`); code != http.StatusOK {
+		t.Fatalf("got %d, want %d", code, http.StatusOK)
+	}
+
+	// telegram sends the update again if it isn't queued before the request is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(`{"update_id":2}`)).WithContext(ctx)
+	req.Header.Set(secretHeader, secret)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -2,24 +2,30 @@ package main
 
 import (
 	"context"
-	"io"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/chucky-1/food-delivery-bot/internal/model"
 	"github.com/chucky-1/food-delivery-bot/internal/producer"
 	"github.com/chucky-1/food-delivery-bot/internal/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 
+	"github.com/chucky-1/food-delivery-bot/internal/clock"
 	"github.com/chucky-1/food-delivery-bot/internal/config"
 	"github.com/chucky-1/food-delivery-bot/internal/consumer"
+	"github.com/chucky-1/food-delivery-bot/internal/dispatcher"
+	"github.com/chucky-1/food-delivery-bot/internal/health"
+	"github.com/chucky-1/food-delivery-bot/internal/messenger"
 	"github.com/chucky-1/food-delivery-bot/internal/repository"
+	"github.com/chucky-1/food-delivery-bot/internal/scheduler"
 	"github.com/chucky-1/food-delivery-bot/internal/service"
+	"github.com/chucky-1/food-delivery-bot/internal/webhook"
 )
 
 func main() {
@@ -38,61 +44,121 @@ func main() {
 		logrus.Fatalf("couldn't ping database: %v", err)
 	}
 
-	dishesByCategories, allDishes := parseMenu(&cfg.Menu)
+	location, err := clock.LoadLocation(cfg.Timezone)
+	if err != nil {
+		logrus.Fatalf("couldn't load time zone: %v", err)
+	}
+	clk := clock.New(location)
 
 	transactorRep := repository.NewTransactor(pool)
 	userRep := repository.NewUser(transactorRep)
 	orgRep := repository.NewOrganization(transactorRep)
-	telegramUserRep := repository.NewTelegram(transactorRep)
-	orderRep := repository.NewOrder(transactorRep, cfg.Timezone, cfg.PeriodOfTimeBeforeLunchToShipOrder)
-	menuRep := repository.NewMenu(cfg.Menu.Categories, dishesByCategories, dishesByCategories, make(map[string][]*model.Dish), allDishes)
+	telegramUserRep := repository.NewTelegram(transactorRep, clk)
+	orderRep := repository.NewOrder(transactorRep, clk, cfg.PeriodOfTimeBeforeLunchToShipOrder)
+	menuRep := repository.NewMenu(transactorRep)
+	templateRep := repository.NewTemplate(transactorRep)
+	calendarRep := repository.NewCalendar(transactorRep)
+	shipmentRep := repository.NewShipment(transactorRep, clk)
+	checkpointRep := repository.NewCheckpoint(transactorRep)
 
 	authService := service.NewAuth(userRep, telegramUserRep, orgRep, transactorRep)
 	orgService := service.NewOrganization(orgRep)
 	menuService := service.NewMenu(menuRep)
-	orderService := service.NewOrder(orderRep)
+	calendarService := service.NewCalendar(calendarRep, transactorRep)
+	orderService := service.NewOrder(orderRep, templateRep, userRep, menuService, calendarService, transactorRep, clk,
+		cfg.OrderHorizonDays)
 	telegramService := service.NewTelegram(telegramUserRep)
 	statisticsService := service.NewStatistics(orderRep, transactorRep)
+	shipmentService := service.NewShipment(shipmentRep, orderRep, calendarService, transactorRep)
+	checkpointService := service.NewCheckpoint(checkpointRep)
 
-	msgStore := storage.NewMessage()
+	var conversationRep repository.Conversation = repository.NewConversation(transactorRep)
+	if cfg.ConversationStorage == "memory" {
+		conversationRep = storage.NewMemory()
+	}
+	msgStore := storage.NewMessage(conversationRep, clk)
 
-	bot, err := tgbotapi.NewBotAPI(cfg.TelegramBot.Token)
+	botAPI, err := tgbotapi.NewBotAPI(cfg.TelegramBot.Token)
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	//bot.Debug = true
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = cfg.TelegramBot.Timeout
-	updatesChan := bot.GetUpdatesChan(u)
-
-	adminChan := make(chan tgbotapi.Update)
-	botConsumer := consumer.NewBot(bot, updatesChan, authService, orgService, menuService, orderService, msgStore, cfg.Timezone,
-		cfg.AdminChatID, adminChan)
-	go botConsumer.Consume(ctx)
-
-	adminConsumer := consumer.NewAdmin(bot, adminChan, orgService, menuService, msgStore, cfg.AdminChatID, cfg.StartedLunchTime, cfg.FinishedLunchTime)
-	go adminConsumer.Consume(ctx)
+	//botAPI.Debug = true
+	var updatesChan tgbotapi.UpdatesChannel
+	switch cfg.UpdatesMode {
+	case "webhook":
+		webhookURL, errParse := url.Parse(cfg.Webhook.URL)
+		// the root path is taken by the health check
+		if errParse != nil || webhookURL.Host == "" || webhookURL.Path == "" || webhookURL.Path == "/" {
+			logrus.Fatalf("invalid webhook url %q, it needs the host and the path", cfg.Webhook.URL)
+		}
+		if cfg.Webhook.Secret == "" {
+			logrus.Fatal("webhook secret is empty")
+		}
+		receiver := webhook.NewReceiver(cfg.Webhook.Secret, cfg.TelegramBot.UpdatesQueueSize)
+		http.Handle(webhookURL.Path, receiver)
+		err = webhook.Register(botAPI, webhookURL.String(), cfg.Webhook.Secret)
+		if err != nil {
+			logrus.Fatalf("couldn't register webhook: %v", err)
+		}
+		updatesChan = receiver.Updates()
+	case "polling":
+		err = webhook.Unregister(botAPI)
+		if err != nil {
+			logrus.Fatalf("couldn't unregister webhook: %v", err)
+		}
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = cfg.TelegramBot.Timeout
+		updatesChan = botAPI.GetUpdatesChan(u)
+	default:
+		logrus.Fatalf("unknown updates mode %q", cfg.UpdatesMode)
+	}
+	bot := messenger.New(botAPI)
 
-	usersReminder := producer.NewUsersReminder(bot, telegramService, orderService, cfg.Timezone, cfg.StartingMinutes, cfg.TickInterval,
+	reportSchedule := cfg.ReportSchedule
+	if reportSchedule == "" {
+		reportSchedule = fmt.Sprintf("0 %d * * *", cfg.ReportHour)
+	}
+	reportCron, err := scheduler.ParseCron(reportSchedule)
+	if err != nil {
+		logrus.Fatalf("couldn't parse report schedule: %v", err)
+	}
+	usersReminder := producer.NewUsersReminder(bot, telegramService, orderService, calendarService, orgService, clk,
 		cfg.PeriodOfTimeBeforeLunchToShipOrder, cfg.FirstReminder, cfg.SecondReminder)
-	go usersReminder.Remind(ctx)
-
-	orderSender := producer.NewOrderSender(bot, orderService, cfg.Timezone, cfg.StartingMinutes, cfg.TickInterval,
+	orderSender := producer.NewOrderSender(bot, shipmentService, orgService, clk,
 		cfg.PeriodOfTimeBeforeLunchToShipOrder, cfg.AdminChatID)
-	go orderSender.Send(ctx)
-
-	statisticsSender := producer.NewStatisticsSender(bot, statisticsService, cfg.Timezone, cfg.ReportHour, cfg.ReportReceivers)
-	go statisticsSender.StatisticsSend(ctx)
+	statisticsSender := producer.NewStatisticsSender(bot, statisticsService, reportCron, cfg.ReportReceivers)
+	jobs := scheduler.New(clk, checkpointService, cfg.CatchUpLimit)
+	jobs.Add(usersReminder.Jobs()...)
+	jobs.Add(orderSender.Jobs()...)
+	jobs.Add(statisticsSender.Jobs()...)
+	jobs.Add(msgStore.Jobs()...)
+	go jobs.Run(ctx)
+
+	adminConsumer := consumer.NewAdmin(bot, orgService, menuService, calendarService, shipmentService, clk, msgStore,
+		jobs, cfg.AdminChatID, cfg.StartedLunchTime, cfg.FinishedLunchTime)
+	err = adminConsumer.SendWelcomeMessage()
+	if err != nil {
+		logrus.Errorf("admin: %s", err.Error())
+	}
+	botConsumer := consumer.NewBot(bot, authService, orgService, menuService, orderService, msgStore, clk,
+		cfg.AdminChatID, adminConsumer)
+	updatesDispatcher := dispatcher.New(botConsumer.Handle, cfg.TelegramBot.Workers, cfg.TelegramBot.UpdatesQueueSize)
+	go updatesDispatcher.Run(ctx, updatesChan)
 
 	// http server to check health
-	http.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
-		_, err = io.WriteString(writer, "")
-		if err != nil {
-			logrus.Fatalf("couldn't write response: %v", err)
-		}
-	})
+	http.HandleFunc("/", health.Live)
+	http.HandleFunc("/healthz", health.Live)
+	http.Handle("/readyz", health.NewReady(5*time.Second, map[string]health.Check{
+		"database": transactorRep.Ping,
+		"telegram": bot.Ping,
+	}))
+	http.HandleFunc("/metrics", health.Metrics)
 	go func() {
-		err = http.ListenAndServe(":8080", nil)
+		if cfg.Webhook.CertFile != "" && cfg.Webhook.KeyFile != "" {
+			err = http.ListenAndServeTLS(cfg.HTTPAddr, cfg.Webhook.CertFile, cfg.Webhook.KeyFile, nil)
+		} else {
+			err = http.ListenAndServe(cfg.HTTPAddr, nil)
+		}
 		if err != nil {
 			logrus.Fatalf("couldn't listen and serve: %v", err)
 		}
@@ -106,63 +172,3 @@ func main() {
 	cancel()
 	<-time.After(2 * time.Second)
 }
-
-func parseMenu(menu *config.Menu) (map[string][]*model.Dish, map[string]*model.Dish) {
-	dishesByCategories := make(map[string][]*model.Dish)
-	allDishes := make(map[string]*model.Dish)
-	for _, category := range menu.Categories {
-		switch category {
-		case model.Soups:
-			for name, price := range menu.Soups {
-				dish := model.Dish{
-					Name:     name,
-					Price:    price,
-					Category: model.Soups,
-				}
-				dishesByCategories[model.Soups] = append(dishesByCategories[model.Soups], &dish)
-				allDishes[dish.String()] = &dish
-			}
-		case model.Salads:
-			for name, price := range menu.Salads {
-				dish := model.Dish{
-					Name:     name,
-					Price:    price,
-					Category: model.Salads,
-				}
-				dishesByCategories[model.Salads] = append(dishesByCategories[model.Salads], &dish)
-				allDishes[dish.String()] = &dish
-			}
-		case model.MainCourse:
-			for name, price := range menu.MainCourse {
-				dish := model.Dish{
-					Name:     name,
-					Price:    price,
-					Category: model.MainCourse,
-				}
-				dishesByCategories[model.MainCourse] = append(dishesByCategories[model.MainCourse], &dish)
-				allDishes[dish.String()] = &dish
-			}
-		case model.Desserts:
-			for name, price := range menu.Desserts {
-				dish := model.Dish{
-					Name:     name,
-					Price:    price,
-					Category: model.Desserts,
-				}
-				dishesByCategories[model.Desserts] = append(dishesByCategories[model.Desserts], &dish)
-				allDishes[dish.String()] = &dish
-			}
-		case model.Drinks:
-			for name, price := range menu.Drinks {
-				dish := model.Dish{
-					Name:     name,
-					Price:    price,
-					Category: model.Drinks,
-				}
-				dishesByCategories[model.Drinks] = append(dishesByCategories[model.Drinks], &dish)
-				allDishes[dish.String()] = &dish
-			}
-		}
-	}
-	return dishesByCategories, allDishes
-}
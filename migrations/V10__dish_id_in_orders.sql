@@ -0,0 +1,10 @@
+ALTER TABLE internal.orders
+    ALTER COLUMN category TYPE varchar(100),
+    ADD COLUMN dish_id int REFERENCES internal.dishes (id) ON DELETE SET NULL;
+
+UPDATE internal.orders AS o
+SET dish_id = d.id
+FROM internal.dishes AS d
+         JOIN internal.categories AS c ON c.id = d.category_id
+WHERE o.dish_name = d.name
+  AND o.category = c.name;
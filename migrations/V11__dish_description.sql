@@ -0,0 +1,2 @@
+ALTER TABLE internal.dishes
+    ADD COLUMN description text NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- A dish is on the menu of a day if it is planned for this date, otherwise if it is planned for this weekday.
+-- Days without any plan show the whole menu.
+-- weekday: 0 - sunday, 1 - monday, ..., 6 - saturday
+CREATE TABLE internal.menu_days
+(
+    dish_id int NOT NULL REFERENCES internal.dishes (id) ON DELETE CASCADE,
+    date    date,
+    weekday smallint CHECK (weekday BETWEEN 0 AND 6),
+    CHECK ((date IS NULL) <> (weekday IS NULL)),
+    UNIQUE (dish_id, date),
+    UNIQUE (dish_id, weekday)
+);
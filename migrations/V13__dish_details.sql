@@ -0,0 +1,5 @@
+ALTER TABLE internal.dishes
+    ADD COLUMN photo    varchar NOT NULL DEFAULT '',
+    ADD COLUMN weight   int     NOT NULL DEFAULT 0,
+    ADD COLUMN calories int     NOT NULL DEFAULT 0,
+    ADD COLUMN allergens text[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,15 @@
+ALTER TABLE internal.orders
+    ADD COLUMN quantity int NOT NULL DEFAULT 1 CHECK (quantity > 0);
+
+CREATE TEMPORARY TABLE grouped_orders AS
+SELECT date, user_telegram_id, dish_id, dish_name, dish_price, category, confirmed, count(1) AS quantity
+FROM internal.orders
+GROUP BY date, user_telegram_id, dish_id, dish_name, dish_price, category, confirmed;
+
+DELETE FROM internal.orders;
+
+INSERT INTO internal.orders (date, user_telegram_id, dish_id, dish_name, dish_price, category, confirmed, quantity)
+SELECT date, user_telegram_id, dish_id, dish_name, dish_price, category, confirmed, quantity
+FROM grouped_orders;
+
+DROP TABLE grouped_orders;
@@ -0,0 +1,17 @@
+CREATE TABLE internal.templates
+(
+    id               serial PRIMARY KEY,
+    user_telegram_id bigint      NOT NULL,
+    name             varchar(50) NOT NULL,
+    UNIQUE (user_telegram_id, name)
+);
+
+CREATE TABLE internal.template_items
+(
+    template_id int          NOT NULL REFERENCES internal.templates (id) ON DELETE CASCADE,
+    dish_id     int REFERENCES internal.dishes (id) ON DELETE SET NULL,
+    dish_name   varchar(100) NOT NULL,
+    quantity    int          NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX ON internal.template_items (template_id);
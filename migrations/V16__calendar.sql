@@ -0,0 +1,12 @@
+-- Days which differ from the usual schedule (monday - friday are working days):
+-- holidays (working = false) and transferred working saturdays (working = true).
+-- A day of the organization overrides the day of all organizations (organization_id IS NULL)
+CREATE TABLE internal.calendar
+(
+    date            date    NOT NULL,
+    organization_id uuid REFERENCES internal.organizations (id) ON DELETE CASCADE,
+    working         boolean NOT NULL
+);
+
+CREATE UNIQUE INDEX ON internal.calendar (date) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX ON internal.calendar (date, organization_id) WHERE organization_id IS NOT NULL;
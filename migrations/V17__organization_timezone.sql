@@ -0,0 +1,3 @@
+-- IANA time zone of the organization, e.g. Europe/Moscow. NULL means the time zone of the bot
+ALTER TABLE internal.organizations
+    ADD COLUMN timezone varchar(64);
@@ -0,0 +1,9 @@
+CREATE TABLE internal.conversations
+(
+    user_telegram_id bigint PRIMARY KEY,
+    action           varchar(50) NOT NULL,
+    message_id       int         NOT NULL,
+    data             text        NOT NULL DEFAULT '',
+    dish             jsonb,
+    expires_at       timestamptz NOT NULL
+);
@@ -0,0 +1,21 @@
+-- Shipment is the batch of confirmed orders of the organization for its lunch on the date. It's sent to the admin
+-- by the outbox worker: pending -> listed (the message with organizations is sent) -> sent (the total is sent)
+CREATE TABLE internal.shipments
+(
+    id              serial PRIMARY KEY,
+    organization_id uuid        NOT NULL REFERENCES internal.organizations (id) ON DELETE CASCADE,
+    date            date        NOT NULL,
+    lunch_time      interval    NOT NULL,
+    ship_at         timestamptz NOT NULL,
+    status          varchar(20) NOT NULL DEFAULT 'pending',
+    attempts        int         NOT NULL DEFAULT 0,
+    last_error      text        NOT NULL DEFAULT '',
+    next_attempt_at timestamptz NOT NULL,
+    sent_at         timestamptz,
+    UNIQUE (organization_id, date, lunch_time)
+);
+
+CREATE INDEX shipments_unsent_idx ON internal.shipments (next_attempt_at) WHERE status <> 'sent';
+
+ALTER TABLE internal.orders
+    ADD COLUMN shipment_id int REFERENCES internal.shipments (id) ON DELETE SET NULL;
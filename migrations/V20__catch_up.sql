@@ -0,0 +1,15 @@
+-- the last instant every producer has processed, instants after it are processed on start
+CREATE TABLE internal.checkpoints
+(
+    producer     varchar(50) PRIMARY KEY,
+    processed_at timestamptz NOT NULL
+);
+
+-- reminders which have been sent, so the reminder isn't sent twice when missed instants are processed again
+CREATE TABLE internal.reminders
+(
+    user_telegram_id bigint      NOT NULL,
+    remind_at        timestamptz NOT NULL,
+    before_lunch     interval    NOT NULL,
+    PRIMARY KEY (user_telegram_id, remind_at, before_lunch)
+);
@@ -0,0 +1,9 @@
+-- reminders are two scheduled jobs now, both of them continue from the checkpoint of the old producer
+INSERT INTO internal.checkpoints (producer, processed_at)
+SELECT job.name, c.processed_at
+FROM internal.checkpoints AS c
+CROSS JOIN (VALUES ('firstReminder'), ('secondReminder')) AS job (name)
+WHERE c.producer = 'usersReminder'
+ON CONFLICT (producer) DO NOTHING;
+
+DELETE FROM internal.checkpoints WHERE producer = 'usersReminder';
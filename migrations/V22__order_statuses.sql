@@ -0,0 +1,14 @@
+-- the status of the order of the user for the day, rows of the day are moved together:
+-- draft -> confirmed -> sent_to_kitchen -> cooking -> out_for_delivery -> delivered, or cancelled before shipping
+ALTER TABLE internal.orders
+    ADD COLUMN status     varchar(20) NOT NULL DEFAULT 'draft',
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+
+-- shipped orders of past days are considered delivered
+UPDATE internal.orders
+SET status = CASE
+                 WHEN shipment_id IS NOT NULL AND date < current_date THEN 'delivered'
+                 WHEN shipment_id IS NOT NULL THEN 'sent_to_kitchen'
+                 WHEN confirmed THEN 'confirmed'
+                 ELSE 'draft'
+    END;
@@ -0,0 +1,89 @@
+-- internal.orders had one row per dish without ID of the order. Now the order of the user for the day is the header
+-- with the number for support chats, the status and totals, and dishes are lines in internal.order_items
+ALTER TABLE internal.orders RENAME TO orders_old;
+
+-- numbers are shown to customers, so they start from 1000 and don't look like counts
+CREATE SEQUENCE internal.order_numbers START 1000;
+
+CREATE TABLE internal.orders
+(
+    id               serial PRIMARY KEY,
+    number           int            NOT NULL UNIQUE DEFAULT nextval('internal.order_numbers'),
+    user_telegram_id bigint         NOT NULL,
+    date             date           NOT NULL,
+    status           varchar(20)    NOT NULL DEFAULT 'draft',
+    total            numeric(10, 2) NOT NULL DEFAULT 0,
+    dishes_count     int            NOT NULL DEFAULT 0,
+    comment          text           NOT NULL DEFAULT '',
+    shipment_id      int REFERENCES internal.shipments (id) ON DELETE SET NULL,
+    created_at       timestamptz    NOT NULL DEFAULT now(),
+    updated_at       timestamptz    NOT NULL DEFAULT now(),
+    UNIQUE (user_telegram_id, date)
+);
+
+CREATE INDEX orders_shipment_id_idx ON internal.orders (shipment_id);
+
+CREATE TABLE internal.order_items
+(
+    id         serial PRIMARY KEY,
+    order_id   int          NOT NULL REFERENCES internal.orders (id) ON DELETE CASCADE,
+    dish_id    int REFERENCES internal.dishes (id) ON DELETE SET NULL,
+    dish_name  varchar(100) NOT NULL,
+    dish_price float        NOT NULL,
+    category   varchar(100) NOT NULL,
+    quantity   int          NOT NULL CHECK (quantity > 0),
+    UNIQUE (order_id, dish_id)
+);
+
+-- the day of the user is placed if any of its rows is confirmed. Statuses of rows aren't trusted for draft and
+-- confirmed days: adding dishes by repeat or template could leave unconfirmed rows in the confirmed day, so the status
+-- of such days is derived from the rows. Shipped rows take the status the admin has moved them to, the least
+-- advanced one if they disagree, and past days which are still sent to the kitchen are considered delivered
+CREATE TEMPORARY TABLE order_days AS
+SELECT user_telegram_id,
+       date,
+       coalesce(bool_or(confirmed), false)       AS placed,
+       max(shipment_id) FILTER (WHERE confirmed) AS shipment_id,
+       min(array_position(ARRAY ['sent_to_kitchen', 'cooking', 'out_for_delivery', 'delivered'], status))
+       FILTER (WHERE confirmed AND shipment_id IS NOT NULL) AS shipped_status,
+       max(updated_at)                           AS updated_at
+FROM internal.orders_old
+GROUP BY user_telegram_id, date;
+
+-- headers get numbers in the order of dates. Cancelled orders had no rows, so there is nothing to keep for them
+INSERT INTO internal.orders (user_telegram_id, date, status, shipment_id, updated_at)
+SELECT user_telegram_id,
+       date,
+       CASE
+           WHEN NOT placed THEN 'draft'
+           WHEN shipment_id IS NULL THEN 'confirmed'
+           WHEN shipped_status > 1
+               THEN (ARRAY ['sent_to_kitchen', 'cooking', 'out_for_delivery', 'delivered'])[shipped_status]
+           WHEN date < current_date THEN 'delivered'
+           ELSE 'sent_to_kitchen'
+           END,
+       shipment_id,
+       updated_at
+FROM order_days
+ORDER BY date, user_telegram_id;
+
+-- placed orders get only the rows which are placed with them: unconfirmed rows were added after the confirmation
+-- and have never been shipped, confirmed rows of the shipped day which aren't in the shipment have been confirmed
+-- too late and have never been shipped either. The dish could be in several rows of the day, they become one line
+INSERT INTO internal.order_items (order_id, dish_id, dish_name, dish_price, category, quantity)
+SELECT h.id, o.dish_id, max(o.dish_name), max(o.dish_price), max(o.category), sum(o.quantity)
+FROM internal.orders_old AS o
+JOIN internal.orders AS h ON h.user_telegram_id = o.user_telegram_id AND h.date = o.date
+WHERE h.status = 'draft'
+   OR o.confirmed AND o.shipment_id IS NOT DISTINCT FROM h.shipment_id
+GROUP BY h.id, o.dish_id, CASE WHEN o.dish_id IS NULL THEN o.dish_name END;
+
+UPDATE internal.orders AS h
+SET total = i.total, dishes_count = i.count
+FROM (SELECT order_id, sum(dish_price * quantity) AS total, sum(quantity) AS count
+      FROM internal.order_items
+      GROUP BY order_id) AS i
+WHERE h.id = i.order_id;
+
+DROP TABLE order_days;
+DROP TABLE internal.orders_old;
@@ -0,0 +1,2 @@
+-- expired conversations are deleted by the scheduled job
+CREATE INDEX conversations_expires_at_idx ON internal.conversations (expires_at);
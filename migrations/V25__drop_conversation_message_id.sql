@@ -0,0 +1,3 @@
+-- answers are matched by the action of the conversation, the ID of the message isn't used
+ALTER TABLE internal.conversations
+    DROP COLUMN message_id;
@@ -0,0 +1,34 @@
+CREATE TABLE internal.categories
+(
+    id         serial PRIMARY KEY,
+    name       varchar(100) NOT NULL UNIQUE,
+    sort_order int          NOT NULL DEFAULT 0
+);
+
+CREATE TABLE internal.dishes
+(
+    id          serial PRIMARY KEY,
+    name        varchar(100) NOT NULL,
+    price       real         NOT NULL,
+    category_id int          NOT NULL REFERENCES internal.categories (id),
+    stop        boolean      NOT NULL DEFAULT false,
+    UNIQUE (category_id, name)
+);
+
+-- Seed the menu from the env config the bot used to read on start.
+-- Placeholders have the same format as the env variables: "Борщ:5.50,Солянка:6"
+INSERT INTO internal.categories (name, sort_order)
+SELECT trim(category), ordinality
+FROM unnest(string_to_array('${categories}', ',')) WITH ORDINALITY AS category
+WHERE trim(category) <> '';
+
+INSERT INTO internal.dishes (name, price, category_id)
+SELECT trim(split_part(item.dish, ':', 1)), split_part(item.dish, ':', 2)::real, c.id
+FROM (VALUES ('Супы', '${soups}'),
+             ('Салаты', '${salads}'),
+             ('Основные блюда', '${main_course}'),
+             ('Десерты', '${desserts}'),
+             ('Напитки', '${drinks}')) AS menu (category, dishes)
+         JOIN internal.categories AS c ON c.name = menu.category
+         CROSS JOIN unnest(string_to_array(menu.dishes, ',')) AS item (dish)
+WHERE trim(item.dish) <> '';
@@ -0,0 +1,12 @@
+ALTER TABLE internal.categories
+    ADD COLUMN emoji   varchar(10) NOT NULL DEFAULT '',
+    ADD COLUMN visible boolean     NOT NULL DEFAULT true;
+
+UPDATE internal.categories
+SET emoji = CASE name
+                WHEN 'Супы' THEN '🍲'
+                WHEN 'Салаты' THEN '🥗'
+                WHEN 'Основные блюда' THEN '🍛'
+                WHEN 'Десерты' THEN '🍰'
+                WHEN 'Напитки' THEN '🥤'
+                ELSE '' END;